@@ -0,0 +1,125 @@
+package radolan
+
+// NaNPolicy determines how missing values are handled by Accumulate.
+type NaNPolicy int
+
+const (
+	SkipNaN      NaNPolicy = iota // missing values are ignored
+	PropagateNaN                  // a single missing value results in a missing sum
+	MinCoverage                   // missing values are ignored if enough values are available
+)
+
+// AccumulateOptions configures the accumulation of composites.
+type AccumulateOptions struct {
+	NaN      NaNPolicy
+	Coverage float64 // minimum fraction of valid values per pixel for MinCoverage (0 - 1)
+	Product  string  // product label of the result (label of the first composite if empty)
+}
+
+// DefaultAccumulateOptions are used when no options are given.
+var DefaultAccumulateOptions = AccumulateOptions{NaN: SkipNaN}
+
+// Accumulate sums the given composites into a new composite holding the
+// precipitation depth in mm. Reflectivity composites (dBZ) are converted to
+// rainfall rates using the Z-R relationship and multiplied by their Interval.
+// Composites measured in mm are summed as they are. All composites must share
+// the same grid.
+//
+// The result uses the latest CaptureTime and ForecastTime of the input, whereas
+// the Interval is the sum of all intervals. Pixels without a valid sum are set
+// to NaN according to the NaN policy of the options. nil options are replaced
+// by DefaultAccumulateOptions.
+//
+// Accumulating 24 hourly RW composites yields daily totals:
+//
+//	day, err := radolan.Accumulate(rw, radolan.Aniol80, nil)
+func Accumulate(cs []*Composite, zr ZR, opts *AccumulateOptions) (*Composite, error) {
+	o := DefaultAccumulateOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if len(cs) == 0 {
+		return nil, newError("Accumulate", "no composites")
+	}
+
+	first := cs[0]
+	g := first.detectGrid()
+	for _, c := range cs {
+		if c.Data == nil {
+			return nil, newError("Accumulate", "data of "+c.Product+" composite required")
+		}
+		if c.Dx != first.Dx || c.Dy != first.Dy || c.Dz != first.Dz || c.detectGrid() != g ||
+			c.proj_wgs84 != first.proj_wgs84 {
+			return nil, newError("Accumulate", "composites do not share the same grid")
+		}
+
+		switch c.DataUnit {
+		case Unit_mm:
+		case Unit_dBZ:
+			if c.Interval <= 0 {
+				return nil, newError("Accumulate", "interval of "+c.Product+" composite required")
+			}
+		default:
+			return nil, newError("Accumulate", "unsupported unit of "+c.Product+" composite: "+c.DataUnit.String())
+		}
+	}
+
+	sum := first.emptyLike()
+	sum.DataUnit = Unit_mm
+	sum.precision = -1
+	sum.Interval = 0
+	if o.Product != "" {
+		sum.Product = o.Product
+	}
+
+	valid := make([]int, len(sum.Raw))
+	for _, c := range cs {
+		hours := c.Interval.Hours()
+		if c.CaptureTime.After(sum.CaptureTime) {
+			sum.CaptureTime = c.CaptureTime
+		}
+		if c.ForecastTime.After(sum.ForecastTime) {
+			sum.ForecastTime = c.ForecastTime
+		}
+		sum.Interval += c.Interval
+
+		for z := 0; z < c.Dz; z++ {
+			for y := 0; y < c.Dy; y++ {
+				for x, v := range c.DataZ[z][y] {
+					if IsNaN(v) {
+						continue
+					}
+
+					depth := float64(v)
+					if c.DataUnit == Unit_dBZ {
+						depth = PrecipitationRate(zr, v) * hours
+					}
+
+					i := sum.Offset(x, y, z)
+					if valid[i] == 0 {
+						sum.Raw[i] = 0
+					}
+					sum.Raw[i] += float32(depth)
+					valid[i]++
+				}
+			}
+		}
+	}
+
+	// apply nan policy
+	for i, n := range valid {
+		switch o.NaN {
+		case PropagateNaN:
+			if n != len(cs) {
+				sum.Raw[i] = NaN
+			}
+		case MinCoverage:
+			if float64(n) < o.Coverage*float64(len(cs)) {
+				sum.Raw[i] = NaN
+			}
+		}
+	}
+
+	return sum, nil
+}
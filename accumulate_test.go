@@ -0,0 +1,90 @@
+package radolan
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAccumulate(t *testing.T) {
+	// 24 hourly RW composites with 1 mm each, pixel (0, 0) is missing once
+	var rw []*Composite
+	for i := 0; i < 24; i++ {
+		c := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+			if x == 0 && y == 0 && i == 5 || x == 1 && y == 0 {
+				return NaN
+			}
+			return 1
+		})
+		c.Interval = time.Hour
+		c.CaptureTime = c.CaptureTime.Add(time.Duration(i) * time.Hour)
+		c.ForecastTime = c.ForecastTime.Add(time.Duration(i) * time.Hour)
+		rw = append(rw, c)
+	}
+
+	day, err := Accumulate(rw, Aniol80, &AccumulateOptions{Product: "SF"})
+	if err != nil {
+		t.Fatalf("Accumulate(RW): returned error: %#v", err.Error())
+	}
+	if day.Product != "SF" || day.DataUnit != Unit_mm || day.Interval != 24*time.Hour {
+		t.Errorf("Accumulate(RW): Product: %s DataUnit: %s Interval: %v; expected: SF mm 24h",
+			day.Product, day.DataUnit, day.Interval)
+	}
+	if !day.ForecastTime.Equal(rw[23].ForecastTime) || !day.CaptureTime.Equal(rw[23].CaptureTime) {
+		t.Errorf("Accumulate(RW): ForecastTime: %v CaptureTime: %v; expected: %v %v",
+			day.ForecastTime, day.CaptureTime, rw[23].ForecastTime, rw[23].CaptureTime)
+	}
+	if !day.HasProjection || day.Dx != 900 || day.Dy != 900 {
+		t.Errorf("Accumulate(RW): grid not retained")
+	}
+	if day.At(0, 0) != 23 || day.At(2, 2) != 24 || !IsNaN(day.At(1, 0)) {
+		t.Errorf("Accumulate(RW): values: %v %v %v; expected: 23 24 NaN", day.At(0, 0), day.At(2, 2), day.At(1, 0))
+	}
+
+	// nan policies
+	day, _ = Accumulate(rw, Aniol80, &AccumulateOptions{NaN: PropagateNaN})
+	if !IsNaN(day.At(0, 0)) || day.At(2, 2) != 24 {
+		t.Errorf("Accumulate(RW, PropagateNaN): values: %v %v; expected: NaN 24", day.At(0, 0), day.At(2, 2))
+	}
+	day, _ = Accumulate(rw, Aniol80, &AccumulateOptions{NaN: MinCoverage, Coverage: 0.95})
+	if day.At(0, 0) != 23 {
+		t.Errorf("Accumulate(RW, MinCoverage 0.95): value: %v; expected: 23", day.At(0, 0))
+	}
+	day, _ = Accumulate(rw, Aniol80, &AccumulateOptions{NaN: MinCoverage, Coverage: 1})
+	if !IsNaN(day.At(0, 0)) {
+		t.Errorf("Accumulate(RW, MinCoverage 1): value: %v; expected: NaN", day.At(0, 0))
+	}
+
+	// accumulated composites can be encoded
+	var buf bytes.Buffer
+	if err := day.Encode(&buf); err != nil {
+		t.Errorf("Accumulate(RW).Encode(): returned error: %#v", err.Error())
+	}
+
+	// one hour of reflectivity with constant rain rate
+	dbz := Reflectivity(Aniol80, 2.4)
+	var rx []*Composite
+	for i := 0; i < 12; i++ {
+		c := newTestComposite("RX", 3, 900, 900, 0, nil, func(c *Composite, x, y int) float32 { return dbz })
+		c.ForecastTime = c.ForecastTime.Add(time.Duration(i) * 5 * time.Minute)
+		rx = append(rx, c)
+	}
+	hour, err := Accumulate(rx, Aniol80, nil)
+	if err != nil {
+		t.Fatalf("Accumulate(RX): returned error: %#v", err.Error())
+	}
+	if v := hour.At(450, 450); !absequal(float64(v), 2.4, 1e-4) || hour.Interval != time.Hour {
+		t.Errorf("Accumulate(RX): value: %v interval: %v; expected: 2.4 1h", v, hour.Interval)
+	}
+
+	// grid mismatch
+	fx := newTestComposite("FX", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 { return 0 })
+	if _, err := Accumulate([]*Composite{rx[0], fx}, Aniol80, nil); err == nil {
+		t.Errorf("Accumulate(RX, FX): expected error")
+	}
+	// unsupported unit
+	pe := newTestComposite("PE", 0, 200, 224, 0, []float32{1, 2}, func(c *Composite, x, y int) float32 { return 1 })
+	if _, err := Accumulate([]*Composite{pe}, Aniol80, nil); err == nil {
+		t.Errorf("Accumulate(PE): expected error")
+	}
+}
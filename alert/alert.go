@@ -0,0 +1,336 @@
+// Package alert evaluates threshold rules against sequences of composites,
+// e.g. "more than 25 mm within an hour and 10 km of a site" or "more than 50 mm
+// within 24 hours in a catchment". An Engine keeps the state of each rule
+// between evaluations, so that composites can be passed as they arrive. It
+// reports an event when an alert starts and when it ends, but not while it
+// persists.
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"strings"
+	"time"
+)
+
+// Event is the start or end of an alert.
+type Event struct {
+	Rule   string    // name of the rule
+	Active bool      // whether the alert started (true) or ended (false)
+	Start  time.Time // forecast time of the first exceeding composite
+	End    time.Time // forecast time of the first composite below the threshold (zero while active)
+	Value  float64   // aggregated value of the composite causing the event
+	Peak   float64   // maximum aggregated value during the alert
+}
+
+// MarshalJSON encodes the event with lower case keys and a null end while the
+// alert is active.
+func (e Event) MarshalJSON() ([]byte, error) {
+	var end *time.Time
+	if !e.Active {
+		end = &e.End
+	}
+
+	return json.Marshal(struct {
+		Rule   string     `json:"rule"`
+		Active bool       `json:"active"`
+		Start  time.Time  `json:"start"`
+		End    *time.Time `json:"end"`
+		Value  float64    `json:"value"`
+		Peak   float64    `json:"peak"`
+	}{e.Rule, e.Active, e.Start, end, e.Value, e.Peak})
+}
+
+// RuleError records the failure of a single rule, e.g. because its region is
+// not covered by the composite.
+type RuleError struct {
+	Rule string // name of the rule
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return e.Rule + ": " + e.Err.Error()
+}
+
+// RuleErrors is returned by Evaluate when single rules could not be evaluated.
+// The errors are ordered by the rules.
+type RuleErrors []*RuleError
+
+func (e RuleErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Engine evaluates rules and keeps their state.
+type Engine struct {
+	ZR radolan.ZR // Z-R relationship for the accumulation of reflectivity
+
+	rules  []Rule
+	states []state
+}
+
+// state holds the state of a rule.
+type state struct {
+	// region on the grid of the evaluated composites
+	dx, dy  int
+	pixels  []int
+	weights []float64
+	err     error // region not available on the grid
+
+	history []frame   // accumulated frames within the window
+	last    time.Time // forecast time of the latest evaluated composite
+	alert   *Event    // active alert
+}
+
+// frame holds the values of the region pixels of a composite.
+type frame struct {
+	time   time.Time
+	values []float64
+}
+
+// NewEngine returns an engine for the given rules, which accumulates
+// reflectivity using the Aniol80 Z-R relationship.
+func NewEngine(rules []Rule) (*Engine, error) {
+	if err := validate(rules); err != nil {
+		return nil, err
+	}
+
+	return &Engine{
+		ZR:     radolan.Aniol80,
+		rules:  append([]Rule(nil), rules...),
+		states: make([]state, len(rules)),
+	}, nil
+}
+
+// Evaluate evaluates the rules of the product of the composite and returns the
+// resulting events. The composites of each product must be passed in
+// chronological order. Composites whose ForecastTime does not succeed the
+// previously evaluated composite of a rule are skipped, so that repeatedly
+// polled composites do not cause duplicate events. Regions without valid
+// values do not change the state of a rule. Windows are accumulated from the
+// passed composites only, so that sums may be incomplete at first. The first
+// layer of the composite is evaluated.
+//
+// Rules which cannot be evaluated, e.g. because their region is not covered by
+// the grid of the composite, are skipped and returned as RuleErrors along with
+// the events of the remaining rules. The state of the skipped rules remains
+// unchanged, except that a region error is kept until the grid changes. On
+// other errors, no rule is evaluated.
+func (e *Engine) Evaluate(c *radolan.Composite) ([]Event, error) {
+	// compute all values before changing the state
+	type result struct {
+		rule    int
+		regrid  bool // the region changes along with the grid
+		pixels  []int
+		weights []float64
+		err     error // failure of the rule
+		frame   frame
+		value   float64
+	}
+	var results []result
+	var errs RuleErrors
+
+	for i := range e.rules {
+		r, s := &e.rules[i], &e.states[i]
+		if r.Product != c.Product || !s.last.IsZero() && !c.ForecastTime.After(s.last) {
+			continue
+		}
+		if c.Dz < 1 {
+			return nil, newError("Evaluate", "composite holds no data")
+		}
+
+		res := result{rule: i, pixels: s.pixels, weights: s.weights, err: s.err}
+		history := s.history
+		if s.dx != c.Dx || s.dy != c.Dy {
+			// the region error is kept along with the grid
+			res.regrid, history = true, nil
+			res.pixels, res.weights, res.err = r.region(c)
+		}
+
+		if res.err == nil {
+			res.frame, res.err = e.frame(r, res.pixels, c)
+			res.regrid = res.regrid && res.err == nil // the state remains unchanged
+		}
+		if res.err == nil {
+			res.value = aggregate(r, res.weights, history, res.frame)
+		}
+		results = append(results, res)
+	}
+
+	var events []Event
+	for _, res := range results {
+		r, s := &e.rules[res.rule], &e.states[res.rule]
+		if res.regrid {
+			s.dx, s.dy = c.Dx, c.Dy
+			s.pixels, s.weights, s.err, s.history = res.pixels, res.weights, res.err, nil
+		}
+		if res.err != nil {
+			errs = append(errs, &RuleError{r.Name, res.err})
+			continue
+		}
+		s.last = c.ForecastTime
+		if r.Window > 0 {
+			s.history = append(window(r, s.history, c.ForecastTime), res.frame)
+		}
+
+		v := res.value
+		switch {
+		case math.IsNaN(v): // unknown
+		case v > r.Threshold && s.alert == nil:
+			s.alert = &Event{Rule: r.Name, Active: true, Start: c.ForecastTime, Value: v, Peak: v}
+			events = append(events, *s.alert)
+		case v > r.Threshold:
+			s.alert.Value = v
+			s.alert.Peak = math.Max(s.alert.Peak, v)
+		case s.alert != nil:
+			ended := *s.alert
+			ended.Active = false
+			ended.End = c.ForecastTime
+			ended.Value = v
+			events = append(events, ended)
+			s.alert = nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return events, errs
+	}
+	return events, nil
+}
+
+// EvaluateSeries evaluates the composites in the given order and returns all
+// resulting events. The RuleErrors of the composites are collected, other
+// errors abort the evaluation.
+func (e *Engine) EvaluateSeries(cs []*radolan.Composite) ([]Event, error) {
+	var events []Event
+	var errs RuleErrors
+	for _, c := range cs {
+		ev, err := e.Evaluate(c)
+		if rerrs, ok := err.(RuleErrors); ok {
+			errs = append(errs, rerrs...)
+		} else if err != nil {
+			return events, err
+		}
+		events = append(events, ev...)
+	}
+
+	if len(errs) > 0 {
+		return events, errs
+	}
+	return events, nil
+}
+
+// Active returns the currently active alerts in the order of the rules.
+func (e *Engine) Active() []Event {
+	var active []Event
+	for _, s := range e.states {
+		if s.alert != nil {
+			active = append(active, *s.alert)
+		}
+	}
+	return active
+}
+
+// frame returns the values of the region pixels of the composite. The values
+// are converted to precipitation depths (mm) if the rule accumulates.
+func (e *Engine) frame(r *Rule, pixels []int, c *radolan.Composite) (frame, error) {
+	f := frame{time: c.ForecastTime, values: make([]float64, len(pixels))}
+
+	hours := c.Interval.Hours()
+	if r.Window > 0 {
+		switch c.DataUnit {
+		case radolan.Unit_mm:
+		case radolan.Unit_dBZ:
+			if c.Interval <= 0 {
+				return frame{}, newError("Evaluate", "interval of "+c.Product+" composite required")
+			}
+		default:
+			return frame{}, newError("Evaluate", "unsupported unit of "+c.Product+" composite: "+c.DataUnit.String())
+		}
+	}
+
+	for k, p := range pixels {
+		v := c.DataZ[0][p/c.Dx][p%c.Dx]
+		switch {
+		case radolan.IsNaN(v):
+			f.values[k] = math.NaN()
+		case r.Window > 0 && c.DataUnit == radolan.Unit_dBZ:
+			f.values[k] = radolan.PrecipitationRate(e.ZR, v) * hours
+		default:
+			f.values[k] = float64(v)
+		}
+	}
+	return f, nil
+}
+
+// window returns the frames of the history within the window of the rule
+// ending at t, excluding the frame at t itself.
+func window(r *Rule, history []frame, t time.Time) []frame {
+	begin := t.Add(-r.Window)
+	var frames []frame
+	for _, f := range history {
+		if f.time.After(begin) && f.time.Before(t) {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// aggregate returns the aggregated value of the region pixels with the given
+// weights for the frame, which is accumulated with the history if the rule
+// defines a window. NaN is returned if no valid value is available.
+func aggregate(r *Rule, weights []float64, history []frame, f frame) float64 {
+	values := f.values
+	if r.Window > 0 {
+		values = make([]float64, len(f.values))
+		copy(values, f.values)
+		for _, h := range window(r, history, f.time) {
+			for k, v := range h.values {
+				switch {
+				case math.IsNaN(v):
+				case math.IsNaN(values[k]):
+					values[k] = v
+				default:
+					values[k] += v
+				}
+			}
+		}
+	}
+
+	result := math.NaN()
+	var sum, weight float64
+	for k, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+
+		switch r.Aggregation {
+		case Mean:
+			sum += v * weights[k]
+			weight += weights[k]
+		case Min:
+			if math.IsNaN(result) || v < result {
+				result = v
+			}
+		default:
+			if math.IsNaN(result) || v > result {
+				result = v
+			}
+		}
+	}
+
+	if r.Aggregation == Mean && weight > 0 {
+		result = sum / weight
+	}
+	return result
+}
+
+// newError returns an error indicating the failed function and reason
+func newError(function, reason string) error {
+	return fmt.Errorf("alert.%s: %s", function, reason)
+}
@@ -0,0 +1,209 @@
+package alert
+
+import (
+	"encoding/json"
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC)
+
+// testComposite returns a national composite of the given product and unit
+// filled with the value, except for the pixels within the radius (pixels)
+// around (400.5, 300.5), which hold the site value.
+func testComposite(product string, unit radolan.Unit, forecast time.Time, interval time.Duration, value, site float32) *radolan.Composite {
+	c := radolan.NewDummy(product, 4, 900, 900)
+	c.ForecastTime = forecast
+	c.Interval = interval
+	c.DataUnit = unit
+	c.Dz = 1
+
+	data := make([][]float32, c.Dy)
+	for y := range data {
+		data[y] = make([]float32, c.Dx)
+		for x := range data[y] {
+			data[y][x] = value
+			if math.Hypot(float64(x)-400, float64(y)-300) <= 3 {
+				data[y][x] = site
+			}
+		}
+	}
+	c.DataZ = [][][]float32{data}
+	c.Data = data
+	return c
+}
+
+func TestEvaluate(t *testing.T) {
+	c := radolan.NewDummy("RW", 4, 900, 900)
+	north, east := c.PixelCenter(400, 300)
+	n0, e0 := c.PixelCorner(100, 100)
+	n1, e1 := c.PixelCorner(120, 120)
+
+	engine, err := NewEngine([]Rule{
+		{Name: "site", Product: "RW", Location: &radolan.Location{North: north, East: east}, Radius: 10, Threshold: 25},
+		{Name: "catchment", Product: "RW", Polygon: radolan.Polygon{{{e0, n0}, {e1, n0}, {e1, n1}, {e0, n1}, {e0, n0}}},
+			Window: 3 * time.Hour, Aggregation: Mean, Threshold: 50},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine(): returned error: %#v", err.Error())
+	}
+
+	// hourly sums of 20 mm everywhere and varying sums at the site, where the
+	// maximum within the radius includes the surrounding 20 mm
+	hour := func(i int) time.Time { return base.Add(time.Duration(i) * time.Hour) }
+	for i, test := range []struct {
+		value, site float32
+		events      []Event
+	}{
+		{20, 0, nil},
+		{20, 30, []Event{{Rule: "site", Active: true, Start: hour(1), Value: 30, Peak: 30}}},
+		{20, 40, []Event{{Rule: "catchment", Active: true, Start: hour(2), Value: 60, Peak: 60}}},
+		{20, 10, []Event{{Rule: "site", Start: hour(1), End: hour(3), Value: 20, Peak: 40}}},
+		{20, 30, []Event{{Rule: "site", Active: true, Start: hour(4), Value: 30, Peak: 30}}},
+		// missing values do not end the site alert, but reduce the sum
+		{radolan.NaN, radolan.NaN, []Event{{Rule: "catchment", Start: hour(2), End: hour(5), Value: 40, Peak: 60}}},
+	} {
+		events, err := engine.Evaluate(testComposite("RW", radolan.Unit_mm, hour(i), time.Hour, test.value, test.site))
+		if err != nil {
+			t.Fatalf("Evaluate(): hour %d: returned error: %#v", i, err.Error())
+		}
+		if !sameEvents(events, test.events) {
+			t.Errorf("Evaluate(): hour %d: events %+v; expected: %+v", i, events, test.events)
+		}
+	}
+
+	active := engine.Active()
+	if len(active) != 1 || active[0].Rule != "site" || !active[0].Start.Equal(hour(4)) {
+		t.Errorf("Active(): %+v", active)
+	}
+
+	// repeated and other composites are skipped
+	for _, c := range []*radolan.Composite{
+		testComposite("RW", radolan.Unit_mm, hour(4), time.Hour, 0, 0),
+		testComposite("RY", radolan.Unit_mm, hour(6), time.Hour, 0, 0),
+	} {
+		if events, err := engine.Evaluate(c); err != nil || len(events) != 0 {
+			t.Errorf("Evaluate(): %s at %s: events %+v, error %v; expected none", c.Product, c.ForecastTime, events, err)
+		}
+	}
+}
+
+func TestEvaluateRuleErrors(t *testing.T) {
+	c := radolan.NewDummy("RW", 4, 900, 900)
+	north, east := c.PixelCenter(400, 300)
+
+	engine, err := NewEngine([]Rule{
+		{Name: "far", Product: "RW", Location: &radolan.Location{North: 0, East: 0}, Threshold: 25},
+		{Name: "site", Product: "RW", Location: &radolan.Location{North: north, East: east}, Threshold: 25},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine(): returned error: %#v", err.Error())
+	}
+
+	// the rule outside of the composite does not block the other one
+	hour := func(i int) time.Time { return base.Add(time.Duration(i) * time.Hour) }
+	for i, test := range []struct {
+		site   float32
+		events []Event
+	}{
+		{30, []Event{{Rule: "site", Active: true, Start: hour(0), Value: 30, Peak: 30}}},
+		{10, []Event{{Rule: "site", Start: hour(0), End: hour(1), Value: 10, Peak: 30}}},
+	} {
+		events, err := engine.Evaluate(testComposite("RW", radolan.Unit_mm, hour(i), time.Hour, 0, test.site))
+		errs, ok := err.(RuleErrors)
+		if !ok || len(errs) != 1 || errs[0].Rule != "far" {
+			t.Fatalf("Evaluate(): hour %d: returned error: %#v; expected RuleErrors of far", i, err)
+		}
+		if !sameEvents(events, test.events) {
+			t.Errorf("Evaluate(): hour %d: events %+v; expected: %+v", i, events, test.events)
+		}
+	}
+
+	// errors of all composites are collected
+	events, err := engine.EvaluateSeries([]*radolan.Composite{
+		testComposite("RW", radolan.Unit_mm, hour(2), time.Hour, 0, 30),
+		testComposite("RW", radolan.Unit_mm, hour(3), time.Hour, 0, 30),
+	})
+	if errs, ok := err.(RuleErrors); !ok || len(errs) != 2 || len(events) != 1 {
+		t.Errorf("EvaluateSeries(): events %+v, error %#v; expected: 1 event, 2 RuleErrors", events, err)
+	}
+}
+
+func TestEvaluateReflectivity(t *testing.T) {
+	c := radolan.NewDummy("RX", 4, 900, 900)
+	north, east := c.PixelCenter(400, 300)
+
+	engine, err := NewEngine([]Rule{
+		{Name: "site", Product: "RX", Location: &radolan.Location{North: north, East: east}, Window: time.Hour, Threshold: 26},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine(): returned error: %#v", err.Error())
+	}
+
+	// 30 mm/h yield 2.5 mm each 5 minutes, exceeding 26 mm with the 11th composite
+	dBZ := radolan.Reflectivity(radolan.Aniol80, 30)
+	var cs []*radolan.Composite
+	for i := 0; i < 14; i++ {
+		cs = append(cs, testComposite("RX", radolan.Unit_dBZ, base.Add(time.Duration(i)*5*time.Minute), 5*time.Minute, 0, dBZ))
+	}
+
+	events, err := engine.EvaluateSeries(cs)
+	if err != nil {
+		t.Fatalf("EvaluateSeries(): returned error: %#v", err.Error())
+	}
+	if len(events) != 1 || !events[0].Start.Equal(cs[10].ForecastTime) || math.Abs(events[0].Value-27.5) > 0.1 {
+		t.Fatalf("EvaluateSeries(): events %+v; expected: start at %s", events, cs[10].ForecastTime)
+	}
+
+	// the window is limited to the last hour
+	if active := engine.Active(); len(active) != 1 || math.Abs(active[0].Peak-30) > 0.1 {
+		t.Errorf("Active(): %+v; expected: peak of 30 mm", active)
+	}
+
+	// accumulation requires precipitation or reflectivity
+	cs[0].DataUnit = radolan.Unit_km
+	engine, _ = NewEngine(engine.rules)
+	if _, err := engine.Evaluate(cs[0]); err == nil {
+		t.Errorf("Evaluate(): unsupported unit: returned no error")
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	events := []Event{
+		{Rule: "site", Active: true, Start: base, Value: 30, Peak: 30},
+		{Rule: "site", Start: base, End: base.Add(time.Hour), Value: 20, Peak: 40},
+	}
+
+	var lines []string
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("json.Marshal(): returned error: %#v", err.Error())
+		}
+		lines = append(lines, string(data))
+	}
+
+	expected := []string{
+		`{"rule":"site","active":true,"start":"2016-07-31T16:50:00Z","end":null,"value":30,"peak":30}`,
+		`{"rule":"site","active":false,"start":"2016-07-31T16:50:00Z","end":"2016-07-31T17:50:00Z","value":20,"peak":40}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("json.Marshal(): %v; expected: %v", lines, expected)
+	}
+}
+
+func sameEvents(a, b []Event) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Rule != b[i].Rule || a[i].Active != b[i].Active || !a[i].Start.Equal(b[i].Start) ||
+			!a[i].End.Equal(b[i].End) || math.Abs(a[i].Value-b[i].Value) > 1e-6 || math.Abs(a[i].Peak-b[i].Peak) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
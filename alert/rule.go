@@ -0,0 +1,180 @@
+package alert
+
+import (
+	"encoding/json"
+	"gitlab.cs.fau.de/since/radolan"
+	"io"
+	"math"
+	"time"
+)
+
+// Aggregation reduces the values of the pixels of a region to a single value.
+type Aggregation string
+
+const (
+	Max  Aggregation = "max"  // maximum value
+	Mean Aggregation = "mean" // mean value weighted by coverage (e.g. areal precipitation)
+	Min  Aggregation = "min"  // minimum value
+)
+
+// Rule raises an alert while the aggregated value of a region exceeds a
+// threshold. The region is either the circle around Location or the polygon.
+// If Window is set, the composites of the last Window are accumulated to
+// precipitation depths (mm) before aggregation, where reflectivity is
+// converted by the Z-R relationship of the Engine. Otherwise, the values of
+// each composite are evaluated as they are.
+//
+// In JSON, the window is given as duration string like "24h":
+//
+//	{"name": "site", "product": "RY", "location": {"north": 52.52, "east": 13.41},
+//	 "radius": 10, "window": "1h", "aggregation": "max", "threshold": 25}
+type Rule struct {
+	Name    string `json:"name"`    // unique name of the rule
+	Product string `json:"product"` // product label of the evaluated composites
+
+	Location *radolan.Location `json:"location"` // center of a circular region
+	Radius   float64           `json:"radius"`   // radius in km (0 selects the pixel containing Location)
+	Polygon  radolan.Polygon   `json:"polygon"`  // polygonal region in geographical coordinates
+
+	Window      time.Duration `json:"-"`           // accumulation window (0 disables accumulation)
+	Aggregation Aggregation   `json:"aggregation"` // aggregation of the region (Max if empty)
+	Threshold   float64       `json:"threshold"`   // alert while the aggregated value exceeds the threshold
+}
+
+// UnmarshalJSON decodes the rule and parses the window duration.
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type plain Rule
+	aux := struct {
+		*plain
+		Window string `json:"window"`
+	}{plain: (*plain)(r)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.Window = 0
+	if aux.Window != "" {
+		window, err := time.ParseDuration(aux.Window)
+		if err != nil {
+			return err
+		}
+		r.Window = window
+	}
+	return nil
+}
+
+// ReadRules reads a JSON array of rules from rd and validates them. YAML is
+// not supported, as the package depends on the standard library only; YAML
+// rule files have to be converted to JSON beforehand.
+func ReadRules(rd io.Reader) ([]Rule, error) {
+	var rules []Rule
+	if err := json.NewDecoder(rd).Decode(&rules); err != nil {
+		return nil, err
+	}
+	if err := validate(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// validate checks the rules for completeness and unique names.
+func validate(rules []Rule) error {
+	names := make(map[string]bool)
+	for _, r := range rules {
+		fail := func(reason string) error {
+			return newError("validate", "rule "+r.Name+": "+reason)
+		}
+
+		switch {
+		case r.Name == "":
+			return newError("validate", "rule without name")
+		case names[r.Name]:
+			return fail("duplicate name")
+		case r.Product == "":
+			return fail("no product given")
+		case (r.Location == nil) == (r.Polygon == nil):
+			return fail("either location or polygon required")
+		case r.Radius < 0 || r.Window < 0:
+			return fail("negative radius or window")
+		case math.IsNaN(r.Threshold):
+			return fail("invalid threshold")
+		}
+
+		switch r.Aggregation {
+		case "", Max, Mean, Min:
+		default:
+			return fail("unknown aggregation: " + string(r.Aggregation))
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// region returns the pixels (indices y*Dx + x) of the region of the rule on
+// the grid of the composite and their weights.
+func (r *Rule) region(c *radolan.Composite) (pixels []int, weights []float64, err error) {
+	if !c.HasProjection {
+		return nil, nil, newError("region", "no projection available")
+	}
+
+	var mask radolan.Mask
+	if r.Polygon != nil {
+		mask, err = c.Rasterize(radolan.Feature{Polygons: []radolan.Polygon{r.Polygon}})
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		mask = r.circle(c)
+	}
+
+	for y, row := range mask {
+		for x, w := range row {
+			if w > 0 {
+				pixels = append(pixels, y*c.Dx+x)
+				weights = append(weights, w)
+			}
+		}
+	}
+	if pixels == nil {
+		return nil, nil, newError("region", "region outside of the composite")
+	}
+	return pixels, weights, nil
+}
+
+// circle returns the mask of the pixels whose center is located within the
+// radius around the location, or of the pixel containing the location.
+func (r *Rule) circle(c *radolan.Composite) radolan.Mask {
+	x, y := c.Project(r.Location.North, r.Location.East)
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return nil
+	}
+	mask := make(radolan.Mask, c.Dy)
+
+	if r.Radius == 0 {
+		px, py := int(math.Floor(x)), int(math.Floor(y))
+		if px >= 0 && py >= 0 && px < c.Dx && py < c.Dy {
+			mask[py] = make([]float64, c.Dx)
+			mask[py][px] = 1
+		}
+		return mask
+	}
+
+	for py := range mask {
+		ey := (float64(py) + 0.5 - y) * c.Ry // distance in km
+		if math.Abs(ey) > r.Radius {
+			continue
+		}
+		for px := 0; px < c.Dx; px++ {
+			ex := (float64(px) + 0.5 - x) * c.Rx
+			if ex*ex+ey*ey > r.Radius*r.Radius {
+				continue
+			}
+			if mask[py] == nil {
+				mask[py] = make([]float64, c.Dx)
+			}
+			mask[py][px] = 1
+		}
+	}
+	return mask
+}
@@ -0,0 +1,86 @@
+package alert
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRules(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(`[
+		{"name": "site", "product": "RY", "location": {"north": 52.52, "east": 13.41},
+		 "radius": 10, "window": "1h", "aggregation": "max", "threshold": 25},
+		{"name": "catchment", "product": "RW", "window": "24h", "aggregation": "mean", "threshold": 50,
+		 "polygon": [[[10.0, 50.0], [10.5, 50.0], [10.5, 50.5], [10.0, 50.5], [10.0, 50.0]]]}
+	]`))
+	if err != nil {
+		t.Fatalf("ReadRules(): returned error: %#v", err.Error())
+	}
+	if len(rules) != 2 {
+		t.Fatalf("ReadRules(): %d rules; expected: 2", len(rules))
+	}
+
+	site, catchment := rules[0], rules[1]
+	if site.Name != "site" || site.Product != "RY" || site.Location == nil || site.Location.North != 52.52 ||
+		site.Location.East != 13.41 || site.Radius != 10 || site.Window != time.Hour || site.Aggregation != Max ||
+		site.Threshold != 25 || site.Polygon != nil {
+		t.Errorf("ReadRules(): unexpected rule: %+v", site)
+	}
+	if catchment.Window != 24*time.Hour || catchment.Aggregation != Mean || catchment.Location != nil ||
+		len(catchment.Polygon) != 1 || catchment.Polygon[0][1] != [2]float64{10.5, 50.0} {
+		t.Errorf("ReadRules(): unexpected rule: %+v", catchment)
+	}
+
+	// invalid rules
+	for _, rule := range []string{
+		`{"product": "RW", "location": {"north": 50, "east": 10}}`,
+		`{"name": "a", "location": {"north": 50, "east": 10}}`,
+		`{"name": "a", "product": "RW"}`,
+		`{"name": "a", "product": "RW", "location": {"north": 50, "east": 10}, "polygon": [[[10, 50]]]}`,
+		`{"name": "a", "product": "RW", "location": {"north": 50, "east": 10}, "radius": -1}`,
+		`{"name": "a", "product": "RW", "location": {"north": 50, "east": 10}, "window": "1 day"}`,
+		`{"name": "a", "product": "RW", "location": {"north": 50, "east": 10}, "aggregation": "median"}`,
+	} {
+		if _, err := ReadRules(strings.NewReader("[" + rule + "]")); err == nil {
+			t.Errorf("ReadRules(%s): returned no error", rule)
+		}
+	}
+
+	dup := `{"name": "a", "product": "RW", "location": {"north": 50, "east": 10}}`
+	if _, err := ReadRules(strings.NewReader("[" + dup + "," + dup + "]")); err == nil {
+		t.Errorf("ReadRules(): duplicate names: returned no error")
+	}
+}
+
+func TestRegion(t *testing.T) {
+	c := radolan.NewDummy("RW", 4, 900, 900)
+	north, east := c.PixelCenter(400, 300)
+
+	for _, test := range []struct {
+		radius float64
+		pixels int
+	}{
+		{0, 1},
+		{1.5, 9},
+		{10, 317}, // lattice points within a circle of radius 10
+	} {
+		r := Rule{Name: "site", Location: &radolan.Location{North: north, East: east}, Radius: test.radius}
+		pixels, weights, err := r.region(c)
+		if err != nil {
+			t.Fatalf("region(): radius %v: returned error: %#v", test.radius, err.Error())
+		}
+		if math.Abs(float64(len(pixels)-test.pixels)) > float64(test.pixels)/20 || len(weights) != len(pixels) {
+			t.Errorf("region(): radius %v: %d pixels; expected: about %d", test.radius, len(pixels), test.pixels)
+		}
+		if test.radius == 0 && pixels[0] != 300*c.Dx+400 {
+			t.Errorf("region(): radius 0: pixel %d; expected: %d", pixels[0], 300*c.Dx+400)
+		}
+	}
+
+	far := Rule{Name: "far", Location: &radolan.Location{North: 0, East: 0}}
+	if _, _, err := far.region(c); err == nil {
+		t.Errorf("region(): location outside of the composite: returned no error")
+	}
+}
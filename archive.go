@@ -0,0 +1,363 @@
+package radolan
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveOptions configures the decoding of archives by NewCompositesFrom.
+type ArchiveOptions struct {
+	MaxDepth int // maximum nesting depth of containers (8 if <= 0)
+	Workers  int // number of members decoded in parallel (sequential if < 2)
+
+	// CollectErrors continues decoding when single archive members fail. The
+	// failures are returned as MemberErrors along with the remaining
+	// composites.
+	CollectErrors bool
+
+	Name func(name string) bool        // decode only members whose name is accepted (optional)
+	Time func(forecast time.Time) bool // decode only composites whose ForecastTime is accepted (optional)
+}
+
+// DefaultArchiveOptions are used when no options are given.
+var DefaultArchiveOptions = ArchiveOptions{MaxDepth: defaultMaxDepth}
+
+// defaultMaxDepth is the nesting depth used if MaxDepth is not set.
+const defaultMaxDepth = 8
+
+// MemberError records the failure of a single archive member.
+type MemberError struct {
+	Name string // path of the member
+	Err  error
+}
+
+func (e *MemberError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// MemberErrors is returned by NewCompositesFrom when archive members could not
+// be decoded and CollectErrors is set. The errors are ordered by the position
+// of the members in the archive. ScanIndex returns the files and archive
+// members which could not be read in the same way.
+type MemberErrors []*MemberError
+
+func (e MemberErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// container formats identified by their magic bytes
+type container int
+
+const (
+	containerRaw container = iota
+	containerBzip2
+	containerGzip
+	containerTar
+)
+
+// archiveMember is a raw composite read from an archive. The index denotes its
+// position in the archive.
+type archiveMember struct {
+	index int
+	name  string
+	data  []byte
+}
+
+// archiveReader traverses nested containers and passes the contained
+// composites to the decoding workers.
+type archiveReader struct {
+	opts    ArchiveOptions
+	members chan archiveMember
+	n       int // number of members read
+
+	mu       sync.Mutex
+	results  []archiveResult
+	failures []archiveResult
+}
+
+// archiveResult is the decoded composite or the error of an archive member.
+type archiveResult struct {
+	index int
+	comp  *Composite
+	err   *MemberError
+}
+
+// Open reads the file at the given path and returns the contained composites
+// sorted by ForecastTime in ascending order. The container format is detected
+// as described for NewCompositesFrom.
+func Open(path string) ([]*Composite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewCompositesFrom(f, nil)
+}
+
+// NewCompositesFrom reads data from rd and returns the parsed composites sorted
+// by ForecastTime in ascending order. The container format is detected by its
+// magic bytes, so that the following inputs are supported:
+//
+//	raw composite (header terminated by \x03 and binary data)
+//	bzip2 or gzip compressed data
+//	tar archives
+//
+// Containers can be nested, e.g. a tar archive holding .tar.gz files of
+// gzipped composites. The names of members in nested archives are prefixed by
+// the name of the enclosing member and a slash. nil options are replaced by
+// DefaultArchiveOptions. Composites whose unit is not defined in the catalog
+// (e.g. RV) are returned with Unit_unknown as described for ErrUnknownUnit.
+//
+// Archive members can be decoded in parallel by multiple workers. Members
+// rejected by the Name filter are skipped without decoding and the Time filter
+// is applied after parsing the header of each composite. The result does not
+// depend on the number of workers: composites with equal ForecastTime retain
+// their order in the archive.
+func NewCompositesFrom(rd io.Reader, opts *ArchiveOptions) ([]*Composite, error) {
+	o := DefaultArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = defaultMaxDepth
+	}
+
+	workers := o.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ar := &archiveReader{opts: o, members: make(chan archiveMember, workers)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range ar.members {
+				ar.decode(m)
+			}
+		}()
+	}
+
+	walker := containerWalker{visit: ar.read, fail: ar.failMember}
+	err := walker.walk(rd, "", o.MaxDepth)
+	close(ar.members)
+	wg.Wait()
+
+	if err != nil && err != errAborted {
+		return nil, err
+	}
+
+	// restore archive order
+	sort.Slice(ar.failures, func(i, j int) bool { return ar.failures[i].index < ar.failures[j].index })
+	var errs MemberErrors
+	for _, f := range ar.failures {
+		errs = append(errs, f.err)
+	}
+
+	if len(errs) > 0 && !o.CollectErrors {
+		if errs[0].Name == "" { // single composite
+			return nil, errs[0].Err
+		}
+		return nil, errs[0]
+	}
+
+	// sort composites in chronological order
+	results := ar.results
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.comp.ForecastTime.Equal(b.comp.ForecastTime) {
+			return a.index < b.index
+		}
+		return a.comp.ForecastTime.Before(b.comp.ForecastTime)
+	})
+
+	cs := make([]*Composite, len(results))
+	for i, r := range results {
+		cs[i] = r.comp
+	}
+
+	if len(errs) > 0 {
+		return cs, errs
+	}
+	return cs, nil
+}
+
+// errAborted stops the traversal of an archive after a member failed.
+var errAborted = newError("NewCompositesFrom", "aborted")
+
+// containerWalker traverses nested containers. visit is called for each
+// contained composite and fail for each tar member which could not be read.
+// The traversal is aborted when visit or fail return an error.
+type containerWalker struct {
+	visit func(name string, rd *bufio.Reader) error
+	fail  func(name string, err error) error
+}
+
+// walk identifies the container format of rd and visits all composites found.
+// Nested containers are read recursively up to the given depth.
+func (w *containerWalker) walk(rd io.Reader, name string, depth int) error {
+	if depth < 0 {
+		return newError("walkContainer", "maximum nesting depth exceeded")
+	}
+
+	reader := bufio.NewReaderSize(rd, 4096)
+	kind, err := identifyContainer(reader)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case containerBzip2:
+		return w.walk(bzip2.NewReader(reader), name, depth-1)
+
+	case containerGzip:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		return w.walk(gzipReader, name, depth-1)
+
+	case containerTar:
+		tarReader := tar.NewReader(reader)
+		for {
+			hdr, err := tarReader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+				continue // directories, links, ...
+			}
+
+			member := hdr.Name
+			if name != "" {
+				member = name + "/" + hdr.Name
+			}
+
+			if err := w.walk(tarReader, member, depth-1); err != nil {
+				if err := w.fail(member, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return w.visit(name, reader)
+}
+
+// read passes the raw composite to the workers.
+func (ar *archiveReader) read(name string, rd *bufio.Reader) error {
+	if ar.failed() {
+		return errAborted
+	}
+	if name != "" && ar.opts.Name != nil && !ar.opts.Name(name) {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	ar.members <- archiveMember{ar.next(), name, data}
+	return nil
+}
+
+// failMember records the error of an archive member. errAborted is returned
+// if decoding does not continue.
+func (ar *archiveReader) failMember(name string, err error) error {
+	if err == errAborted || !ar.fail(ar.next(), name, err) {
+		return errAborted
+	}
+	return nil
+}
+
+// decode parses the composite of the archive member.
+func (ar *archiveReader) decode(m archiveMember) {
+	dec, err := NewDecoder(bytes.NewReader(m.data))
+	if err != nil && err != ErrUnknownUnit {
+		ar.fail(m.index, m.name, err)
+		return
+	}
+
+	comp := dec.Composite()
+	if ar.opts.Time != nil && !ar.opts.Time(comp.ForecastTime) {
+		return
+	}
+
+	if err := comp.parseData(dec); err != nil {
+		ar.fail(m.index, m.name, err)
+		return
+	}
+	comp.arrangeData()
+
+	ar.mu.Lock()
+	ar.results = append(ar.results, archiveResult{index: m.index, comp: comp})
+	ar.mu.Unlock()
+}
+
+// next returns the archive position of the next member.
+func (ar *archiveReader) next() int {
+	ar.n++
+	return ar.n - 1
+}
+
+// fail records the error of an archive member and reports whether decoding
+// continues.
+func (ar *archiveReader) fail(index int, name string, err error) bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	ar.failures = append(ar.failures, archiveResult{index: index, err: &MemberError{name, err}})
+	return ar.opts.CollectErrors
+}
+
+// failed reports whether the traversal should be aborted.
+func (ar *archiveReader) failed() bool {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	return len(ar.failures) > 0 && !ar.opts.CollectErrors
+}
+
+// identifyContainer returns the container format of the data buffered by
+// reader without consuming it.
+func identifyContainer(reader *bufio.Reader) (container, error) {
+	magic, err := reader.Peek(262)
+	if len(magic) == 0 {
+		if err == nil || err == io.EOF {
+			err = newError("NewCompositesFrom", "empty input")
+		}
+		return containerRaw, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return containerBzip2, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return containerGzip, nil
+	case len(magic) >= 262 && bytes.Equal(magic[257:262], []byte("ustar")):
+		return containerTar, nil
+	}
+
+	return containerRaw, nil
+}
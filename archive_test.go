@@ -0,0 +1,292 @@
+package radolan
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tarArchive returns a tar archive holding the given files in lexical order.
+func tarArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	w.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, name := range names {
+		data := files[name]
+		if err := w.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gzipData returns the gzip compressed data.
+func gzipData(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestNewCompositesFrom(t *testing.T) {
+	// three hourly RW composites
+	var raw [3][]byte
+	for i := range raw {
+		comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+			return float32(i)
+		})
+		comp.ForecastTime = comp.ForecastTime.Add(time.Duration(i) * time.Hour)
+
+		var buf bytes.Buffer
+		if err := comp.Encode(&buf); err != nil {
+			t.Fatalf("RW.Encode(): returned error: %#v", err.Error())
+		}
+		raw[i] = buf.Bytes()
+	}
+
+	day := tarArchive(t, map[string][]byte{
+		"dir/raa01-rw-2.gz": gzipData(raw[2]),
+		"dir/raa01-rw-0":    raw[0],
+	})
+	month := tarArchive(t, map[string][]byte{
+		"day-1.tar.gz":  gzipData(day),
+		"raa01-rw-1.gz": gzipData(raw[1]),
+	})
+
+	testcases := []struct {
+		name  string
+		data  []byte
+		count int
+	}{
+		{"raw", raw[1], 1},
+		{"gzip", gzipData(raw[1]), 1},
+		{"tar", day, 2},
+		{"tar.gz", gzipData(day), 2},
+		{"nested", month, 3},
+	}
+
+	for _, test := range testcases {
+		cs, err := NewCompositesFrom(bytes.NewReader(test.data), nil)
+		if err != nil {
+			t.Fatalf("NewCompositesFrom(%s): returned error: %#v", test.name, err.Error())
+		}
+		if len(cs) != test.count {
+			t.Fatalf("NewCompositesFrom(%s): %d composites; expected: %d", test.name, len(cs), test.count)
+		}
+		for i := 1; i < len(cs); i++ {
+			if !cs[i-1].ForecastTime.Before(cs[i].ForecastTime) {
+				t.Errorf("NewCompositesFrom(%s): composites not sorted by ForecastTime", test.name)
+			}
+		}
+	}
+
+	// composites are identified by ForecastTime and value
+	cs, _ := NewCompositesFrom(bytes.NewReader(month), nil)
+	for i, c := range cs {
+		if c.At(0, 0) != float32(i) {
+			t.Errorf("NewCompositesFrom(nested)[%d].At(0, 0) = %#v; expected: %#v", i, c.At(0, 0), float32(i))
+		}
+	}
+
+	if _, err := NewCompositesFrom(bytes.NewReader(month), &ArchiveOptions{MaxDepth: 1}); err == nil {
+		t.Errorf("NewCompositesFrom(nested, MaxDepth: 1): expected error")
+	}
+	if _, err := NewCompositesFrom(bytes.NewReader(nil), nil); err == nil {
+		t.Errorf("NewCompositesFrom(empty): expected error")
+	}
+
+	// file access
+	dir, err := ioutil.TempDir("", "radolan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "RW-201607.tar")
+	if err := ioutil.WriteFile(path, month, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cs, err := Open(path); err != nil || len(cs) != 3 {
+		t.Errorf("Open(%s): %d composites, error: %v; expected: 3 composites", path, len(cs), err)
+	}
+}
+
+// rvComposite returns the encoded RV composite with the given lead time in
+// minutes. Its unit is not defined in the catalog.
+func rvComposite(t *testing.T, lead int) []byte {
+	t.Helper()
+
+	comp := newTestComposite("RV", 5, 110, 120, -1, nil, func(c *Composite, x, y int) float32 {
+		return float32(lead) / 10
+	})
+	comp.ForecastTime = comp.CaptureTime.Add(time.Duration(lead) * time.Minute)
+
+	var buf bytes.Buffer
+	if err := comp.Encode(&buf); err != nil {
+		t.Fatalf("RV.Encode(): returned error: %#v", err.Error())
+	}
+	return buf.Bytes()
+}
+
+func TestNewCompositesFromUnknownUnit(t *testing.T) {
+	raw := rvComposite(t, 5)
+	if _, err := NewComposite(bytes.NewReader(raw)); err != ErrUnknownUnit {
+		t.Fatalf("NewComposite(RV): returned error: %#v; expected ErrUnknownUnit", err)
+	}
+
+	dir, err := ioutil.TempDir("", "radolan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "DE1200_RV1607311650_005")
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// accepted like by NewComposite
+	cs, err := Open(path)
+	if err != nil || len(cs) != 1 {
+		t.Fatalf("Open(RV): %d composites, error: %v; expected: 1 composite", len(cs), err)
+	}
+	if cs[0].Product != "RV" || cs[0].DataUnit != Unit_unknown || cs[0].At(0, 0) != 0.5 {
+		t.Errorf("Open(RV): %s composite (%s) with value %v; expected: RV (unknown) with value 0.5",
+			cs[0].Product, cs[0].DataUnit, cs[0].At(0, 0))
+	}
+}
+
+func TestNewCompositesFromOptions(t *testing.T) {
+	files := make(map[string][]byte)
+	for i := 0; i < 24; i++ {
+		comp := newTestComposite("FX", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 {
+			return float32(i)
+		})
+		comp.ForecastTime = comp.ForecastTime.Add(time.Duration(i%12) * 5 * time.Minute)
+
+		var buf bytes.Buffer
+		if err := comp.Encode(&buf); err != nil {
+			t.Fatalf("FX.Encode(): returned error: %#v", err.Error())
+		}
+		data := buf.Bytes()
+		if i%5 == 4 {
+			data = data[:len(data)/2] // truncated
+		}
+		files[fmt.Sprintf("FX%02d", i)] = data
+	}
+	archive := tarArchive(t, files)
+
+	// failures do not depend on the number of workers
+	var expected []*Composite
+	var expectedErrs MemberErrors
+	for _, workers := range []int{0, 1, 3, 8} {
+		cs, err := NewCompositesFrom(bytes.NewReader(archive), &ArchiveOptions{MaxDepth: 2, Workers: workers, CollectErrors: true})
+		errs, ok := err.(MemberErrors)
+		if !ok {
+			t.Fatalf("NewCompositesFrom(Workers: %d): returned error: %#v; expected MemberErrors", workers, err)
+		}
+		if len(cs) != 20 || len(errs) != 4 {
+			t.Fatalf("NewCompositesFrom(Workers: %d): %d composites, %d errors; expected: 20 composites, 4 errors",
+				workers, len(cs), len(errs))
+		}
+
+		if expected == nil {
+			expected, expectedErrs = cs, errs
+			continue
+		}
+		for i := range cs {
+			if !cs[i].ForecastTime.Equal(expected[i].ForecastTime) || cs[i].At(0, 0) != expected[i].At(0, 0) {
+				t.Errorf("NewCompositesFrom(Workers: %d)[%d]: order differs", workers, i)
+			}
+		}
+		for i := range errs {
+			if errs[i].Name != expectedErrs[i].Name {
+				t.Errorf("NewCompositesFrom(Workers: %d): errors[%d].Name = %#v; expected: %#v",
+					workers, i, errs[i].Name, expectedErrs[i].Name)
+			}
+		}
+	}
+
+	for i, c := range expected[1:] {
+		if c.ForecastTime.Before(expected[i].ForecastTime) {
+			t.Errorf("NewCompositesFrom(): composites not sorted by ForecastTime")
+		}
+	}
+	if expectedErrs[0].Name != "FX04" {
+		t.Errorf("NewCompositesFrom(): errors[0].Name = %#v; expected: %#v", expectedErrs[0].Name, "FX04")
+	}
+
+	// first failure aborts without CollectErrors
+	_, err := NewCompositesFrom(bytes.NewReader(archive), &ArchiveOptions{MaxDepth: 2, Workers: 4})
+	if merr, ok := err.(*MemberError); !ok || merr.Name != "FX04" {
+		t.Errorf("NewCompositesFrom(): returned error: %#v; expected MemberError of FX04", err)
+	}
+
+	// filters
+	until := time.Date(2016, time.July, 31, 17, 25, 0, 0, time.UTC)
+	opts := &ArchiveOptions{
+		MaxDepth: 2,
+		Workers:  4,
+		Name:     func(name string) bool { return strings.HasPrefix(name, "FX0") },
+		Time:     func(forecast time.Time) bool { return forecast.Before(until) },
+	}
+	cs, err := NewCompositesFrom(bytes.NewReader(archive), opts)
+	if err != nil {
+		t.Fatalf("NewCompositesFrom(filtered): returned error: %#v", err.Error())
+	}
+	// truncated FX04 is skipped before decoding its data
+	if len(cs) != 4 || cs[0].At(0, 0) != 0 || cs[3].At(0, 0) != 3 {
+		t.Errorf("NewCompositesFrom(filtered): %d composites; expected: FX00 to FX03", len(cs))
+	}
+	// RADVOR archive of RV forecasts, whose unit is not defined in the catalog
+	rv := make(map[string][]byte)
+	for lead := 0; lead <= 120; lead += 5 {
+		rv[fmt.Sprintf("DE1200_RV1607311650_%03d", lead)] = rvComposite(t, lead)
+	}
+	cs, err = NewCompositesFrom(bytes.NewReader(gzipData(tarArchive(t, rv))), &ArchiveOptions{
+		Workers:       4,
+		CollectErrors: true,
+		Time:          func(forecast time.Time) bool { return forecast.Before(until) },
+	})
+	if err != nil {
+		t.Fatalf("NewCompositesFrom(RV): returned error: %#v", err.Error())
+	}
+	if len(cs) != 7 || cs[0].Product != "RV" || cs[6].At(0, 0) != 3 {
+		t.Errorf("NewCompositesFrom(RV): %d composites; expected: lead times 0 to 30 min", len(cs))
+	}
+
+	// unset MaxDepth defaults to the depth of DefaultArchiveOptions
+	for _, opts := range []*ArchiveOptions{
+		{Workers: 4, Name: func(name string) bool { return name == "FX00" }},
+		{Time: func(forecast time.Time) bool { return forecast.Before(until) }, CollectErrors: true},
+	} {
+		cs, err := NewCompositesFrom(bytes.NewReader(gzipData(archive)), opts)
+		if _, ok := err.(MemberErrors); err != nil && !ok {
+			t.Fatalf("NewCompositesFrom(no MaxDepth): returned error: %#v", err.Error())
+		}
+		if len(cs) == 0 {
+			t.Errorf("NewCompositesFrom(no MaxDepth): no composites")
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package radolan
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ExportOptions configures the text based exports EncodeASCIIGrid and
+// EncodeXYZ.
+type ExportOptions struct {
+	Layer   int          // z-layer to export
+	NoData  *float64     // value written instead of NaN (-9999 if nil)
+	SkipNaN bool         // omit NaN values (XYZ only)
+	Meters  bool         // projected coordinates in m instead of km
+	Crop    *BoundingBox // export only the area covering this bounding box (optional)
+}
+
+// DefaultExportOptions are used when no options are given.
+var DefaultExportOptions = ExportOptions{}
+
+// defaultNoData is written instead of NaN if no NoData value is given.
+const defaultNoData = -9999
+
+// exportWindow returns the validated options and the exported rectangle of data
+// indices.
+func (c *Composite) exportWindow(function string, opts *ExportOptions) (ExportOptions, image.Rectangle, error) {
+	o := DefaultExportOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.NoData == nil {
+		noData := float64(defaultNoData)
+		o.NoData = &noData
+	}
+
+	if !c.HasProjection {
+		return o, image.Rectangle{}, newError(function, "no projection available")
+	}
+	if o.Layer < 0 || o.Layer >= c.Dz {
+		return o, image.Rectangle{}, newError(function, "invalid layer")
+	}
+
+	rect := image.Rect(0, 0, c.Dx, c.Dy)
+	if o.Crop != nil {
+		rect = c.Rectangle(*o.Crop)
+	}
+	if rect.Empty() {
+		return o, rect, newError(function, "empty export area")
+	}
+
+	return o, rect, nil
+}
+
+// EncodeASCIIGrid writes the given layer of the composite as ESRI ASCII Grid
+// to w. The corner coordinates and cell size are given in the polar
+// stereographic projection of the composite (in km or m). When the horizontal
+// and vertical resolution differ, the dx and dy fields are written instead of
+// cellsize. NaN values are replaced by the NoData value of the options. nil
+// options are replaced by DefaultExportOptions.
+func (c *Composite) EncodeASCIIGrid(wr io.Writer, opts *ExportOptions) error {
+	o, rect, err := c.exportWindow("EncodeASCIIGrid", opts)
+	if err != nil {
+		return err
+	}
+
+	scale := 1.0
+	if o.Meters {
+		scale = 1000
+	}
+
+	w := bufio.NewWriter(wr)
+	fmt.Fprintf(w, "ncols %d\n", rect.Dx())
+	fmt.Fprintf(w, "nrows %d\n", rect.Dy())
+	xll, yll := c.planeCoordinates(float64(rect.Min.X), float64(rect.Max.Y))
+	fmt.Fprintf(w, "xllcorner %s\n", formatFloat(xll*scale))
+	fmt.Fprintf(w, "yllcorner %s\n", formatFloat(yll*scale))
+	if math.Abs(c.Rx-c.Ry) < 1e-3*c.Rx {
+		fmt.Fprintf(w, "cellsize %s\n", formatFloat(c.Rx*scale))
+	} else {
+		fmt.Fprintf(w, "dx %s\n", formatFloat(c.Rx*scale))
+		fmt.Fprintf(w, "dy %s\n", formatFloat(c.Ry*scale))
+	}
+	fmt.Fprintf(w, "NODATA_value %s\n", formatFloat(*o.NoData))
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if x != rect.Min.X {
+				w.WriteByte(' ')
+			}
+			w.WriteString(formatValue(c.DataZ[o.Layer][y][x], *o.NoData))
+		}
+		w.WriteByte('\n')
+	}
+
+	return w.Flush()
+}
+
+// EncodeXYZ writes the given layer of the composite as comma separated values
+// to w. Each line holds the projected coordinates (x, y in km or m) and the
+// geographical coordinates (lat, lon) of a pixel center followed by its value.
+// NaN values are either omitted or replaced by the NoData value of the
+// options. nil options are replaced by DefaultExportOptions.
+func (c *Composite) EncodeXYZ(wr io.Writer, opts *ExportOptions) error {
+	o, rect, err := c.exportWindow("EncodeXYZ", opts)
+	if err != nil {
+		return err
+	}
+
+	scale := 1.0
+	if o.Meters {
+		scale = 1000
+	}
+
+	w := bufio.NewWriter(wr)
+	w.WriteString("x,y,lat,lon,value\n")
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			v := c.DataZ[o.Layer][y][x]
+			if IsNaN(v) && o.SkipNaN {
+				continue
+			}
+
+			px, py := c.planeCoordinates(float64(x)+0.5, float64(y)+0.5)
+			north, east := c.PixelCenter(x, y)
+
+			fmt.Fprintf(w, "%s,%s,%s,%s,%s\n", formatFloat(px*scale), formatFloat(py*scale),
+				strconv.FormatFloat(north, 'f', 5, 64), strconv.FormatFloat(east, 'f', 5, 64),
+				formatValue(v, *o.NoData))
+		}
+	}
+
+	return w.Flush()
+}
+
+// formatFloat returns the shortest representation of f.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatValue returns the shortest representation of the data value v or the
+// given no-data value if v is NaN.
+func formatValue(v float32, noData float64) string {
+	if IsNaN(v) {
+		return formatFloat(noData)
+	}
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
@@ -0,0 +1,131 @@
+package radolan
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncodeASCIIGrid(t *testing.T) {
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		if x == y {
+			return NaN
+		}
+		return float32(x) + float32(y)/10
+	})
+
+	var buf bytes.Buffer
+	if err := comp.EncodeASCIIGrid(&buf, nil); err != nil {
+		t.Fatalf("RW.EncodeASCIIGrid(): returned error: %#v", err.Error())
+	}
+	lines := strings.Split(buf.String(), "\n")
+
+	exp := []string{
+		"ncols 900",
+		"nrows 900",
+		fmt.Sprintf("xllcorner %s", formatFloat(comp.offx)),
+		fmt.Sprintf("yllcorner %s", formatFloat(-(comp.offy + 900*comp.Ry))),
+		fmt.Sprintf("cellsize %s", formatFloat(comp.Rx)),
+		"NODATA_value -9999",
+	}
+	for i := range exp {
+		if lines[i] != exp[i] {
+			t.Errorf("RW.EncodeASCIIGrid(): line %d: %#v; expected: %#v", i, lines[i], exp[i])
+		}
+	}
+	if len(lines) != len(exp)+900+1 {
+		t.Fatalf("RW.EncodeASCIIGrid(): %d lines; expected: %d", len(lines), len(exp)+900+1)
+	}
+
+	row := strings.Fields(lines[len(exp)+1]) // y = 1
+	if len(row) != 900 || row[0] != "0.1" || row[1] != "-9999" || row[2] != "2.1" {
+		t.Errorf("RW.EncodeASCIIGrid(): row 1: %#v ...", row[:3])
+	}
+
+	// national grid corner: lower left corner -523.4622 km, -4658.645 km [1]
+	if !absequal(comp.offx, -523.4622, 0.01) || !absequal(-(comp.offy+900*comp.Ry), -4658.645, 0.01) {
+		t.Errorf("RW.EncodeASCIIGrid(): lower left corner: (%#v, %#v)", comp.offx, -(comp.offy + 900*comp.Ry))
+	}
+
+	// unset NoData defaults to -9999, 0 is written as given
+	zero := 0.0
+	for _, test := range []struct {
+		opts   *ExportOptions
+		noData string
+	}{
+		{&ExportOptions{Meters: true}, "-9999"},
+		{&ExportOptions{NoData: &zero}, "0"},
+	} {
+		buf.Reset()
+		if err := comp.EncodeASCIIGrid(&buf, test.opts); err != nil {
+			t.Fatalf("RW.EncodeASCIIGrid(): returned error: %#v", err.Error())
+		}
+		lines := strings.Split(buf.String(), "\n")
+		row := strings.Fields(lines[len(exp)+1])
+		if lines[len(exp)-1] != "NODATA_value "+test.noData || row[1] != test.noData {
+			t.Errorf("RW.EncodeASCIIGrid(): %#v, row 1: %#v ...; expected no-data value: %s", lines[len(exp)-1], row[:3], test.noData)
+		}
+	}
+
+	// cropped in meters
+	buf.Reset()
+	noData := -1.0
+	box := &BoundingBox{North: 52.6, West: 13.0, South: 52.3, East: 13.8} // Berlin
+	if err := comp.EncodeASCIIGrid(&buf, &ExportOptions{NoData: &noData, Meters: true, Crop: box}); err != nil {
+		t.Fatalf("RW.EncodeASCIIGrid(): returned error: %#v", err.Error())
+	}
+	rect := comp.Rectangle(*box)
+	lines = strings.Split(buf.String(), "\n")
+	if lines[0] != fmt.Sprintf("ncols %d", rect.Dx()) || lines[1] != fmt.Sprintf("nrows %d", rect.Dy()) {
+		t.Errorf("RW.EncodeASCIIGrid(): cropped dimensions: %#v %#v; expected: %v", lines[0], lines[1], rect)
+	}
+	if rect.Dx() < 50 || rect.Dx() > 60 || rect.Dy() < 30 || rect.Dy() > 40 {
+		t.Errorf("RW.Rectangle(%#v) = %v: unexpected size", *box, rect)
+	}
+	if xll := fmt.Sprintf("xllcorner %s", formatFloat((comp.offx+float64(rect.Min.X)*comp.Rx)*1000)); lines[2] != xll {
+		t.Errorf("RW.EncodeASCIIGrid(): cropped: %#v; expected: %#v", lines[2], xll)
+	}
+	if lines[5] != "NODATA_value -1" {
+		t.Errorf("RW.EncodeASCIIGrid(): cropped: %#v; expected: %#v", lines[5], "NODATA_value -1")
+	}
+}
+
+func TestEncodeXYZ(t *testing.T) {
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		if x == y {
+			return NaN
+		}
+		return float32(x)
+	})
+
+	var buf bytes.Buffer
+	box := &BoundingBox{North: 52.6, West: 13.0, South: 52.3, East: 13.8}
+	if err := comp.EncodeXYZ(&buf, &ExportOptions{SkipNaN: true, Crop: box}); err != nil {
+		t.Fatalf("RW.EncodeXYZ(): returned error: %#v", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if lines[0] != "x,y,lat,lon,value" {
+		t.Errorf("RW.EncodeXYZ(): header: %#v", lines[0])
+	}
+
+	rect := comp.Rectangle(*box)
+	x, y := rect.Min.X, rect.Min.Y
+	north, east := comp.PixelCenter(x, y)
+	exp := fmt.Sprintf("%s,%s,%.5f,%.5f,%d", formatFloat(comp.offx+(float64(x)+0.5)*comp.Rx),
+		formatFloat(-(comp.offy + (float64(y)+0.5)*comp.Ry)), north, east, x)
+	if lines[1] != exp {
+		t.Errorf("RW.EncodeXYZ(): line 1: %#v; expected: %#v", lines[1], exp)
+	}
+
+	for _, line := range lines[1:] {
+		if strings.HasSuffix(line, ",-9999") || strings.HasSuffix(line, "NaN") {
+			t.Errorf("RW.EncodeXYZ(): NaN value not skipped: %#v", line)
+		}
+	}
+
+	if err := comp.EncodeXYZ(&buf, &ExportOptions{Layer: 1}); err == nil {
+		t.Errorf("RW.EncodeXYZ(): expected error for invalid layer")
+	}
+}
@@ -0,0 +1,168 @@
+// Package cells identifies convective cells in reflectivity composites and
+// tracks them over time. A cell is a connected area of pixels exceeding a
+// reflectivity threshold, where pixels are connected to their eight
+// neighbours. Cells are tracked by linking each cell to the nearest cell of
+// the previous time step, extrapolated by its motion.
+package cells
+
+import (
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"sort"
+	"time"
+)
+
+// Options configures the detection and tracking of cells.
+type Options struct {
+	Threshold float64 // minimum reflectivity of cell pixels in dBZ
+	MinArea   float64 // minimum area of a cell in km²
+	MaxSpeed  float64 // maximum speed of tracked cells in km/h
+}
+
+// DefaultOptions are used when no options are given. They correspond to
+// common thresholds for the detection of thunderstorms.
+var DefaultOptions = Options{
+	Threshold: 46,
+	MinArea:   15,
+	MaxSpeed:  120,
+}
+
+// Cell is a convective cell detected in a composite.
+type Cell struct {
+	ID   int       // track identifier (assigned by Track, 0 otherwise)
+	Time time.Time // forecast time of the composite
+
+	X, Y        float64 // centroid in data indices
+	North, East float64 // centroid in geographical coordinates
+
+	Pixels  int     // number of pixels
+	Area    float64 // area in km²
+	MaxDBZ  float64 // maximum reflectivity in dBZ
+	EchoTop float64 // maximum echo top in km (NaN if not available)
+
+	// motion since the previous time step (NaN if not tracked)
+	Speed     float64 // speed in km/h
+	Direction float64 // direction of motion in degrees clockwise from north
+}
+
+// Detect returns the cells of the reflectivity composite ordered by
+// decreasing maximum reflectivity. The centroid is the mean location of the
+// cell pixels. If an echo top composite like PE is given, the maximum echo
+// top of each cell is determined from pixels sharing the data indices, if the
+// composites share the same dimensions, or else from the pixels at the same
+// geographical coordinates. nil options are replaced by DefaultOptions.
+func Detect(c, echoTop *radolan.Composite, opts *Options) ([]Cell, error) {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if c.DataUnit != radolan.Unit_dBZ {
+		return nil, newError("Detect", "reflectivity (dBZ) data required")
+	}
+	if !c.HasProjection {
+		return nil, newError("Detect", "no projection available")
+	}
+	if c.Dz < 1 {
+		return nil, newError("Detect", "composite holds no data")
+	}
+	sameGrid := echoTop != nil && echoTop.Dx == c.Dx && echoTop.Dy == c.Dy
+	if echoTop != nil && !sameGrid && !echoTop.HasProjection {
+		return nil, newError("Detect", "echo top composite does not match grid")
+	}
+
+	pixelArea := c.Rx * c.Ry
+	label := make([]bool, c.Dx*c.Dy) // visited pixels
+	var cells []Cell
+	var stack, pixels []int // indices y*Dx + x
+
+	for start := range label {
+		if label[start] || !exceeds(c.DataZ[0][start/c.Dx][start%c.Dx], o.Threshold) {
+			continue
+		}
+
+		// flood fill of the connected component
+		cell := Cell{Time: c.ForecastTime, MaxDBZ: math.Inf(-1), EchoTop: math.NaN(), Speed: math.NaN(), Direction: math.NaN()}
+		label[start] = true
+		stack = append(stack[:0], start)
+		pixels = pixels[:0]
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%c.Dx, i/c.Dx
+
+			pixels = append(pixels, i)
+			cell.X += float64(x) + 0.5
+			cell.Y += float64(y) + 0.5
+			cell.MaxDBZ = math.Max(cell.MaxDBZ, float64(c.DataZ[0][y][x]))
+
+			for ny := y - 1; ny <= y+1; ny++ {
+				for nx := x - 1; nx <= x+1; nx++ {
+					if nx < 0 || ny < 0 || nx >= c.Dx || ny >= c.Dy {
+						continue
+					}
+					n := ny*c.Dx + nx
+					if !label[n] && exceeds(c.DataZ[0][ny][nx], o.Threshold) {
+						label[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		cell.Pixels = len(pixels)
+		cell.Area = float64(cell.Pixels) * pixelArea
+		if cell.Area < o.MinArea {
+			continue
+		}
+
+		cell.X /= float64(cell.Pixels)
+		cell.Y /= float64(cell.Pixels)
+		cell.North, cell.East = c.Unproject(cell.X, cell.Y)
+		if echoTop != nil {
+			cell.EchoTop = maxEchoTop(c, echoTop, pixels, sameGrid)
+		}
+		cells = append(cells, cell)
+	}
+
+	sortCells(cells)
+	return cells, nil
+}
+
+// exceeds reports whether the value is valid and reaches the threshold.
+func exceeds(v float32, threshold float64) bool {
+	return !radolan.IsNaN(v) && float64(v) >= threshold
+}
+
+// sortCells sorts the cells by decreasing maximum reflectivity.
+func sortCells(cells []Cell) {
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].MaxDBZ > cells[j].MaxDBZ })
+}
+
+// maxEchoTop returns the maximum echo top of the pixels (NaN if not
+// available).
+func maxEchoTop(c, echoTop *radolan.Composite, pixels []int, sameGrid bool) float64 {
+	max := math.NaN()
+	for _, i := range pixels {
+		x, y := i%c.Dx, i/c.Dx
+
+		var v float32
+		if sameGrid {
+			v = echoTop.AtZ(x, y, 0)
+		} else {
+			north, east := c.Unproject(float64(x)+0.5, float64(y)+0.5)
+			v = echoTop.Sample(north, east, radolan.Nearest)
+		}
+
+		if !radolan.IsNaN(v) && (math.IsNaN(max) || float64(v) > max) {
+			max = float64(v)
+		}
+	}
+	return max
+}
+
+// newError returns an error indicating the failed function and reason
+func newError(function, reason string) error {
+	return fmt.Errorf("cells.%s: %s", function, reason)
+}
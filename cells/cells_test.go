@@ -0,0 +1,142 @@
+package cells
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC)
+
+// disc is a circular area of constant value.
+type disc struct {
+	x, y, r float64
+	value   float32
+}
+
+// testComposite returns a national RX composite (1 km resolution) with a
+// background of 20 dBZ and the given discs.
+func testComposite(forecast time.Time, discs ...disc) *radolan.Composite {
+	c := radolan.NewDummy("RX", 4, 900, 900)
+	c.CaptureTime = forecast
+	c.ForecastTime = forecast
+	c.Interval = 5 * time.Minute
+	c.DataUnit = radolan.Unit_dBZ
+	c.Dz = 1
+
+	data := make([][]float32, c.Dy)
+	for y := range data {
+		data[y] = make([]float32, c.Dx)
+		for x := range data[y] {
+			data[y][x] = 20
+			for _, d := range discs {
+				if math.Hypot(float64(x)+0.5-d.x, float64(y)+0.5-d.y) <= d.r {
+					data[y][x] = d.value
+				}
+			}
+		}
+	}
+	c.DataZ = [][][]float32{data}
+	c.Data = data
+	return c
+}
+
+func TestDetect(t *testing.T) {
+	c := testComposite(base,
+		disc{300, 400, 5, 50},
+		disc{600, 200, 8, 55},
+		disc{100, 100, 2, 60}, // too small
+	)
+	c.Data[400][300] = 52
+	c.Data[0][0] = radolan.NaN
+
+	cells, err := Detect(c, nil, nil)
+	if err != nil {
+		t.Fatalf("Detect(): returned error: %#v", err.Error())
+	}
+	if len(cells) != 2 {
+		t.Fatalf("Detect(): %d cells; expected: 2", len(cells))
+	}
+
+	for i, test := range []struct {
+		x, y, r, maxDBZ float64
+	}{
+		{600, 200, 8, 55},
+		{300, 400, 5, 52},
+	} {
+		cell := cells[i]
+		if math.Abs(cell.X-test.x) > 0.01 || math.Abs(cell.Y-test.y) > 0.01 {
+			t.Errorf("Detect(): cell %d: centroid (%.2f, %.2f); expected: (%.2f, %.2f)", i, cell.X, cell.Y, test.x, test.y)
+		}
+		if area := math.Pi * test.r * test.r; math.Abs(cell.Area-area) > 0.1*area {
+			t.Errorf("Detect(): cell %d: area %.1f km²; expected: about %.1f km²", i, cell.Area, area)
+		}
+		if cell.MaxDBZ != test.maxDBZ {
+			t.Errorf("Detect(): cell %d: max %.1f dBZ; expected: %.1f dBZ", i, cell.MaxDBZ, test.maxDBZ)
+		}
+
+		north, east := c.Unproject(test.x, test.y)
+		if math.Abs(cell.North-north) > 1e-3 || math.Abs(cell.East-east) > 1e-3 {
+			t.Errorf("Detect(): cell %d: at (%.4f, %.4f); expected: (%.4f, %.4f)", i, cell.North, cell.East, north, east)
+		}
+		if cell.ID != 0 || !cell.Time.Equal(base) || !math.IsNaN(cell.EchoTop) || !math.IsNaN(cell.Speed) {
+			t.Errorf("Detect(): cell %d: unexpected attributes %+v", i, cell)
+		}
+	}
+
+	// lower minimum area
+	opts := DefaultOptions
+	opts.MinArea = 5
+	if cells, _ := Detect(c, nil, &opts); len(cells) != 3 || cells[0].MaxDBZ != 60 {
+		t.Errorf("Detect(): MinArea 5: %d cells; expected: 3 cells", len(cells))
+	}
+}
+
+func TestDetectEchoTop(t *testing.T) {
+	c := testComposite(base, disc{300, 400, 5, 50})
+
+	et := radolan.NewDummy("PE", 4, 900, 900)
+	et.DataUnit = radolan.Unit_km
+	et.Dz = 1
+	data := make([][]float32, et.Dy)
+	for y := range data {
+		data[y] = make([]float32, et.Dx)
+		for x := range data[y] {
+			data[y][x] = radolan.NaN
+		}
+	}
+	data[401][302] = 9
+	data[400][300] = 11.5
+	data[100][100] = 15 // outside of the cell
+	et.DataZ = [][][]float32{data}
+	et.Data = data
+
+	cells, err := Detect(c, et, nil)
+	if err != nil {
+		t.Fatalf("Detect(): returned error: %#v", err.Error())
+	}
+	if len(cells) != 1 || cells[0].EchoTop != 11.5 {
+		t.Errorf("Detect(): echo top %v; expected: 11.5", cells[0].EchoTop)
+	}
+
+	// local PE composites are not projected
+	if _, err := Detect(c, radolan.NewDummy("PE", 3, 200, 200), nil); err == nil {
+		t.Errorf("Detect(): echo top composite without projection: returned no error")
+	}
+}
+
+func TestDetectErrors(t *testing.T) {
+	c := testComposite(base)
+
+	c.DataUnit = radolan.Unit_mm
+	if _, err := Detect(c, nil, nil); err == nil {
+		t.Errorf("Detect(): precipitation composite: returned no error")
+	}
+
+	local := radolan.NewDummy("RX", 3, 300, 300)
+	local.DataUnit = radolan.Unit_dBZ
+	if _, err := Detect(local, nil, nil); err == nil {
+		t.Errorf("Detect(): composite without projection: returned no error")
+	}
+}
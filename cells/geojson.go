@@ -0,0 +1,78 @@
+package cells
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"io"
+	"math"
+	"time"
+)
+
+// WriteGeoJSON writes the cells as GeoJSON FeatureCollection to w. Each cell
+// is written as Point feature at its centroid with the properties id, time,
+// area (km²), max_dbz, echo_top (km), speed (km/h) and direction (degrees).
+// Unavailable values are null. Additionally, each track of at least two cells
+// is written as LineString feature through the centroids of its cells in
+// chronological order with the properties id, start and end.
+func WriteGeoJSON(w io.Writer, cells []Cell) error {
+	features := []radolan.Feature{}
+
+	tracks := make(map[int][]Cell)
+	var ids []int
+	for _, c := range cells {
+		features = append(features, radolan.Feature{
+			Points: [][2]float64{point(c)},
+			Properties: map[string]interface{}{
+				"id":        c.ID,
+				"time":      c.Time.Format(time.RFC3339),
+				"area":      c.Area,
+				"max_dbz":   c.MaxDBZ,
+				"echo_top":  optional(c.EchoTop),
+				"speed":     optional(c.Speed),
+				"direction": optional(c.Direction),
+			},
+		})
+
+		if c.ID == 0 {
+			continue
+		}
+		if _, ok := tracks[c.ID]; !ok {
+			ids = append(ids, c.ID)
+		}
+		tracks[c.ID] = append(tracks[c.ID], c)
+	}
+
+	for _, id := range ids {
+		track := tracks[id]
+		if len(track) < 2 {
+			continue
+		}
+
+		line := make(radolan.Line, len(track))
+		for i, c := range track {
+			line[i] = point(c)
+		}
+		features = append(features, radolan.Feature{
+			Lines: []radolan.Line{line},
+			Properties: map[string]interface{}{
+				"id":    id,
+				"start": track[0].Time.Format(time.RFC3339),
+				"end":   track[len(track)-1].Time.Format(time.RFC3339),
+			},
+		})
+	}
+
+	return radolan.WriteGeoJSON(w, features)
+}
+
+// point returns the centroid of the cell as [longitude, latitude].
+func point(c Cell) [2]float64 {
+	return [2]float64{c.East, c.North}
+}
+
+// optional returns nil for NaN values, which are not representable in JSON.
+func optional(v float64) interface{} {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return v
+}
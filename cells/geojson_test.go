@@ -0,0 +1,68 @@
+package cells
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteGeoJSON(t *testing.T) {
+	cells, err := Track(testSequence(), nil, nil)
+	if err != nil {
+		t.Fatalf("Track(): returned error: %#v", err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGeoJSON(&buf, cells); err != nil {
+		t.Fatalf("WriteGeoJSON(): returned error: %#v", err.Error())
+	}
+
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+		t.Fatalf("json.Unmarshal(): returned error: %#v", err.Error())
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 13 {
+		t.Fatalf("WriteGeoJSON(): %s of %d features; expected: FeatureCollection of 13 features", fc.Type, len(fc.Features))
+	}
+
+	// points
+	seen := make(map[int]bool)
+	for i, c := range cells {
+		f := fc.Features[i]
+		var point [2]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &point); err != nil || f.Geometry.Type != "Point" {
+			t.Fatalf("WriteGeoJSON(): feature %d: %s %s; expected: Point", i, f.Geometry.Type, f.Geometry.Coordinates)
+		}
+		if point[0] != c.East || point[1] != c.North {
+			t.Errorf("WriteGeoJSON(): feature %d: at %v; expected: [%v %v]", i, point, c.East, c.North)
+		}
+		if f.Properties["id"] != float64(c.ID) || f.Properties["max_dbz"] != c.MaxDBZ || f.Properties["echo_top"] != nil {
+			t.Errorf("WriteGeoJSON(): feature %d: properties %v", i, f.Properties)
+		}
+		if first := !seen[c.ID]; first != (f.Properties["speed"] == nil) {
+			t.Errorf("WriteGeoJSON(): feature %d: speed %v", i, f.Properties["speed"])
+		}
+		seen[c.ID] = true
+	}
+
+	// tracks
+	for i, expected := range []int{4, 4, 2} {
+		f := fc.Features[len(cells)+i]
+		var line [][2]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &line); err != nil || f.Geometry.Type != "LineString" {
+			t.Fatalf("WriteGeoJSON(): track %d: %s %s; expected: LineString", i, f.Geometry.Type, f.Geometry.Coordinates)
+		}
+		if len(line) != expected {
+			t.Errorf("WriteGeoJSON(): track %d: %d points; expected: %d", i, len(line), expected)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package cells
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"sort"
+	"time"
+)
+
+// earthRadius is the mean radius of the earth in km.
+const earthRadius = 6371.0
+
+// track is an active track during tracking.
+type track struct {
+	cell   Cell    // latest cell of the track
+	vx, vy float64 // velocity in data indices per hour
+}
+
+// Track detects the cells of each composite and links them to tracks. The
+// composites must share the same grid and be ordered by ForecastTime. Echo top
+// composites (may be nil) are assigned to the composites by ForecastTime.
+//
+// Each cell of the previous time step is extrapolated by the velocity of its
+// track and linked to the nearest cell it can reach at MaxSpeed. The links are
+// established greedily by increasing distance, so that each cell continues at
+// most one track. Unlinked cells start new tracks. Splits and merges are not
+// modelled: the remaining fragments of a split cell start new tracks, and
+// merged tracks are continued by one of their cells only.
+//
+// The returned cells of all time steps are ordered by time and carry the
+// identifier of their track starting at 1. Speed and Direction describe the
+// motion since the previous cell of the track. nil options are replaced by
+// DefaultOptions.
+func Track(cs, echoTops []*radolan.Composite, opts *Options) ([]Cell, error) {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	for i, c := range cs {
+		if c.Dx != cs[0].Dx || c.Dy != cs[0].Dy {
+			return nil, newError("Track", "composites do not share the same grid")
+		}
+		if i > 0 && !c.ForecastTime.After(cs[i-1].ForecastTime) {
+			return nil, newError("Track", "composites are not in chronological order")
+		}
+	}
+
+	var result []Cell
+	var active []track
+	nextID := 1
+
+	for i, c := range cs {
+		cells, err := Detect(c, echoTopAt(echoTops, c.ForecastTime), &o)
+		if err != nil {
+			return nil, err
+		}
+
+		var links []int // index into active for each cell or -1
+		var hours float64
+		if i > 0 {
+			hours = c.ForecastTime.Sub(cs[i-1].ForecastTime).Hours()
+			links = link(active, cells, c.Rx, c.Ry, hours, o.MaxSpeed*hours)
+		}
+
+		next := make([]track, len(cells))
+		for n := range cells {
+			cell := &cells[n]
+			if links == nil || links[n] < 0 {
+				cell.ID = nextID
+				nextID++
+				next[n] = track{cell: *cell}
+				continue
+			}
+
+			prev := active[links[n]].cell
+			cell.ID = prev.ID
+			cell.Speed = distance(prev.North, prev.East, cell.North, cell.East) / hours
+			cell.Direction = bearing(prev.North, prev.East, cell.North, cell.East)
+			next[n] = track{
+				cell: *cell,
+				vx:   (cell.X - prev.X) / hours,
+				vy:   (cell.Y - prev.Y) / hours,
+			}
+		}
+
+		result = append(result, cells...)
+		active = next
+	}
+
+	return result, nil
+}
+
+// link returns the index of the linked track for each cell (or -1). The
+// tracks are extrapolated by the given number of hours and linked to cells
+// within the maximum distance in km. Rx and Ry are the pixel dimensions in km.
+func link(active []track, cells []Cell, rx, ry, hours, maxDist float64) []int {
+	type candidate struct {
+		track, cell int
+		dist        float64
+	}
+
+	var candidates []candidate
+	for t, tr := range active {
+		px := tr.cell.X + tr.vx*hours
+		py := tr.cell.Y + tr.vy*hours
+		for n, cell := range cells {
+			d := math.Hypot((cell.X-px)*rx, (cell.Y-py)*ry)
+			if d <= maxDist {
+				candidates = append(candidates, candidate{t, n, d})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	links := make([]int, len(cells))
+	for n := range links {
+		links[n] = -1
+	}
+	used := make([]bool, len(active))
+	for _, cand := range candidates {
+		if used[cand.track] || links[cand.cell] >= 0 {
+			continue
+		}
+		used[cand.track] = true
+		links[cand.cell] = cand.track
+	}
+	return links
+}
+
+// echoTopAt returns the echo top composite of the given forecast time or nil.
+func echoTopAt(echoTops []*radolan.Composite, forecast time.Time) *radolan.Composite {
+	for _, et := range echoTops {
+		if et != nil && et.ForecastTime.Equal(forecast) {
+			return et
+		}
+	}
+	return nil
+}
+
+// distance returns the great circle distance between both points in km.
+func distance(north1, east1, north2, east2 float64) float64 {
+	phi1, phi2 := radians(north1), radians(north2)
+	dphi, dlambda := phi2-phi1, radians(east2-east1)
+
+	a := math.Pow(math.Sin(dphi/2), 2) + math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin(dlambda/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// bearing returns the initial bearing from the first to the second point in
+// degrees clockwise from north (0 - 360).
+func bearing(north1, east1, north2, east2 float64) float64 {
+	phi1, phi2 := radians(north1), radians(north2)
+	dlambda := radians(east2 - east1)
+
+	y := math.Sin(dlambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlambda)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
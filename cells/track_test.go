@@ -0,0 +1,119 @@
+package cells
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"testing"
+	"time"
+)
+
+// testSequence returns four composites in intervals of five minutes. Cell A
+// moves east by 3 km, cell B south by 2 km per step. Cell C appears in the
+// third composite.
+func testSequence() []*radolan.Composite {
+	var cs []*radolan.Composite
+	for i := 0; i < 4; i++ {
+		discs := []disc{
+			{300 + 3*float64(i), 400, 5, 50},
+			{600, 200 + 2*float64(i), 6, 55},
+		}
+		if i >= 2 {
+			discs = append(discs, disc{450, 700, 4, 48})
+		}
+		cs = append(cs, testComposite(base.Add(time.Duration(i)*5*time.Minute), discs...))
+	}
+	return cs
+}
+
+func TestTrack(t *testing.T) {
+	cells, err := Track(testSequence(), nil, nil)
+	if err != nil {
+		t.Fatalf("Track(): returned error: %#v", err.Error())
+	}
+	if len(cells) != 10 {
+		t.Fatalf("Track(): %d cells; expected: 10", len(cells))
+	}
+
+	ids := make(map[int][]Cell)
+	for i, c := range cells {
+		if i > 0 && c.Time.Before(cells[i-1].Time) {
+			t.Errorf("Track(): cells are not in chronological order")
+		}
+		ids[c.ID] = append(ids[c.ID], c)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("Track(): %d tracks; expected: 3", len(ids))
+	}
+
+	for id, track := range ids {
+		first := track[0]
+		if !math.IsNaN(first.Speed) || !math.IsNaN(first.Direction) {
+			t.Errorf("Track(): track %d: first cell has speed %v", id, first.Speed)
+		}
+
+		var speed, direction float64
+		switch {
+		case first.MaxDBZ == 50: // A
+			speed, direction = 36, 90
+		case first.MaxDBZ == 55: // B
+			speed, direction = 24, 180
+		default: // C
+			if len(track) != 2 || !first.Time.Equal(base.Add(10*time.Minute)) {
+				t.Errorf("Track(): track %d: %d cells from %s; expected: 2 cells from %s", id, len(track), first.Time, base.Add(10*time.Minute))
+			}
+			speed, direction = 0, math.NaN()
+		}
+
+		for _, c := range track[1:] {
+			// the scale of the projection is about 5% above 1 in central Germany
+			if math.Abs(c.Speed-speed) > 0.1*speed+0.1 {
+				t.Errorf("Track(): track %d: speed %.1f km/h; expected: %.1f km/h", id, c.Speed, speed)
+			}
+			if !math.IsNaN(direction) && math.Abs(c.Direction-direction) > 5 {
+				t.Errorf("Track(): track %d: direction %.1f°; expected: %.1f°", id, c.Direction, direction)
+			}
+		}
+	}
+}
+
+func TestTrackExtrapolation(t *testing.T) {
+	// two cells pass each other at a distance of 4 km, each moving by 10 km
+	// per step, so that the nearest cell of the last step is the wrong one
+	var cs []*radolan.Composite
+	for i := 0; i < 3; i++ {
+		offset := 10 * float64(i)
+		cs = append(cs, testComposite(base.Add(time.Duration(i)*5*time.Minute),
+			disc{300 + offset, 400, 4, 50},
+			disc{330 - offset, 404, 4, 55},
+		))
+	}
+
+	opts := DefaultOptions
+	opts.MaxSpeed = 150 // 12.5 km per step
+	cells, err := Track(cs, nil, &opts)
+	if err != nil {
+		t.Fatalf("Track(): returned error: %#v", err.Error())
+	}
+
+	for _, c := range cells {
+		if c.Time.Equal(cs[2].ForecastTime) {
+			if c.MaxDBZ == 50 && (c.Direction < 45 || c.Direction > 135) {
+				t.Errorf("Track(): cell moving east linked in direction %.1f°", c.Direction)
+			}
+			if c.MaxDBZ == 55 && (c.Direction < 225 || c.Direction > 315) {
+				t.Errorf("Track(): cell moving west linked in direction %.1f°", c.Direction)
+			}
+		}
+	}
+}
+
+func TestTrackErrors(t *testing.T) {
+	cs := testSequence()
+
+	if _, err := Track([]*radolan.Composite{cs[1], cs[0]}, nil, nil); err == nil {
+		t.Errorf("Track(): unordered composites: returned no error")
+	}
+	if _, err := Track([]*radolan.Composite{cs[0], radolan.NewDummy("RX", 3, 300, 300)}, nil, nil); err == nil {
+		t.Errorf("Track(): composites of different grids: returned no error")
+	}
+}
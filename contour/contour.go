@@ -0,0 +1,186 @@
+// Package contour extracts isobands of composites as polygons, e.g. for web
+// maps or warning polygons. The boundaries are computed by marching squares
+// with linear interpolation between the pixel centers and can be simplified
+// by the Douglas-Peucker algorithm. The polygons are given in geographical
+// coordinates and can be written as GeoJSON by radolan.WriteGeoJSON.
+package contour
+
+import (
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+)
+
+// Options configures the extraction of isobands.
+type Options struct {
+	Layer    int     // layer of the composite
+	Simplify float64 // tolerance of the simplification in km (0 disables simplification)
+}
+
+// DefaultOptions are used when no options are given. They yield the exact
+// boundaries of the first layer.
+var DefaultOptions = Options{}
+
+// polygon is an outer boundary with holes in data indices.
+type polygon struct {
+	outer ring
+	holes []ring
+}
+
+// Isobands returns a feature for each band of values between consecutive
+// thresholds, which must be ascending, e.g. dBZ or mm values. Each band covers
+// the values at or above its threshold and below the next threshold, where the
+// last band is unbounded. Values below the first threshold and missing values
+// are not covered. The properties of each feature are the lower limit
+// "threshold", the upper limit "upper" (nil for the last band) and the "unit"
+// of the composite. Bands without area are omitted. The outer boundaries of
+// the polygons run counterclockwise and holes clockwise. As the rings are
+// simplified independently, simplified rings may intersect each other. nil
+// options are replaced by DefaultOptions.
+func Isobands(c *radolan.Composite, thresholds []float64, opts *Options) ([]radolan.Feature, error) {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if !c.HasProjection {
+		return nil, newError("Isobands", "no projection available")
+	}
+	if o.Layer < 0 || o.Layer >= c.Dz {
+		return nil, newError("Isobands", "layer out of range")
+	}
+	if len(thresholds) == 0 {
+		return nil, newError("Isobands", "no thresholds given")
+	}
+	for i, t := range thresholds {
+		if math.IsNaN(t) || i > 0 && t <= thresholds[i-1] {
+			return nil, newError("Isobands", "thresholds are not ascending")
+		}
+	}
+
+	var features []radolan.Feature
+	for i, band := range bands(newGrid(c, o.Layer), thresholds) {
+		var polygons []radolan.Polygon
+		for _, p := range band {
+			if poly := geographic(c, p, o.Simplify); poly != nil {
+				polygons = append(polygons, poly)
+			}
+		}
+		if len(polygons) == 0 {
+			continue
+		}
+
+		var upper interface{}
+		if i+1 < len(thresholds) {
+			upper = thresholds[i+1]
+		}
+		features = append(features, radolan.Feature{
+			Properties: map[string]interface{}{
+				"threshold": thresholds[i],
+				"upper":     upper,
+				"unit":      c.DataUnit.String(),
+			},
+			Polygons: polygons,
+		})
+	}
+
+	return features, nil
+}
+
+// bands returns the polygons of each band between consecutive thresholds.
+// The band is the area above its threshold excluding the area above the next
+// threshold, whose boundaries are therefore included in reverse direction.
+// Boundaries of different thresholds do not cross, as the areas are nested.
+func bands(g *grid, thresholds []float64) [][]polygon {
+	levels := make([][]ring, len(thresholds))
+	for i, t := range thresholds {
+		levels[i] = g.rings(t)
+	}
+
+	bands := make([][]polygon, len(thresholds))
+	for i := range thresholds {
+		rings := levels[i]
+		if i+1 < len(levels) {
+			rings = append([]ring(nil), rings...)
+			for _, r := range levels[i+1] {
+				rings = append(rings, r.reverse())
+			}
+		}
+		bands[i] = assemble(rings)
+	}
+	return bands
+}
+
+// assemble groups the rings to polygons. Counterclockwise rings are outer
+// boundaries, whereas clockwise rings are holes of the smallest enclosing
+// outer boundary.
+func assemble(rings []ring) []polygon {
+	var polygons []polygon
+	var areas []float64
+	var mins, maxs []point
+	var holes []ring
+
+	for _, r := range rings {
+		switch a := r.area(); {
+		case a > 0:
+			min, max := r.bounds()
+			polygons = append(polygons, polygon{outer: r})
+			areas = append(areas, a)
+			mins, maxs = append(mins, min), append(maxs, max)
+		case a < 0:
+			holes = append(holes, r)
+		}
+	}
+
+	for _, h := range holes {
+		p := h.inner()
+		best := -1
+		for k := range polygons {
+			if p.x < mins[k].x || p.y < mins[k].y || p.x > maxs[k].x || p.y > maxs[k].y {
+				continue
+			}
+			if (best < 0 || areas[k] < areas[best]) && polygons[k].outer.contains(p) {
+				best = k
+			}
+		}
+		if best >= 0 {
+			polygons[best].holes = append(polygons[best].holes, h)
+		}
+	}
+
+	return polygons
+}
+
+// geographic returns the simplified polygon in geographical coordinates. The
+// tolerance is given in km. nil is returned if the outer boundary vanishes.
+func geographic(c *radolan.Composite, p polygon, tolerance float64) radolan.Polygon {
+	outer := p.outer.simplify(tolerance, c.Rx, c.Ry)
+	if outer == nil {
+		return nil
+	}
+
+	poly := radolan.Polygon{lonLat(c, outer)}
+	for _, h := range p.holes {
+		if h = h.simplify(tolerance, c.Rx, c.Ry); h != nil {
+			poly = append(poly, lonLat(c, h))
+		}
+	}
+	return poly
+}
+
+// lonLat returns the closed ring in geographical coordinates as
+// [longitude, latitude] points.
+func lonLat(c *radolan.Composite, r ring) [][2]float64 {
+	coords := make([][2]float64, len(r)+1)
+	for k, p := range r {
+		north, east := c.Unproject(p.x, p.y)
+		coords[k] = [2]float64{east, north}
+	}
+	coords[len(r)] = coords[0]
+	return coords
+}
+
+// newError returns an error indicating the failed function and reason
+func newError(function, reason string) error {
+	return fmt.Errorf("contour.%s: %s", function, reason)
+}
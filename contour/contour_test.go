@@ -0,0 +1,135 @@
+package contour
+
+import (
+	"bytes"
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"testing"
+)
+
+// lonLatArea returns the signed area of the closed ring in square degrees,
+// which is positive for counterclockwise rings.
+func lonLatArea(r [][2]float64) float64 {
+	var a float64
+	for k := 0; k+1 < len(r); k++ {
+		a += r[k][0]*r[k+1][1] - r[k+1][0]*r[k][1]
+	}
+	return a / 2
+}
+
+func TestIsobands(t *testing.T) {
+	const cx, cy = 300.3, 400.7
+	c := testComposite(cone(cx, cy))
+
+	features, err := Isobands(c, []float64{20, 40, 60}, nil)
+	if err != nil {
+		t.Fatalf("Isobands(): returned error: %#v", err.Error())
+	}
+	if len(features) != 2 {
+		t.Fatalf("Isobands(): %d features; expected: 2", len(features))
+	}
+
+	for i, test := range []struct {
+		threshold, upper float64
+		radii            []float64
+	}{
+		{20, 40, []float64{30, 10}}, // annulus
+		{40, 60, []float64{10}},
+	} {
+		f := features[i]
+		if f.Properties["threshold"] != test.threshold || f.Properties["upper"] != test.upper || f.Properties["unit"] != "dBZ" {
+			t.Errorf("Isobands(): feature %d: properties %v", i, f.Properties)
+		}
+		if len(f.Polygons) != 1 || len(f.Polygons[0]) != len(test.radii) {
+			t.Fatalf("Isobands(): feature %d: unexpected polygons", i)
+		}
+
+		for k, r := range f.Polygons[0] {
+			if r[0] != r[len(r)-1] {
+				t.Errorf("Isobands(): feature %d: ring %d is not closed", i, k)
+			}
+			if a := lonLatArea(r); (k == 0) != (a > 0) {
+				t.Errorf("Isobands(): feature %d: ring %d has wrong orientation", i, k)
+			}
+
+			// the points are located on the circle around the center
+			for _, p := range r {
+				x, y := c.Project(p[1], p[0])
+				if d := math.Hypot(x-cx, y-cy); math.Abs(d-test.radii[k]) > 0.05 {
+					t.Errorf("Isobands(): feature %d: ring %d: point at distance %.2f; expected: %.2f", i, k, d, test.radii[k])
+					break
+				}
+			}
+		}
+	}
+
+	// GeoJSON round trip
+	var buf bytes.Buffer
+	if err := radolan.WriteGeoJSON(&buf, features); err != nil {
+		t.Fatalf("WriteGeoJSON(): returned error: %#v", err.Error())
+	}
+	read, err := radolan.ReadGeoJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadGeoJSON(): returned error: %#v", err.Error())
+	}
+	if len(read) != 2 || read[1].Properties["threshold"] != 40.0 || len(read[0].Polygons[0]) != 2 {
+		t.Errorf("ReadGeoJSON(): unexpected features: %v", read)
+	}
+}
+
+func TestIsobandsSimplify(t *testing.T) {
+	c := testComposite(cone(450, 450))
+
+	exact, err := Isobands(c, []float64{20}, nil)
+	if err != nil {
+		t.Fatalf("Isobands(): returned error: %#v", err.Error())
+	}
+
+	opts := DefaultOptions
+	opts.Simplify = 0.5
+	simple, err := Isobands(c, []float64{20}, &opts)
+	if err != nil {
+		t.Fatalf("Isobands(): returned error: %#v", err.Error())
+	}
+
+	e, s := exact[0].Polygons[0][0], simple[0].Polygons[0][0]
+	if len(s) >= len(e)/2 {
+		t.Errorf("Isobands(): simplified ring of %d points; expected less than %d", len(s), len(e)/2)
+	}
+	if ae, as := lonLatArea(e), lonLatArea(s); math.Abs(as-ae) > 0.03*ae {
+		t.Errorf("Isobands(): simplified area %v; expected: about %v", as, ae)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// square with collinear points and a small notch
+	r := ring{{0, 0}, {0, 5}, {0, 10}, {5, 10.1}, {10, 10}, {10, 5}, {10, 0}, {5, 0}}
+
+	s := r.simplify(0.5, 1, 1)
+	if len(s) != 4 {
+		t.Errorf("simplify(): %v; expected: 4 corners", s)
+	}
+	if s := r.simplify(0.05, 1, 1); len(s) != 5 {
+		t.Errorf("simplify(): %v; expected: 4 corners and notch", s)
+	}
+	if s := r.simplify(0.5, 0.01, 0.01); s != nil {
+		t.Errorf("simplify(): degenerate ring: %v; expected: nil", s)
+	}
+}
+
+func TestIsobandsErrors(t *testing.T) {
+	c := testComposite(cone(450, 450))
+
+	if _, err := Isobands(c, []float64{40, 20}, nil); err == nil {
+		t.Errorf("Isobands(): descending thresholds: returned no error")
+	}
+	if _, err := Isobands(c, nil, nil); err == nil {
+		t.Errorf("Isobands(): no thresholds: returned no error")
+	}
+	if _, err := Isobands(c, []float64{20}, &Options{Layer: 1}); err == nil {
+		t.Errorf("Isobands(): missing layer: returned no error")
+	}
+	if _, err := Isobands(radolan.NewDummy("RX", 3, 300, 300), []float64{20}, nil); err == nil {
+		t.Errorf("Isobands(): composite without projection: returned no error")
+	}
+}
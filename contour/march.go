@@ -0,0 +1,221 @@
+package contour
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+)
+
+// point is a location in data indices.
+type point struct {
+	x, y float64
+}
+
+// ring is a closed sequence of points. The first point is not repeated.
+type ring []point
+
+// grid holds the values of a composite layer surrounded by a border of
+// missing values, so that all contours are closed. The value (i, j) is
+// located at the center of the pixel (i-1, j-1), i.e. at the data indices
+// (i-0.5, j-0.5).
+type grid struct {
+	w, h   int
+	values []float32
+}
+
+// newGrid returns the grid of the given layer.
+func newGrid(c *radolan.Composite, layer int) *grid {
+	g := &grid{w: c.Dx + 2, h: c.Dy + 2}
+	g.values = make([]float32, g.w*g.h)
+	for i := range g.values {
+		g.values[i] = radolan.NaN
+	}
+	for y := 0; y < c.Dy; y++ {
+		copy(g.values[(y+1)*g.w+1:], c.DataZ[layer][y])
+	}
+	return g
+}
+
+// Each edge between two horizontally or vertically adjacent values is
+// identified by twice the index of its upper left value, plus one for
+// vertical edges.
+func (g *grid) horizontal(i, j int) int { return 2 * (j*g.w + i) }
+func (g *grid) vertical(i, j int) int   { return 2*(j*g.w+i) + 1 }
+
+// rings returns the boundaries of the area with values at or above the
+// threshold by marching squares. The area lies on the left of each ring in
+// map view (north up), so that outer boundaries run counterclockwise and
+// boundaries of holes clockwise. Ambiguous squares (saddles) are resolved by
+// the mean of their four values. Missing values are treated as below any
+// threshold and boundaries towards them run halfway between the pixel
+// centers.
+func (g *grid) rings(threshold float64) []ring {
+	t := float32(threshold)
+	inside := func(v float32) bool {
+		return !radolan.IsNaN(v) && v >= t
+	}
+
+	// following edge of the boundary for each edge (-1 if not crossed)
+	next := make([]int32, 2*g.w*g.h)
+	for e := range next {
+		next[e] = -1
+	}
+
+	for j := 0; j < g.h-1; j++ {
+		for i := 0; i < g.w-1; i++ {
+			// corners and edges of the square in clockwise order (map view),
+			// where edge k connects corner k and k+1
+			corners := [4]int{j*g.w + i, j*g.w + i + 1, (j+1)*g.w + i + 1, (j+1)*g.w + i}
+			edges := [4]int{g.horizontal(i, j), g.vertical(i+1, j), g.horizontal(i, j+1), g.vertical(i, j)}
+
+			var in [4]bool
+			for k, c := range corners {
+				in[k] = inside(g.values[c])
+			}
+
+			// crossed edges and whether they are entered (outside -> inside)
+			var crossed [4]int
+			var entered [4]bool
+			n := 0
+			for k := range edges {
+				if in[k] != in[(k+1)%4] {
+					crossed[n], entered[n] = edges[k], in[(k+1)%4]
+					n++
+				}
+			}
+
+			// connect each entered edge to the following crossed edge, which
+			// cuts off the inside corners. In connected saddles, connect to
+			// the preceding edge to cut off the outside corners instead.
+			connected := n == 4 && inside(g.center(corners))
+			for k := 0; k < n; k++ {
+				if !entered[k] {
+					continue
+				}
+				to := (k + 1) % n
+				if connected {
+					to = (k + n - 1) % n
+				}
+				next[crossed[k]] = int32(crossed[to])
+			}
+		}
+	}
+
+	var rings []ring
+	for start := range next {
+		if next[start] < 0 {
+			continue
+		}
+
+		var r ring
+		for e := start; next[e] >= 0; {
+			r = append(r, g.crossing(e, t))
+			following := next[e]
+			next[e] = -1
+			e = int(following)
+		}
+		rings = append(rings, r)
+	}
+	return rings
+}
+
+// center returns the mean of the values at the corners of a square (NaN if a
+// value is missing).
+func (g *grid) center(corners [4]int) float32 {
+	var sum float32
+	for _, c := range corners {
+		sum += g.values[c]
+	}
+	return sum / 4
+}
+
+// crossing returns the location where the threshold crosses the edge, which
+// is interpolated linearly between the values at both ends.
+func (g *grid) crossing(e int, t float32) point {
+	a := e / 2
+	b := a + 1
+	if e%2 == 1 {
+		b = a + g.w
+	}
+
+	// values equal to the threshold would let several crossings coincide,
+	// so the crossings are kept off the ends of the edge
+	const margin = 1e-3
+
+	f := 0.5
+	va, vb := g.values[a], g.values[b]
+	if !radolan.IsNaN(va) && !radolan.IsNaN(vb) {
+		f = clamp(float64(t-va)/float64(vb-va), margin, 1-margin)
+	}
+
+	p := point{float64(a%g.w) - 0.5, float64(a/g.w) - 0.5}
+	if e%2 == 0 {
+		p.x += f
+	} else {
+		p.y += f
+	}
+	return p
+}
+
+// area returns the signed area of the ring in square pixels, which is
+// positive for counterclockwise rings in map view.
+func (r ring) area() float64 {
+	var a float64
+	for k, p := range r {
+		q := r[(k+1)%len(r)]
+		a += p.x*q.y - q.x*p.y
+	}
+	return -a / 2 // the y axis points south
+}
+
+// contains reports whether the point lies within the ring (even-odd rule).
+func (r ring) contains(p point) bool {
+	in := false
+	for k, a := range r {
+		b := r[(k+len(r)-1)%len(r)]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			in = !in
+		}
+	}
+	return in
+}
+
+// reverse returns the ring in opposite direction.
+func (r ring) reverse() ring {
+	rev := make(ring, len(r))
+	for k, p := range r {
+		rev[len(r)-1-k] = p
+	}
+	return rev
+}
+
+// bounds returns the bounding box of the ring.
+func (r ring) bounds() (min, max point) {
+	min = point{math.Inf(1), math.Inf(1)}
+	max = point{math.Inf(-1), math.Inf(-1)}
+	for _, p := range r {
+		min.x, min.y = math.Min(min.x, p.x), math.Min(min.y, p.y)
+		max.x, max.y = math.Max(max.x, p.x), math.Max(max.y, p.y)
+	}
+	return
+}
+
+// inner returns a point close to the longest segment of the ring on its
+// right side, which lies within holes (clockwise rings).
+func (r ring) inner() point {
+	var a, b point
+	longest := -1.0
+	for k, p := range r {
+		q := r[(k+1)%len(r)]
+		if d := math.Hypot(q.x-p.x, q.y-p.y); d > longest {
+			longest, a, b = d, p, q
+		}
+	}
+
+	const eps = 1e-6
+	dx, dy := (b.x-a.x)/longest, (b.y-a.y)/longest
+	return point{(a.x+b.x)/2 - dy*eps, (a.y+b.y)/2 + dx*eps}
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
@@ -0,0 +1,165 @@
+package contour
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testComposite returns a national composite (1 km resolution) holding the
+// values of fn at the pixel centers.
+func testComposite(fn func(x, y float64) float32) *radolan.Composite {
+	c := radolan.NewDummy("RX", 4, 900, 900)
+	c.DataUnit = radolan.Unit_dBZ
+	c.Dz = 1
+
+	data := make([][]float32, c.Dy)
+	for y := range data {
+		data[y] = make([]float32, c.Dx)
+		for x := range data[y] {
+			data[y][x] = fn(float64(x)+0.5, float64(y)+0.5)
+		}
+	}
+	c.DataZ = [][][]float32{data}
+	c.Data = data
+	return c
+}
+
+// cone returns a function decreasing by 1 per pixel from 50 at (cx, cy).
+func cone(cx, cy float64) func(x, y float64) float32 {
+	return func(x, y float64) float32 {
+		return float32(50 - math.Hypot(x-cx, y-cy))
+	}
+}
+
+func TestRings(t *testing.T) {
+	g := newGrid(testComposite(cone(300.3, 400.7)), 0)
+
+	rings := g.rings(40)
+	if len(rings) != 1 {
+		t.Fatalf("rings(): %d rings; expected: 1", len(rings))
+	}
+	if a := rings[0].area(); math.Abs(a-100*math.Pi) > 2 {
+		t.Errorf("rings(): area %.2f; expected: %.2f", a, 100*math.Pi)
+	}
+	for _, p := range rings[0] {
+		if r := math.Hypot(p.x-300.3, p.y-400.7); math.Abs(r-10) > 0.05 {
+			t.Errorf("rings(): point (%.2f, %.2f) at distance %.2f; expected: 10", p.x, p.y, r)
+			break
+		}
+	}
+
+	if rings := g.rings(60); len(rings) != 0 {
+		t.Errorf("rings(): %d rings above the maximum; expected: 0", len(rings))
+	}
+}
+
+func TestRingsHole(t *testing.T) {
+	annulus := func(x, y float64) float32 {
+		if d := math.Hypot(x-450, y-450); d >= 5 && d <= 15 {
+			return 50
+		}
+		return 0
+	}
+	rings := newGrid(testComposite(annulus), 0).rings(40)
+
+	var outer, holes int
+	for _, r := range rings {
+		if r.area() > 0 {
+			outer++
+		} else {
+			holes++
+		}
+	}
+	if outer != 1 || holes != 1 {
+		t.Errorf("rings(): %d outer rings and %d holes; expected: 1 and 1", outer, holes)
+	}
+}
+
+func TestRingsMissing(t *testing.T) {
+	// block of 20x20 pixels surrounded by missing values with a missing pixel
+	block := func(x, y float64) float32 {
+		if x > 100 && x < 120 && y > 200 && y < 220 && (x != 110.5 || y != 210.5) {
+			return 50
+		}
+		return radolan.NaN
+	}
+	rings := newGrid(testComposite(block), 0).rings(40)
+	if len(rings) != 2 {
+		t.Fatalf("rings(): %d rings; expected: 2", len(rings))
+	}
+
+	// boundaries run halfway between the pixel centers, cutting the corners
+	for i, expected := range []float64{399.5, -0.5} {
+		if a := rings[i].area(); math.Abs(a-expected) > 1e-9 {
+			t.Errorf("rings(): ring %d: area %v; expected: %v", i, a, expected)
+		}
+	}
+
+	// the composite is closed at its edges
+	full := newGrid(testComposite(func(x, y float64) float32 { return 50 }), 0).rings(40)
+	if len(full) != 1 || full[0].area() != 900*900-0.5 {
+		t.Errorf("rings(): composite covered completely: %d rings", len(full))
+	}
+}
+
+func TestBands(t *testing.T) {
+	// noise leads to many saddles and nested rings
+	rnd := rand.New(rand.NewSource(1))
+	g := &grid{w: 62, h: 62, values: make([]float32, 62*62)}
+	for i := range g.values {
+		g.values[i] = float32(rnd.Intn(60))
+		if i%g.w == 0 || i%g.w == g.w-1 || i/g.w == 0 || i/g.w == g.h-1 || rnd.Intn(50) == 0 {
+			g.values[i] = radolan.NaN
+		}
+	}
+
+	thresholds := []float64{10, 25, 40}
+	bands := bands(g, thresholds)
+
+	var total float64
+	for _, r := range g.rings(thresholds[0]) {
+		total += r.area()
+	}
+
+	var sum float64
+	var rings int
+	for i, band := range bands {
+		for _, p := range band {
+			sum += p.outer.area()
+			for _, h := range p.holes {
+				sum += h.area()
+
+				// boundaries towards missing values are shared, so that
+				// only most points of the hole lie within the polygon
+				inside := 0
+				for _, q := range h {
+					if p.outer.contains(q) {
+						inside++
+					}
+				}
+				if 2*inside < len(h) || -h.area() >= p.outer.area() {
+					t.Errorf("bands(): band %d: hole at (%v, %v) outside of polygon", i, h[0].x, h[0].y)
+				}
+			}
+			rings += 1 + len(p.holes)
+		}
+	}
+
+	// all rings are assigned and the areas of all bands add up
+	expected := 0
+	for i, threshold := range thresholds {
+		n := len(g.rings(threshold))
+		expected += n
+		if i > 0 {
+			expected += n
+		}
+	}
+	if rings != expected {
+		t.Errorf("bands(): %d rings; expected: %d", rings, expected)
+	}
+	if math.Abs(sum-total) > 1e-6 {
+		t.Errorf("bands(): total area %v; expected: %v", sum, total)
+	}
+}
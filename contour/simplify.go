@@ -0,0 +1,77 @@
+package contour
+
+import (
+	"math"
+)
+
+// simplify returns the ring simplified by the Douglas-Peucker algorithm, so
+// that no removed point deviates more than the tolerance from the result. The
+// tolerance and the pixel dimensions rx and ry are given in km. nil is
+// returned if the ring degenerates.
+func (r ring) simplify(tolerance, rx, ry float64) ring {
+	if tolerance <= 0 || len(r) <= 4 {
+		return r
+	}
+
+	// points in km
+	km := make([]point, len(r))
+	for k, p := range r {
+		km[k] = point{p.x * rx, p.y * ry}
+	}
+
+	// split the ring at the point farthest from the first point
+	far := 0
+	for k, p := range km {
+		if math.Hypot(p.x-km[0].x, p.y-km[0].y) > math.Hypot(km[far].x-km[0].x, km[far].y-km[0].y) {
+			far = k
+		}
+	}
+
+	keep := make([]bool, len(r))
+	keep[0], keep[far] = true, true
+
+	// mark the points to keep between first and last (index len(r) denotes
+	// the first point)
+	var douglasPeucker func(first, last int)
+	douglasPeucker = func(first, last int) {
+		a, b := km[first], km[last%len(km)]
+		max, index := 0.0, -1
+		for k := first + 1; k < last; k++ {
+			if d := segmentDistance(km[k], a, b); d > max {
+				max, index = d, k
+			}
+		}
+		if max > tolerance {
+			keep[index] = true
+			douglasPeucker(first, index)
+			douglasPeucker(index, last)
+		}
+	}
+	douglasPeucker(0, far)
+	douglasPeucker(far, len(r))
+
+	var s ring
+	for k, p := range r {
+		if keep[k] {
+			s = append(s, p)
+		}
+	}
+
+	// the orientation must be retained
+	if len(s) < 3 || s.area()*r.area() <= 0 {
+		return nil
+	}
+	return s
+}
+
+// segmentDistance returns the distance of p to the segment from a to b.
+func segmentDistance(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/l))
+	return math.Hypot(p.x-a.x-t*dx, p.y-a.y-t*dy)
+}
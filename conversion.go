@@ -61,8 +61,26 @@ func toRVP6(dBZ float32) float32 {
 	return (dBZ + 32.5) * 2
 }
 
+// toLinearZ converts the given radar reflectivity factor (dBZ) to its linear
+// representation Z (mm^6/m^3).
+func toLinearZ(dBZ float32) float64 {
+	return math.Pow(10, float64(dBZ)/10)
+}
+
+// fromLinearZ converts the given linear reflectivity factor Z (mm^6/m^3) to its
+// logarithmic representation (dBZ).
+func fromLinearZ(z float64) float32 {
+	return float32(10 * math.Log10(z))
+}
+
 // rvp6Raw converts the raw value to radar video processor values (rvp-6) by applying the
 // products precision field.
 func (c *Composite) rvp6Raw(value int) float32 {
 	return float32(value) * float32(math.Pow10(c.precision))
 }
+
+// rawRVP6 converts the radar video processor value (rvp-6) to the nearest raw value
+// by applying the products precision field. This is the inverse of rvp6Raw.
+func (c *Composite) rawRVP6(rvp6 float32) int {
+	return int(math.Round(float64(rvp6) / math.Pow10(c.precision)))
+}
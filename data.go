@@ -2,6 +2,7 @@ package radolan
 
 import (
 	"bufio"
+	"bytes"
 )
 
 // encoding types of the composite
@@ -14,15 +15,29 @@ const (
 	unknown
 )
 
-// parsing methods
-var parse = [4]func(c *Composite, rd *bufio.Reader) error{}
+// row parsing methods
+var parseRow = [4]func(c *Composite, rd *bufio.Reader, line []byte, dst []float32, flags []PixelFlag) error{}
 
-// init maps the parsing methods to the encoding type
+// bytes per value in the line buffer of each encoding type
+var lineWidth = [4]int{runlength: 0, littleEndian: 2, singleByte: 1, unknown: 0}
+
+// rows of the encoding type are stored bottom up
+var flipped = [4]bool{runlength: false, littleEndian: true, singleByte: true, unknown: false}
+
+// encoding methods
+var encode = [4]func(c *Composite, w *bytes.Buffer) error{}
+
+// init maps the parsing and encoding methods to the encoding type
 func init() {
-	parse[runlength] = (*Composite).parseRunlength
-	parse[littleEndian] = (*Composite).parseLittleEndian
-	parse[singleByte] = (*Composite).parseSingleByte
-	parse[unknown] = (*Composite).parseUnknown
+	parseRow[runlength] = (*Composite).parseRowRunlength
+	parseRow[littleEndian] = (*Composite).parseRowLittleEndian
+	parseRow[singleByte] = (*Composite).parseRowSingleByte
+	parseRow[unknown] = (*Composite).parseRowUnknown
+
+	encode[runlength] = (*Composite).encodeRunlength
+	encode[littleEndian] = (*Composite).encodeLittleEndian
+	encode[singleByte] = (*Composite).encodeSingleByte
+	encode[unknown] = (*Composite).encodeLittleEndian
 }
 
 // identifyEncoing identifies the encoding type of the data section by
@@ -44,20 +59,87 @@ func (c *Composite) identifyEncoding() encoding {
 	return unknown
 }
 
-// parseData parses the composite data and writes the related fields.
-// This method requires header data to be already written.
-func (c *Composite) parseData(reader *bufio.Reader) error {
+// parseData parses the composite data using the given decoder and writes the
+// related fields. The rows are decoded in place.
+func (c *Composite) parseData(dec *Decoder) error {
 	if c.Px == 0 || c.Py == 0 {
 		return newError("parseData", "parsed header data required")
 	}
 
 	// create Data fields
+	c.allocData(dec.enc == littleEndian)
+
+	for y := dec.next(); y != -1; y = dec.next() {
+		var flags []PixelFlag
+		if c.Flags != nil {
+			flags = c.Flags[y]
+		}
+
+		if err := dec.readRow(c.PlainData[y], flags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allocData creates the contiguous Raw field and the PlainData rows as views
+// into it. When withFlags is set, the Flags field is created likewise.
+func (c *Composite) allocData(withFlags bool) {
+	c.Raw = make([]float32, c.Px*c.Py)
 	c.PlainData = make([][]float32, c.Py)
 	for i := range c.PlainData {
-		c.PlainData[i] = make([]float32, c.Px)
+		c.PlainData[i] = c.Raw[c.Px*i : c.Px*(i+1) : c.Px*(i+1)]
+	}
+
+	c.Flags = nil
+	if withFlags {
+		flags := make([]PixelFlag, c.Px*c.Py)
+		c.Flags = make([][]PixelFlag, c.Py)
+		for i := range c.Flags {
+			c.Flags[i] = flags[c.Px*i : c.Px*(i+1) : c.Px*(i+1)]
+		}
 	}
+}
+
+// emptyLike returns a composite with the grid, projection and times of c. Its
+// data fields hold c.Dz layers filled with NaN and no elevation rows.
+func (c *Composite) emptyLike() *Composite {
+	comp := &Composite{
+		Product:       c.Product,
+		CaptureTime:   c.CaptureTime,
+		ForecastTime:  c.ForecastTime,
+		Interval:      c.Interval,
+		DataUnit:      c.DataUnit,
+		Px:            c.Dx,
+		Py:            c.Dy * c.Dz,
+		Dx:            c.Dx,
+		Dy:            c.Dy,
+		Rx:            c.Rx,
+		Ry:            c.Ry,
+		HasProjection: c.HasProjection,
+		Format:        c.Format,
+		precision:     c.precision,
+		offx:          c.offx,
+		offy:          c.offy,
+		proj_wgs84:    c.proj_wgs84,
+	}
+
+	comp.allocData(false)
+	for i := range comp.Raw {
+		comp.Raw[i] = NaN
+	}
+	comp.arrangeData()
+
+	return comp
+}
 
-	return parse[c.identifyEncoding()](c, reader)
+// layerRow returns the plain data row index of the first row in layer z.
+func (c *Composite) layerRow(z int) int {
+	if c.Py%c.Dy == 0 { // multiple layers are linked downwards
+		return c.Dy * z
+	}
+	return c.Py - c.Dy // strip elevation
 }
 
 // arrangeData slices plain data into its data layers or strips preceeding
@@ -65,18 +147,31 @@ func (c *Composite) parseData(reader *bufio.Reader) error {
 func (c *Composite) arrangeData() {
 	if c.Py%c.Dy == 0 { // multiple layers are linked downwards
 		c.DataZ = make([][][]float32, c.Py/c.Dy)
-		for i := range c.DataZ {
-			c.DataZ[i] = c.PlainData[c.Dy*i : c.Dy*(i+1)] // split layers
-		}
 	} else { // only use bottom most part of plain data
-		c.DataZ = [][][]float32{c.PlainData[c.Py-c.Dy:]} // strip elevation
+		c.DataZ = make([][][]float32, 1)
+	}
+
+	for i := range c.DataZ {
+		row := c.layerRow(i)
+		c.DataZ[i] = c.PlainData[row : row+c.Dy] // split layers or strip elevation
 	}
 
 	c.Dz = len(c.DataZ)
 	c.Data = c.DataZ[0] // alias
 }
 
-// parseUnknown performs no action and always returns an error.
-func (c *Composite) parseUnknown(rd *bufio.Reader) error {
+// parseRowUnknown performs no action and always returns an error.
+func (c *Composite) parseRowUnknown(rd *bufio.Reader, line []byte, dst []float32, flags []PixelFlag) error {
 	return newError("parseUnknown", "unknown encoding")
 }
+
+// encodeData encodes the PlainData field of the composite and writes the binary
+// section to w. The encoding of the parsed source is retained. Composites that do
+// not originate from a parsed file are encoded in little endian format.
+func (c *Composite) encodeData(w *bytes.Buffer) error {
+	if c.Px == 0 || c.Py == 0 || len(c.PlainData) != c.Py {
+		return newError("encodeData", "plain data required")
+	}
+
+	return encode[c.identifyEncoding()](c, w)
+}
@@ -0,0 +1,134 @@
+package radolan
+
+import (
+	"bufio"
+	"io"
+)
+
+// A Decoder reads a composite row by row. The header is parsed on creation,
+// whereas the data section is decoded on demand. Consumers which only need a
+// part of the composite can stop reading early.
+//
+// The rows are returned in the order in which they are stored. Depending on
+// the encoding this is either top down or bottom up. The plain data index of
+// the last returned row is available by Y():
+//
+//	dec, err := radolan.NewDecoder(rd)
+//	// if err == nil
+//	for {
+//		row, err := dec.NextRow()
+//		if err == io.EOF {
+//			break
+//		}
+//		// if err == nil
+//		fmt.Println(dec.Y(), row[0]) // row is only valid until next call
+//	}
+type Decoder struct {
+	comp *Composite
+	rd   *bufio.Reader
+	enc  encoding
+
+	n int // number of rows read
+	y int // plain data index of last row
+
+	line  []byte      // reusable line buffer
+	row   []float32   // reusable row buffer
+	flags []PixelFlag // reusable flag buffer
+}
+
+// NewDecoder reads and parses the composite header from rd and returns a
+// decoder for the data section. An error is returned on failure. When
+// ErrUnknownUnit is returned, the decoder is still valid, but the data values
+// can be incorrect due to unit dependent conversions during parsing.
+func NewDecoder(rd io.Reader) (dec *Decoder, err error) {
+	comp := &Composite{}
+	reader := bufio.NewReader(rd)
+
+	if err = comp.parseHeader(reader); err != nil {
+		return
+	}
+
+	if comp.Px == 0 || comp.Py == 0 {
+		err = newError("NewDecoder", "parsed header data required")
+		return
+	}
+
+	dec = &Decoder{comp: comp, rd: reader, enc: comp.identifyEncoding(), y: -1}
+	dec.line = make([]byte, comp.Px*lineWidth[dec.enc])
+
+	comp.calibrateProjection()
+
+	if comp.DataUnit == Unit_unknown {
+		err = ErrUnknownUnit
+	}
+	return
+}
+
+// Composite returns the composite described by the parsed header. Its data
+// fields are not populated by the decoder.
+func (d *Decoder) Composite() *Composite {
+	return d.comp
+}
+
+// NextRow decodes the next row of the plain data. The returned slice is
+// reused and only valid until the next call. io.EOF is returned when all rows
+// have been read.
+func (d *Decoder) NextRow() ([]float32, error) {
+	if d.next() == -1 {
+		return nil, io.EOF
+	}
+
+	if d.row == nil {
+		d.row = make([]float32, d.comp.Px)
+		if d.enc == littleEndian {
+			d.flags = make([]PixelFlag, d.comp.Px)
+		}
+	}
+
+	if err := d.readRow(d.row, d.flags); err != nil {
+		return nil, err
+	}
+	return d.row, nil
+}
+
+// Y returns the plain data index [y] of the row last returned by NextRow.
+// -1 is returned if no row has been read yet.
+func (d *Decoder) Y() int {
+	return d.y
+}
+
+// Flags returns the pixel flags of the row last returned by NextRow. The
+// returned slice is reused and only valid until the next call of NextRow. nil
+// is returned for products which are not little endian encoded.
+func (d *Decoder) Flags() []PixelFlag {
+	return d.flags
+}
+
+// next returns the plain data index of the next row or -1 if all rows have
+// been read.
+func (d *Decoder) next() int {
+	if d.n >= d.comp.Py {
+		return -1
+	}
+	if flipped[d.enc] {
+		return d.comp.Py - 1 - d.n
+	}
+	return d.n
+}
+
+// readRow decodes the next row into the given destinations. The flags are only
+// required for little endian encoded products.
+func (d *Decoder) readRow(dst []float32, flags []PixelFlag) error {
+	y := d.next()
+	if y == -1 {
+		return io.EOF
+	}
+
+	if err := parseRow[d.enc](d.comp, d.rd, d.line, dst, flags); err != nil {
+		return err
+	}
+
+	d.n++
+	d.y = y
+	return nil
+}
@@ -0,0 +1,76 @@
+package radolan
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	testcases := []*Composite{
+		newTestComposite("PG", 0, 460, 460, 0, []float32{1.0, 19.0, 28.0}, func(c *Composite, x, y int) float32 {
+			if x < y {
+				return NaN
+			}
+			return c.level[(x+y)%len(c.level)]
+		}),
+		newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+			if x == y {
+				return NaN
+			}
+			return c.rvp6Raw(x + y)
+		}),
+	}
+
+	for _, exp := range testcases {
+		var buf bytes.Buffer
+		if err := exp.Encode(&buf); err != nil {
+			t.Fatalf("%s.Encode(): returned error: %#v", exp.Product, err.Error())
+		}
+
+		dec, err := NewDecoder(&buf)
+		if err != nil {
+			t.Fatalf("NewDecoder(%s): returned error: %#v", exp.Product, err.Error())
+		}
+
+		if c := dec.Composite(); c.Product != exp.Product || c.Dx != exp.Dx || c.Dy != exp.Dy || c.PlainData != nil {
+			t.Errorf("NewDecoder(%s).Composite(): unexpected header fields", exp.Product)
+		}
+		if dec.Y() != -1 {
+			t.Errorf("NewDecoder(%s).Y() = %d; expected: -1", exp.Product, dec.Y())
+		}
+
+		seen := make([]bool, exp.Py)
+		for {
+			row, err := dec.NextRow()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s.NextRow(): returned error: %#v", exp.Product, err.Error())
+			}
+
+			y := dec.Y()
+			if y < 0 || y >= exp.Py || seen[y] {
+				t.Fatalf("%s.Y() = %d: invalid or repeated row", exp.Product, y)
+			}
+			seen[y] = true
+
+			for x, e := range exp.PlainData[y] {
+				if v := row[x]; v != e && !(IsNaN(v) && IsNaN(e)) {
+					t.Fatalf("%s.NextRow(): [%d][%d] = %#v; expected: %#v", exp.Product, y, x, v, e)
+				}
+			}
+
+			if flags := dec.Flags(); flags != nil && IsNaN(exp.PlainData[y][0]) != flags[0].Has(NoData) {
+				t.Errorf("%s.Flags(): [%d][0] = %#v; value: %#v", exp.Product, y, flags[0], exp.PlainData[y][0])
+			}
+		}
+
+		for y := range seen {
+			if !seen[y] {
+				t.Errorf("%s.NextRow(): row %d missing", exp.Product, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package radolan
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filename contains the fields encoded in the name of a file provided by the
+// DWD.
+type Filename struct {
+	Product string        // product label - Example: "RW"
+	Time    time.Time     // timestamp in UTC
+	Lead    time.Duration // forecast lead time (RADVOR products)
+	Grid    string        // grid label - Example: "DE1200" (empty if not given)
+	WMO     string        // WMO number - Example: "10000" (empty if not given)
+}
+
+var (
+	// raa01-rw_10000-1607311850-dwd---bin, raa01-yw2017.002_10000-1001010050-dwd---bin.gz
+	dwdFilename = regexp.MustCompile(`^ra[a-z]\d\d-([a-z][a-z0-9])[a-z0-9.]*_(\d+)-(\d{10})-dwd---bin\b`)
+
+	// DE1200_RV2307141205_000, FX1607311850_010_MF002, WN2307141205.tar.bz2
+	radvorFilename = regexp.MustCompile(`^(?:([A-Z][A-Z0-9]*)_)?([A-Z][A-Z0-9])(\d{10})(?:_(\d{3}))?(?:[_.-]|$)`)
+)
+
+// ParseFilename extracts the product label, timestamp, lead time and grid from
+// the base name of the given path. The following naming schemes are supported,
+// optionally followed by a file extension:
+//
+//	raa01-rw_10000-1607311850-dwd---bin	// composite products
+//	DE1200_RV2307141205_000			// RADVOR products with grid and lead time
+//	FX1607311850_010_MF002			// RADVOR products with lead time
+//	WN2307141205.tar.bz2			// RADVOR archives
+//
+// An error is returned if the name does not follow these schemes.
+func ParseFilename(path string) (Filename, error) {
+	name := filepath.Base(path)
+
+	if m := dwdFilename.FindStringSubmatch(name); m != nil {
+		t, err := time.Parse("0601021504", m[3])
+		if err != nil {
+			return Filename{}, newError("ParseFilename", "invalid timestamp: "+err.Error())
+		}
+		return Filename{Product: strings.ToUpper(m[1]), Time: t, WMO: m[2]}, nil
+	}
+
+	if m := radvorFilename.FindStringSubmatch(name); m != nil {
+		t, err := time.Parse("0601021504", m[3])
+		if err != nil {
+			return Filename{}, newError("ParseFilename", "invalid timestamp: "+err.Error())
+		}
+
+		f := Filename{Product: m[2], Time: t, Grid: m[1]}
+		if m[4] != "" {
+			lead, _ := strconv.Atoi(m[4]) // matched digits
+			f.Lead = time.Duration(lead) * time.Minute
+		}
+		return f, nil
+	}
+
+	return Filename{}, newError("ParseFilename", "unknown naming scheme: "+name)
+}
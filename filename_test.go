@@ -0,0 +1,44 @@
+package radolan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilename(t *testing.T) {
+	testcases := []struct {
+		name string
+		exp  Filename
+	}{
+		{"raa01-rw_10000-1607311850-dwd---bin", Filename{
+			Product: "RW", Time: time.Date(2016, time.July, 31, 18, 50, 0, 0, time.UTC), WMO: "10000"}},
+		{"/data/raa01-yw2017.002_10000-1001010050-dwd---bin.gz", Filename{
+			Product: "YW", Time: time.Date(2010, time.January, 1, 0, 50, 0, 0, time.UTC), WMO: "10000"}},
+		{"DE1200_RV2307141205_000", Filename{
+			Product: "RV", Time: time.Date(2023, time.July, 14, 12, 5, 0, 0, time.UTC), Grid: "DE1200"}},
+		{"DE1200_RV2307141205_120.bz2", Filename{
+			Product: "RV", Time: time.Date(2023, time.July, 14, 12, 5, 0, 0, time.UTC), Grid: "DE1200",
+			Lead: 2 * time.Hour}},
+		{"FX1607311850_010_MF002", Filename{
+			Product: "FX", Time: time.Date(2016, time.July, 31, 18, 50, 0, 0, time.UTC), Lead: 10 * time.Minute}},
+		{"WN2307141205.tar.bz2", Filename{
+			Product: "WN", Time: time.Date(2023, time.July, 14, 12, 5, 0, 0, time.UTC)}},
+	}
+
+	for _, test := range testcases {
+		f, err := ParseFilename(test.name)
+		if err != nil {
+			t.Errorf("ParseFilename(%#v): returned error: %#v", test.name, err.Error())
+			continue
+		}
+		if f != test.exp {
+			t.Errorf("ParseFilename(%#v) = %#v; expected: %#v", test.name, f, test.exp)
+		}
+	}
+
+	for _, name := range []string{"README.md", "raa01-rw_10000-1613311850-dwd---bin", "RW2307141205x"} {
+		if _, err := ParseFilename(name); err == nil {
+			t.Errorf("ParseFilename(%#v): expected error", name)
+		}
+	}
+}
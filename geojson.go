@@ -0,0 +1,196 @@
+package radolan
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Polygon is an area in geographical coordinates. The first ring is the outer
+// boundary and following rings are holes. Each point is given as
+// [longitude, latitude] like in GeoJSON.
+type Polygon [][][2]float64
+
+// Line is a path through points in geographical coordinates. Each point is
+// given as [longitude, latitude] like in GeoJSON.
+type Line [][2]float64
+
+// Feature is a geographical object like a river catchment, a municipality or
+// a weather station. Areas consist of one or more polygons, which are used by
+// functions like Rasterize, whereas points and lines are only read and
+// written.
+type Feature struct {
+	Properties map[string]interface{}
+	Polygons   []Polygon
+	Points     [][2]float64 // points as [longitude, latitude]
+	Lines      []Line
+}
+
+// geojson object of any type
+type geojsonObject struct {
+	Type        string                 `json:"type"`
+	Features    []geojsonObject        `json:"features"`
+	Geometry    *geojsonObject         `json:"geometry"`
+	Geometries  []geojsonObject        `json:"geometries"`
+	Properties  map[string]interface{} `json:"properties"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+}
+
+// ReadGeoJSON reads a GeoJSON FeatureCollection, Feature or geometry from rd
+// and returns the contained features. The polygons, points and lines of all
+// geometry types including GeometryCollection are collected in the fields of
+// the feature. A bare geometry is returned as feature without properties.
+func ReadGeoJSON(rd io.Reader) ([]Feature, error) {
+	var obj geojsonObject
+	if err := json.NewDecoder(rd).Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	var features []Feature
+	switch obj.Type {
+	case "FeatureCollection":
+		for _, f := range obj.Features {
+			feature, err := f.feature()
+			if err != nil {
+				return nil, err
+			}
+			features = append(features, feature)
+		}
+	case "Feature":
+		feature, err := obj.feature()
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	default:
+		var feature Feature
+		if err := obj.geometry(&feature); err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	}
+
+	return features, nil
+}
+
+// WriteGeoJSON writes the features as GeoJSON FeatureCollection to w. The
+// polygons of a feature are written as MultiPolygon, a single point or line as
+// Point or LineString and several as MultiPoint or MultiLineString. Features
+// holding more than one kind of geometry are written as GeometryCollection and
+// features without geometry with a null geometry. It complements ReadGeoJSON.
+func WriteGeoJSON(w io.Writer, features []Feature) error {
+	type feature struct {
+		Type       string                 `json:"type"`
+		Geometry   *geojsonGeometry       `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+
+	fc := struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}{"FeatureCollection", make([]feature, len(features))}
+
+	for i, f := range features {
+		fc.Features[i] = feature{Type: "Feature", Geometry: f.geometry(), Properties: f.Properties}
+	}
+
+	return json.NewEncoder(w).Encode(fc)
+}
+
+// geojson geometry written by WriteGeoJSON
+type geojsonGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates interface{}       `json:"coordinates,omitempty"`
+	Geometries  []geojsonGeometry `json:"geometries,omitempty"`
+}
+
+// geometry returns the GeoJSON geometry of the feature or nil if it holds no
+// geometry.
+func (f Feature) geometry() *geojsonGeometry {
+	var parts []geojsonGeometry
+	if len(f.Polygons) != 0 {
+		parts = append(parts, geojsonGeometry{Type: "MultiPolygon", Coordinates: f.Polygons})
+	}
+	switch {
+	case len(f.Points) == 1:
+		parts = append(parts, geojsonGeometry{Type: "Point", Coordinates: f.Points[0]})
+	case len(f.Points) > 1:
+		parts = append(parts, geojsonGeometry{Type: "MultiPoint", Coordinates: f.Points})
+	}
+	switch {
+	case len(f.Lines) == 1:
+		parts = append(parts, geojsonGeometry{Type: "LineString", Coordinates: f.Lines[0]})
+	case len(f.Lines) > 1:
+		parts = append(parts, geojsonGeometry{Type: "MultiLineString", Coordinates: f.Lines})
+	}
+
+	switch len(parts) {
+	case 0:
+		return nil
+	case 1:
+		return &parts[0]
+	}
+	return &geojsonGeometry{Type: "GeometryCollection", Geometries: parts}
+}
+
+// feature converts the GeoJSON feature object.
+func (obj *geojsonObject) feature() (Feature, error) {
+	if obj.Type != "Feature" {
+		return Feature{}, newError("ReadGeoJSON", "unexpected object: "+obj.Type)
+	}
+
+	f := Feature{Properties: obj.Properties}
+	if obj.Geometry == nil { // unlocated feature
+		return f, nil
+	}
+
+	err := obj.Geometry.geometry(&f)
+	return f, err
+}
+
+// geometry adds the polygons, points and lines of the GeoJSON geometry object
+// to the feature.
+func (obj *geojsonObject) geometry(f *Feature) error {
+	var err error
+	switch obj.Type {
+	case "Polygon":
+		var p Polygon
+		err = json.Unmarshal(obj.Coordinates, &p)
+		f.Polygons = append(f.Polygons, p)
+
+	case "MultiPolygon":
+		var ps []Polygon
+		err = json.Unmarshal(obj.Coordinates, &ps)
+		f.Polygons = append(f.Polygons, ps...)
+
+	case "Point":
+		var p [2]float64
+		err = json.Unmarshal(obj.Coordinates, &p)
+		f.Points = append(f.Points, p)
+
+	case "MultiPoint":
+		var ps [][2]float64
+		err = json.Unmarshal(obj.Coordinates, &ps)
+		f.Points = append(f.Points, ps...)
+
+	case "LineString":
+		var l Line
+		err = json.Unmarshal(obj.Coordinates, &l)
+		f.Lines = append(f.Lines, l)
+
+	case "MultiLineString":
+		var ls []Line
+		err = json.Unmarshal(obj.Coordinates, &ls)
+		f.Lines = append(f.Lines, ls...)
+
+	case "GeometryCollection":
+		for _, g := range obj.Geometries {
+			if err := g.geometry(f); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return newError("ReadGeoJSON", "unknown geometry: "+obj.Type)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package radolan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteGeoJSON(t *testing.T) {
+	c := NewDummy("RW", 3, 900, 900)
+	features := []Feature{
+		{Properties: map[string]interface{}{"name": "area"}, Polygons: []Polygon{{rectangleRing(c, 100, 100, 110, 110)}}},
+		{Properties: map[string]interface{}{"name": "unlocated"}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGeoJSON(&buf, features); err != nil {
+		t.Fatalf("WriteGeoJSON(): returned error: %#v", err.Error())
+	}
+	if !strings.Contains(buf.String(), `"type":"MultiPolygon"`) || !strings.Contains(buf.String(), `"geometry":null`) {
+		t.Errorf("WriteGeoJSON(): unexpected output: %s", buf.String())
+	}
+
+	read, err := ReadGeoJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadGeoJSON(): returned error: %#v", err.Error())
+	}
+	if len(read) != 2 || read[0].Properties["name"] != "area" || read[1].Polygons != nil ||
+		len(read[0].Polygons) != 1 || read[0].Polygons[0][0][2] != features[0].Polygons[0][0][2] {
+		t.Errorf("ReadGeoJSON(): unexpected features: %#v", read)
+	}
+
+	// points and lines
+	station := [2]float64{13.4, 52.5}
+	line := Line{{8.7, 50.1}, {9.2, 50.3}, {9.9, 50.4}}
+	features = []Feature{
+		{Points: [][2]float64{station}},
+		{Points: [][2]float64{station, line[0]}, Lines: []Line{line, line[1:]}},
+	}
+
+	buf.Reset()
+	if err := WriteGeoJSON(&buf, features); err != nil {
+		t.Fatalf("WriteGeoJSON(): returned error: %#v", err.Error())
+	}
+	for _, geometry := range []string{`"Point"`, `"GeometryCollection"`, `"MultiPoint"`, `"MultiLineString"`} {
+		if !strings.Contains(buf.String(), `"type":`+geometry) {
+			t.Errorf("WriteGeoJSON(): %s missing: %s", geometry, buf.String())
+		}
+	}
+
+	read, err = ReadGeoJSON(&buf)
+	if err != nil {
+		t.Fatalf("ReadGeoJSON(): returned error: %#v", err.Error())
+	}
+	if len(read) != 2 || len(read[0].Points) != 1 || read[0].Points[0] != station || read[0].Polygons != nil ||
+		len(read[1].Points) != 2 || len(read[1].Lines) != 2 || len(read[1].Lines[0]) != 3 || read[1].Lines[1][0] != line[1] {
+		t.Errorf("ReadGeoJSON(): unexpected features: %#v", read)
+	}
+}
@@ -0,0 +1,286 @@
+package radolan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// tiff field types
+const (
+	typeASCII  = 2
+	typeShort  = 3
+	typeLong   = 4
+	typeDouble = 12
+)
+
+// tiff and geotiff tags
+const (
+	tagImageWidth                = 256
+	tagImageLength               = 257
+	tagBitsPerSample             = 258
+	tagCompression               = 259
+	tagPhotometricInterpretation = 262
+	tagStripOffsets              = 273
+	tagSamplesPerPixel           = 277
+	tagRowsPerStrip              = 278
+	tagStripByteCounts           = 279
+	tagPlanarConfiguration       = 284
+	tagSampleFormat              = 339
+	tagModelPixelScale           = 33550
+	tagModelTiepoint             = 33922
+	tagGeoKeyDirectory           = 34735
+	tagGeoDoubleParams           = 34736
+	tagGeoASCIIParams            = 34737
+	tagGDALNoData                = 42113
+)
+
+// geotiff keys and values as described in the GeoTIFF specification 1.0
+const (
+	keyGTModelType              = 1024
+	keyGTRasterType             = 1025
+	keyGTCitation               = 1026
+	keyGeographicType           = 2048
+	keyGeogGeodeticDatum        = 2050
+	keyGeogAngularUnits         = 2054
+	keyGeogEllipsoid            = 2056
+	keyGeogSemiMajorAxis        = 2057
+	keyGeogSemiMinorAxis        = 2058
+	keyProjectedCSType          = 3072
+	keyProjection               = 3074
+	keyProjCoordTrans           = 3075
+	keyProjLinearUnits          = 3076
+	keyProjNatOriginLat         = 3081
+	keyProjFalseEasting         = 3082
+	keyProjFalseNorthing        = 3083
+	keyProjScaleAtNatOrigin     = 3092
+	keyProjStraightVertPoleLong = 3095
+
+	modelTypeProjected   = 1
+	rasterPixelIsArea    = 1
+	userDefined          = 32767
+	gcsWGS84             = 4326
+	angularDegree        = 9102
+	linearMeter          = 9001
+	ctPolarStereographic = 15
+)
+
+// tiffEntry is a single field of the image file directory.
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte // little endian encoded values
+}
+
+// geoKey is a single entry of the geotiff key directory. Values which are not
+// stored directly are referenced by location and offset.
+type geoKey struct {
+	id       uint16
+	location uint16
+	count    uint16
+	value    uint16
+}
+
+// EncodeGeoTIFF writes the given z-layer of the composite as GeoTIFF image to
+// w. The samples are stored as 32 bit floats, where NaN represents no-data.
+// The image is georeferenced in the polar stereographic projection of the
+// composite, so that it can be used in common GIS applications. An error is
+// returned if no projection is available.
+func (c *Composite) EncodeGeoTIFF(w io.Writer, layer int) error {
+	if !c.HasProjection {
+		return newError("EncodeGeoTIFF", "no projection available")
+	}
+	if layer < 0 || layer >= c.Dz {
+		return newError("EncodeGeoTIFF", "invalid layer")
+	}
+
+	keys, doubles, ascii := c.geoKeys()
+
+	// upper left corner in projected coordinates (m)
+	originX, originY := c.planeCoordinates(0, 0)
+	originX, originY = originX*1000, originY*1000
+
+	entries := []tiffEntry{
+		tiffLongs(tagImageWidth, uint32(c.Dx)),
+		tiffLongs(tagImageLength, uint32(c.Dy)),
+		tiffShorts(tagBitsPerSample, 32),
+		tiffShorts(tagCompression, 1),               // no compression
+		tiffShorts(tagPhotometricInterpretation, 1), // black is zero
+		tiffLongs(tagStripOffsets, 0),               // set below
+		tiffShorts(tagSamplesPerPixel, 1),
+		tiffLongs(tagRowsPerStrip, uint32(c.Dy)),
+		tiffLongs(tagStripByteCounts, uint32(c.Dx*c.Dy*4)),
+		tiffShorts(tagPlanarConfiguration, 1),
+		tiffShorts(tagSampleFormat, 3), // IEEE floating point
+		tiffDoubles(tagModelPixelScale, c.Rx*1000, c.Ry*1000, 0),
+		tiffDoubles(tagModelTiepoint, 0, 0, 0, originX, originY, 0),
+		tiffShorts(tagGeoKeyDirectory, keys...),
+		tiffDoubles(tagGeoDoubleParams, doubles...),
+		tiffString(tagGeoASCIIParams, ascii),
+		tiffString(tagGDALNoData, "nan"),
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	// layout: header, image file directory, field values, image data
+	const headerLength = 8
+	ifdLength := 2 + len(entries)*12 + 4
+
+	offset := headerLength + ifdLength
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			offset += len(e.data) + len(e.data)%2 // word boundary
+		}
+	}
+	for i := range entries {
+		if entries[i].tag == tagStripOffsets {
+			entries[i] = tiffLongs(tagStripOffsets, uint32(offset))
+		}
+	}
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+
+	// header
+	buf.WriteString("II")
+	binary.Write(&buf, le, uint16(42))
+	binary.Write(&buf, le, uint32(headerLength))
+
+	// image file directory
+	binary.Write(&buf, le, uint16(len(entries)))
+	valueOffset := headerLength + ifdLength
+	for _, e := range entries {
+		binary.Write(&buf, le, e.tag)
+		binary.Write(&buf, le, e.typ)
+		binary.Write(&buf, le, e.count)
+		if len(e.data) <= 4 {
+			var inline [4]byte
+			copy(inline[:], e.data)
+			buf.Write(inline[:])
+		} else {
+			binary.Write(&buf, le, uint32(valueOffset))
+			valueOffset += len(e.data) + len(e.data)%2
+		}
+	}
+	binary.Write(&buf, le, uint32(0)) // no further directory
+
+	// field values
+	for _, e := range entries {
+		if len(e.data) > 4 {
+			buf.Write(e.data)
+			if len(e.data)%2 != 0 {
+				buf.WriteByte(0)
+			}
+		}
+	}
+
+	if _, err := buf.WriteTo(w); err != nil {
+		return err
+	}
+
+	// image data
+	row := make([]byte, c.Dx*4)
+	for y := 0; y < c.Dy; y++ {
+		for x, v := range c.DataZ[layer][y] {
+			le.PutUint32(row[x*4:], math.Float32bits(v))
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// geoKeys returns the geotiff key directory and its double and ascii
+// parameters describing the polar stereographic projection of the composite.
+func (c *Composite) geoKeys() (directory []uint16, doubles []float64, ascii string) {
+	var keys []geoKey
+	short := func(id, value uint16) {
+		keys = append(keys, geoKey{id, 0, 1, value})
+	}
+	double := func(id uint16, value float64) {
+		keys = append(keys, geoKey{id, tagGeoDoubleParams, 1, uint16(len(doubles))})
+		doubles = append(doubles, value)
+	}
+
+	citation := "RADOLAN polar stereographic|"
+	lon0, latTS, scale := junctionEast, junctionNorth, 1.0
+	falseEasting, falseNorthing := 0.0, 0.0
+	a, b := earthRadius*1000, earthRadius*1000
+
+	short(keyGTModelType, modelTypeProjected)
+	short(keyGTRasterType, rasterPixelIsArea)
+	keys = append(keys, geoKey{keyGTCitation, tagGeoASCIIParams, uint16(len(citation)), 0})
+
+	if p := c.proj_wgs84; p != nil { // ellipsoid
+		lon0, latTS, scale = p.lon_0/degToRad, p.lat_ts, p.k
+		falseEasting, falseNorthing = p.x_0, p.y_0
+		a, b = p.a, p.b
+	}
+
+	if a == wgs84SemiMajor && b == wgs84SemiMinor {
+		short(keyGeographicType, gcsWGS84)
+		short(keyGeogAngularUnits, angularDegree)
+	} else { // user defined ellipsoid or sphere
+		short(keyGeographicType, userDefined)
+		short(keyGeogGeodeticDatum, userDefined)
+		short(keyGeogAngularUnits, angularDegree)
+		short(keyGeogEllipsoid, userDefined)
+		double(keyGeogSemiMajorAxis, a)
+		double(keyGeogSemiMinorAxis, b)
+	}
+
+	short(keyProjectedCSType, userDefined)
+	short(keyProjection, userDefined)
+	short(keyProjCoordTrans, ctPolarStereographic)
+	short(keyProjLinearUnits, linearMeter)
+	double(keyProjNatOriginLat, latTS) // latitude of true scale
+	double(keyProjFalseEasting, falseEasting)
+	double(keyProjFalseNorthing, falseNorthing)
+	double(keyProjScaleAtNatOrigin, scale)
+	double(keyProjStraightVertPoleLong, lon0)
+
+	directory = []uint16{1, 1, 0, uint16(len(keys))} // version, revision, minor revision, count
+	for _, k := range keys {
+		directory = append(directory, k.id, k.location, k.count, k.value)
+	}
+
+	ascii = citation
+	return
+}
+
+// tiffShorts returns a field holding the given short values.
+func tiffShorts(tag uint16, values ...uint16) tiffEntry {
+	data := make([]byte, len(values)*2)
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(data[i*2:], v)
+	}
+	return tiffEntry{tag, typeShort, uint32(len(values)), data}
+}
+
+// tiffLongs returns a field holding the given long values.
+func tiffLongs(tag uint16, values ...uint32) tiffEntry {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], v)
+	}
+	return tiffEntry{tag, typeLong, uint32(len(values)), data}
+}
+
+// tiffDoubles returns a field holding the given double values.
+func tiffDoubles(tag uint16, values ...float64) tiffEntry {
+	data := make([]byte, len(values)*8)
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(data[i*8:], math.Float64bits(v))
+	}
+	return tiffEntry{tag, typeDouble, uint32(len(values)), data}
+}
+
+// tiffString returns a field holding the given NUL terminated string.
+func tiffString(tag uint16, value string) tiffEntry {
+	data := append([]byte(value), 0)
+	return tiffEntry{tag, typeASCII, uint32(len(data)), data}
+}
@@ -0,0 +1,202 @@
+package radolan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// readTIFFTags returns the fields of the first image file directory of the given
+// little endian tiff file. The values are resolved to their raw bytes.
+func readTIFFTags(t *testing.T, file []byte) map[uint16][]byte {
+	t.Helper()
+	le := binary.LittleEndian
+
+	if !bytes.Equal(file[:4], []byte{'I', 'I', 42, 0}) {
+		t.Fatalf("invalid tiff header: %#v", file[:4])
+	}
+
+	size := map[uint16]int{typeASCII: 1, typeShort: 2, typeLong: 4, typeDouble: 8}
+	tags := make(map[uint16][]byte)
+
+	ifd := le.Uint32(file[4:])
+	n := int(le.Uint16(file[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := file[int(ifd)+2+i*12:]
+		tag, typ, count := le.Uint16(entry), le.Uint16(entry[2:]), le.Uint32(entry[4:])
+
+		length := size[typ] * int(count)
+		if length <= 4 {
+			tags[tag] = entry[8 : 8+length]
+		} else {
+			offset := le.Uint32(entry[8:])
+			tags[tag] = file[offset : int(offset)+length]
+		}
+	}
+	return tags
+}
+
+// littleEndianBytes returns the little endian encoding of the given values.
+func littleEndianBytes(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeGeoTIFF(t *testing.T) {
+	fill := func(c *Composite, x, y int) float32 {
+		if x == y {
+			return NaN
+		}
+		return float32(x) + float32(y)/1000
+	}
+
+	sphere := newTestComposite("RX", 3, 450, 450, 0, nil, fill)
+	wgs84 := newTestComposite("WN", 5, 550, 600, 0, nil, fill)
+
+	testcases := []struct {
+		comp    *Composite
+		keys    []uint16
+		doubles []float64
+	}{
+		{sphere, []uint16{
+			1, 1, 0, 18,
+			1024, 0, 1, 1, // projected
+			1025, 0, 1, 1, // pixel is area
+			1026, 34737, 28, 0, // citation
+			2048, 0, 1, 32767, // user defined geographic type
+			2050, 0, 1, 32767, // user defined datum
+			2054, 0, 1, 9102, // degree
+			2056, 0, 1, 32767, // user defined ellipsoid
+			2057, 34736, 1, 0, // semi major axis
+			2058, 34736, 1, 1, // semi minor axis
+			3072, 0, 1, 32767, // user defined projected cs
+			3074, 0, 1, 32767, // user defined projection
+			3075, 0, 1, 15, // polar stereographic
+			3076, 0, 1, 9001, // meter
+			3081, 34736, 1, 2, // latitude of true scale
+			3082, 34736, 1, 3, // false easting
+			3083, 34736, 1, 4, // false northing
+			3092, 34736, 1, 5, // scale
+			3095, 34736, 1, 6, // pole longitude
+		}, []float64{6370040, 6370040, 60, 0, 0, 1, 10}},
+		{wgs84, []uint16{
+			1, 1, 0, 14,
+			1024, 0, 1, 1,
+			1025, 0, 1, 1,
+			1026, 34737, 28, 0,
+			2048, 0, 1, 4326, // WGS84
+			2054, 0, 1, 9102,
+			3072, 0, 1, 32767,
+			3074, 0, 1, 32767,
+			3075, 0, 1, 15,
+			3076, 0, 1, 9001,
+			3081, 34736, 1, 0,
+			3082, 34736, 1, 1,
+			3083, 34736, 1, 2,
+			3092, 34736, 1, 3,
+			3095, 34736, 1, 4,
+		}, []float64{60, 543196.83521776402, 3622588.861931001, 1, 10}},
+	}
+
+	for _, test := range testcases {
+		comp := test.comp
+
+		var buf bytes.Buffer
+		if err := comp.EncodeGeoTIFF(&buf, 0); err != nil {
+			t.Fatalf("%s.EncodeGeoTIFF(): returned error: %#v", comp.Product, err.Error())
+		}
+		file := buf.Bytes()
+		tags := readTIFFTags(t, file)
+
+		dataLength := comp.Dx * comp.Dy * 4
+		expected := map[uint16][]byte{
+			tagImageWidth:                littleEndianBytes(uint32(comp.Dx)),
+			tagImageLength:               littleEndianBytes(uint32(comp.Dy)),
+			tagBitsPerSample:             littleEndianBytes(uint16(32)),
+			tagCompression:               littleEndianBytes(uint16(1)),
+			tagPhotometricInterpretation: littleEndianBytes(uint16(1)),
+			tagStripOffsets:              littleEndianBytes(uint32(len(file) - dataLength)),
+			tagSamplesPerPixel:           littleEndianBytes(uint16(1)),
+			tagRowsPerStrip:              littleEndianBytes(uint32(comp.Dy)),
+			tagStripByteCounts:           littleEndianBytes(uint32(dataLength)),
+			tagPlanarConfiguration:       littleEndianBytes(uint16(1)),
+			tagSampleFormat:              littleEndianBytes(uint16(3)),
+			tagModelPixelScale:           littleEndianBytes(comp.Rx*1000, comp.Ry*1000, 0.0),
+			tagModelTiepoint:             littleEndianBytes(0.0, 0.0, 0.0, comp.offx*1000, -comp.offy*1000, 0.0),
+			tagGeoKeyDirectory:           littleEndianBytes(test.keys),
+			tagGeoDoubleParams:           littleEndianBytes(test.doubles),
+			tagGeoASCIIParams:            []byte("RADOLAN polar stereographic|\x00"),
+			tagGDALNoData:                []byte("nan\x00"),
+		}
+
+		if len(tags) != len(expected) {
+			t.Errorf("%s.EncodeGeoTIFF(): %d tags; expected: %d", comp.Product, len(tags), len(expected))
+		}
+		for tag, exp := range expected {
+			if res, ok := tags[tag]; !ok || !bytes.Equal(res, exp) {
+				t.Errorf("%s.EncodeGeoTIFF(): tag %d: % x; expected: % x", comp.Product, tag, res, exp)
+			}
+		}
+
+		// image data
+		data := file[len(file)-dataLength:]
+		for y := 0; y < comp.Dy; y++ {
+			for x := 0; x < comp.Dx; x++ {
+				res := math.Float32frombits(binary.LittleEndian.Uint32(data[(y*comp.Dx+x)*4:]))
+				if exp := comp.Data[y][x]; res != exp && !(IsNaN(res) && IsNaN(exp)) {
+					t.Fatalf("%s.EncodeGeoTIFF(): sample (%d, %d) = %#v; expected: %#v", comp.Product, x, y, res, exp)
+				}
+			}
+		}
+	}
+
+	// projected coordinates of the tiepoint for the sphere (km) - national grid [1]
+	if !absequal(sphere.offx, -523.4622, 0.01) || !absequal(sphere.offy, 3758.645, 0.01) {
+		t.Errorf("RX: tiepoint: (%#v, %#v); expected: (-523.4622, 3758.645)", sphere.offx, sphere.offy)
+	}
+
+	if err := NewDummy("PX", 0, 200, 224).EncodeGeoTIFF(&bytes.Buffer{}, 0); err == nil {
+		t.Errorf("PX.EncodeGeoTIFF(): expected error for missing projection")
+	}
+}
+
+func TestEncodeGeoTIFFScaleFactor(t *testing.T) {
+	defer func(grids []gridDefinition) { registeredGrids = grids }(registeredGrids)
+
+	err := RegisterGrid(GridDefinition{
+		Dx: 300, Dy: 500, MinFormat: 5,
+		Proj4: "+proj=stere +lat_0=90 +lat_ts=90 +lon_0=10 +k=0.97 +ellps=WGS84",
+		North: 55, West: 3,
+		South: 47, East: 15,
+	})
+	if err != nil {
+		t.Fatalf("RegisterGrid() returned error: %#v", err)
+	}
+
+	var buf bytes.Buffer
+	comp := newTestComposite("WN", 5, 300, 500, 0, nil, func(c *Composite, x, y int) float32 { return 0 })
+	if err := comp.EncodeGeoTIFF(&buf, 0); err != nil {
+		t.Fatalf("WN.EncodeGeoTIFF(): returned error: %#v", err.Error())
+	}
+	tags := readTIFFTags(t, buf.Bytes())
+
+	// resolve the double parameters of the geo keys
+	params := make(map[uint16]float64)
+	dir, doubles := tags[tagGeoKeyDirectory], tags[tagGeoDoubleParams]
+	for i := 8; i+8 <= len(dir); i += 8 {
+		key := binary.LittleEndian.Uint16(dir[i:])
+		if binary.LittleEndian.Uint16(dir[i+2:]) == tagGeoDoubleParams {
+			index := int(binary.LittleEndian.Uint16(dir[i+6:]))
+			params[key] = math.Float64frombits(binary.LittleEndian.Uint64(doubles[index*8:]))
+		}
+	}
+
+	if params[keyProjNatOriginLat] != 90 || params[keyProjScaleAtNatOrigin] != 0.97 {
+		t.Errorf("WN.EncodeGeoTIFF(): latitude of true scale %#v, scale %#v; expected: 90, 0.97",
+			params[keyProjNatOriginLat], params[keyProjScaleAtNatOrigin])
+	}
+}
@@ -3,6 +3,7 @@ package radolan
 import (
 	"bufio"
 	"fmt"
+	"strings"
 	"time"
 	"unicode"
 )
@@ -38,6 +39,45 @@ func splitHeader(header string) (m map[string]string) {
 	return
 }
 
+// Header contains the typed fields of the composite header as described in [1]
+// and [3]. Fields not present in the header are left at their zero value.
+// Fields which are not recognised or cannot be parsed are kept in Unknown.
+type Header struct {
+	Raw string // complete header without delimiter
+
+	Product string    // product label - Example: "PG"
+	Time    time.Time // capture time
+	WMO     string    // WMO number - Example: "10000"
+
+	DataLength int    // BY: length of header and binary section in bytes
+	Version    int    // VS: format version
+	Software   string // SW: software version - Example: "2.13.1"
+	Precision  int    // PR: precision exponent, values are multiplied by 10^Precision
+	Interval   int    // INT: interval in minutes (in 10 minutes for W1-W4)
+	Dx         int    // GP/BG: data width
+	Dy         int    // GP/BG: data height
+	Forecast   int    // VV: forecast time in minutes
+
+	ModuleFlags    int // MF: module flags
+	Quantification int // QN: quantification
+	UnitFlag       int // U: unit flag
+	Coding         int // CS: coding
+	Maximum        int // MX: maximum
+
+	Radars   []string  // MS: radar stations used for the composite
+	Stations []Station // ST: status of the radar stations
+	Level    []float32 // LV: level values of runlength based formats
+
+	Unknown map[string]string // unrecognised fields
+}
+
+// Station is the status of a single radar station listed in the ST field of the
+// composite header.
+type Station struct {
+	Name   string
+	Status int
+}
+
 // parseHeader parses and the composite header and writes the related fields as
 // described in [1] and [3].
 func (c *Composite) parseHeader(reader *bufio.Reader) error {
@@ -46,11 +86,16 @@ func (c *Composite) parseHeader(reader *bufio.Reader) error {
 		return newError("parseHeader", "header corrupted: too short")
 	}
 
-	// Split header segments
-	section := splitHeader(header[:len(header)-1]) // without delimiter
+	h := &c.Header
+	if err := h.parse(header[:len(header)-1]); err != nil { // without delimiter
+		return err
+	}
 
-	// Parse Product - Example: "PG" or "FZ"
-	c.Product = header[:2]
+	c.Product = h.Product
+	c.CaptureTime = h.Time
+	c.Format = h.Version
+	c.precision = h.Precision
+	c.level = h.Level
 
 	// Lookup Unit
 	c.DataUnit = Unit_unknown
@@ -58,70 +103,92 @@ func (c *Composite) parseHeader(reader *bufio.Reader) error {
 		c.DataUnit = unit
 	}
 
+	c.dataLength = h.DataLength - len(header) // remove header length including delimiter
+
+	// Forecast time
+	c.ForecastTime = c.CaptureTime.Add(time.Duration(h.Forecast) * time.Minute)
+
+	// Interval
+	c.Interval = time.Duration(h.Interval) * time.Minute
+	switch c.Product {
+	case "W1", "W2", "W3", "W4":
+		c.Interval *= 10
+	}
+
+	// Dimensions
+	if h.Dx != 0 && h.Dy != 0 {
+		c.Dx, c.Dy = h.Dx, h.Dy
+		c.Px, c.Py = c.Dx, c.Dy // composite formats do not show elevation
+
+	} else { // dimensions of local picture products not defined in header
+		v, ok := dimensionCatalog[c.Product] // lookup in catalog
+		if !ok {
+			return newError("parseHeader", "no dimension information available")
+		}
+
+		c.Px, c.Py = v.px, v.py // plain data dimensions
+		c.Dx, c.Dy = v.dx, v.dy // data layer dimensions
+		c.Rx, c.Ry = v.rx, v.ry // data resolution
+	}
+
+	return nil
+}
+
+// parse parses the given header string without delimiter and writes the related
+// fields as described in [1] and [3].
+func (h *Header) parse(header string) (err error) {
+	*h = Header{Raw: header, Unknown: make(map[string]string)}
+	if len(header) < 21 {
+		return newError("parseHeader", "header corrupted: too short")
+	}
+
+	// Split header segments
+	section := splitHeader(header)
+
+	// Parse Product - Example: "PG" or "FZ"
+	h.Product = header[:2]
+	h.WMO = header[8:13]
+
 	// Parse DataLength - Example: "BY 405160"
-	if _, err := fmt.Sscanf(section["BY"], "%d", &c.dataLength); err != nil {
+	if _, err := fmt.Sscanf(section["BY"], "%d", &h.DataLength); err != nil {
 		return newError("parseHeader", "could not parse data length: "+err.Error())
 	}
-	c.dataLength -= len(header) // remove header length including delimiter
 
 	// Parse CaptureTime - Example: "PG262115100000616" or "FZ211615100000716"
 	date := header[2:8] + header[13:17] // cut WMO number
-	c.CaptureTime, err = time.Parse("0215040106", date)
+	h.Time, err = time.Parse("0215040106", date)
 	if err != nil {
 		return newError("parseHeader", "could not parse capture time: "+err.Error())
 	}
 
 	// Parse ForecastTime - Example: "VV 005"
-	c.ForecastTime = c.CaptureTime
 	if vv, ok := section["VV"]; ok {
-		min := 0
-		if _, err := fmt.Sscanf(vv, "%d", &min); err != nil {
+		if _, err := fmt.Sscanf(vv, "%d", &h.Forecast); err != nil {
 			return newError("parseHeader", "could not parse forecast time: "+err.Error())
 		}
-		c.ForecastTime = c.CaptureTime.Add(time.Duration(min) * time.Minute)
 	}
 
 	// Parse Interval - Example "INT   5" or "INT1008"
 	if intr, ok := section["INT"]; ok {
-		min := 0
-		if _, err := fmt.Sscanf(intr, "%d", &min); err != nil {
+		if _, err := fmt.Sscanf(intr, "%d", &h.Interval); err != nil {
 			return newError("parseHeader", "could not parse interval: "+err.Error())
 		}
-
-		c.Interval = time.Duration(min) * time.Minute
-		switch c.Product {
-		case "W1", "W2", "W3", "W4":
-			c.Interval *= 10
-		}
 	}
 
 	// Parse Dimensions - Example: "GP 450x 450" or "BG460460" or "GP 1500x1400" (if defined)
 	if dim, ok := section["GP"]; ok {
-		if _, err := fmt.Sscanf(dim, "%dx%d", &c.Dy, &c.Dx); err != nil {
+		if _, err := fmt.Sscanf(dim, "%dx%d", &h.Dy, &h.Dx); err != nil {
 			return newError("parseHeader", "could not parse dimensions (GP): "+err.Error())
 		}
-		c.Px, c.Py = c.Dx, c.Dy // composite formats do not show elevation
-
 	} else if dim, ok := section["BG"]; ok {
-		if _, err := fmt.Sscanf(dim, "%3d%3d", &c.Dy, &c.Dx); err != nil {
+		if _, err := fmt.Sscanf(dim, "%3d%3d", &h.Dy, &h.Dx); err != nil {
 			return newError("parseHeader", "could not parse dimensions (BG): "+err.Error())
 		}
-		c.Px, c.Py = c.Dx, c.Dy // composite formats do not show elevation
-
-	} else { // dimensions of local picture products not defined in header
-		v, ok := dimensionCatalog[c.Product] // lookup in catalog
-		if !ok {
-			return newError("parseHeader", "no dimension information available")
-		}
-
-		c.Px, c.Py = v.px, v.py // plain data dimensions
-		c.Dx, c.Dy = v.dx, v.dy // data layer dimensions
-		c.Rx, c.Ry = v.rx, v.ry // data resolution
 	}
 
 	// Parse Precision - Example: "PR E-01" or "PR E+00"
 	if prec, ok := section["E"]; ok { // not that nice
-		if _, err := fmt.Sscanf(prec, "%d", &c.precision); err != nil {
+		if _, err := fmt.Sscanf(prec, "%d", &h.Precision); err != nil {
 			return newError("parseHeader", "could not parse precision: "+err.Error())
 		}
 	}
@@ -142,10 +209,10 @@ func (c *Composite) parseHeader(reader *bufio.Reader) error {
 			return newError("parseHeader", "invalid level format: "+lv)
 		}
 
-		c.level = make([]float32, cnt)
-		for i := range c.level {
+		h.Level = make([]float32, cnt)
+		for i := range h.Level {
 			n := i * 5
-			if _, err = fmt.Sscanf(lv[n+2:n+7], "%f", &c.level[i]); err != nil {
+			if _, err = fmt.Sscanf(lv[n+2:n+7], "%f", &h.Level[i]); err != nil {
 				return newError("parseHeader", "invalid level value: "+err.Error())
 			}
 		}
@@ -153,10 +220,200 @@ func (c *Composite) parseHeader(reader *bufio.Reader) error {
 
 	// Parse Format Version - Example "VS 5"
 	if vs, ok := section["VS"]; ok {
-		if _, err = fmt.Sscanf(vs, "%d", &c.Format); err != nil {
+		if _, err = fmt.Sscanf(vs, "%d", &h.Version); err != nil {
 			return newError("parseHeader", "invalid format value: "+err.Error())
 		}
 	}
 
+	// Parse Software Version - Example "SW   2.13.1"
+	if sw, ok := section["SW"]; ok {
+		h.Software = strings.TrimSpace(sw)
+	}
+
+	// Parse Radar Stations - Example "MS 66<boo,ros,emd,hnr,umd,pro,ess,drs,neu,nhb,oft,eis,tur,isn,fbg,mem>"
+	if ms, ok := section["MS"]; ok {
+		if list, ok := headerList(ms); ok {
+			h.Radars = list
+		} else {
+			h.Unknown["MS"] = ms
+		}
+	}
+
+	// Parse Station Status - Example "ST 92<asb 1,boo 1,ros 1,hnr 1,umd 1,pro 1>"
+	if st, ok := section["ST"]; ok {
+		list, ok := headerList(st)
+		for _, entry := range list {
+			var station Station
+			if _, err := fmt.Sscanf(entry, "%s %d", &station.Name, &station.Status); err != nil {
+				ok = false
+				break
+			}
+			h.Stations = append(h.Stations, station)
+		}
+		if !ok {
+			h.Stations = nil
+			h.Unknown["ST"] = st
+		}
+	}
+
+	// Parse numeric flags - Example "MF 00000002", "QN 001", "U0", "CS0" or "MX 0"
+	for key, dst := range map[string]*int{
+		"MF": &h.ModuleFlags,
+		"QN": &h.Quantification,
+		"U":  &h.UnitFlag,
+		"CS": &h.Coding,
+		"MX": &h.Maximum,
+	} {
+		if v, ok := section[key]; ok {
+			if _, err := fmt.Sscanf(v, "%d", dst); err != nil {
+				h.Unknown[key] = v
+			}
+		}
+	}
+
+	// Keep unrecognised fields
+	product := header[:strings.IndexFunc(header, func(r rune) bool { return !unicode.IsUpper(r) })]
+	for key, v := range section {
+		switch key {
+		case product, "BY", "VV", "INT", "GP", "BG", "PR", "E", "LV", "VS", "SW",
+			"MS", "ST", "MF", "QN", "U", "CS", "MX":
+		default:
+			h.Unknown[key] = v
+		}
+	}
+
 	return nil
 }
+
+// headerList extracts the comma separated list enclosed in angle brackets of the
+// given header field - Example " 10<boo,ros>".
+func headerList(field string) ([]string, bool) {
+	begin, end := strings.IndexByte(field, '<'), strings.IndexByte(field, '>')
+	if begin == -1 || end < begin {
+		return nil, false
+	}
+	if end == begin+1 {
+		return []string{}, true
+	}
+	return strings.Split(field[begin+1:end], ","), true
+}
+
+// encodeHeader creates the composite header including the delimiter. The given
+// length of the binary section is used for the BY field. The fields are
+// written as described in [1] and [3]. Fields which are not represented by the
+// composite itself are taken from its Header.
+func (c *Composite) encodeHeader(dataLength int) (string, error) {
+	if len(c.Product) != 2 {
+		return "", newError("encodeHeader", "invalid product label: "+c.Product)
+	}
+
+	var b strings.Builder
+
+	// Product and CaptureTime - Example: "FZ211615100000716"
+	capture := c.CaptureTime.UTC()
+	b.WriteString(c.Product)
+	b.WriteString(capture.Format("021504"))
+	wmo := c.Header.WMO
+	if len(wmo) != 5 {
+		wmo = "10000"
+	}
+	b.WriteString(wmo)
+	b.WriteString(capture.Format("0106"))
+
+	// DataLength (fixed width) - Example: "BY 405160"
+	byPos := b.Len()
+	b.WriteString("BY       ")
+
+	// Format Version - Example "VS 5"
+	if c.Format != 0 {
+		fmt.Fprintf(&b, "VS %d", c.Format)
+	}
+
+	// Software Version - Example "SW   2.13.1"
+	if c.Header.Software != "" {
+		fmt.Fprintf(&b, "SW%9s", c.Header.Software)
+	}
+
+	// Precision - Example: "PR E-01"
+	if c.level == nil {
+		fmt.Fprintf(&b, "PR E%+03d", c.precision)
+	}
+
+	// Interval - Example "INT   5"
+	if c.Interval != 0 {
+		min := int(c.Interval / time.Minute)
+		switch c.Product {
+		case "W1", "W2", "W3", "W4":
+			min /= 10
+		}
+		fmt.Fprintf(&b, "INT%4d", min)
+	}
+
+	// Dimensions - Example: "GP 450x 450" or "BG460460"
+	if _, ok := dimensionCatalog[c.Product]; !ok {
+		switch c.Product {
+		case "PG", "PC":
+			fmt.Fprintf(&b, "BG%3d%3d", c.Dy, c.Dx)
+		default:
+			fmt.Fprintf(&b, "GP%4dx%4d", c.Dy, c.Dx)
+		}
+	}
+
+	// ForecastTime - Example: "VV 005"
+	if vv := int(c.ForecastTime.Sub(c.CaptureTime) / time.Minute); vv != 0 {
+		fmt.Fprintf(&b, "VV %3d", vv)
+	}
+
+	// Flags - Example "U0", "MF 00000002" or "QN 001"
+	if c.Header.UnitFlag != 0 {
+		fmt.Fprintf(&b, "U%d", c.Header.UnitFlag)
+	}
+	if c.Header.ModuleFlags != 0 {
+		fmt.Fprintf(&b, "MF %08d", c.Header.ModuleFlags)
+	}
+	if c.Header.Quantification != 0 {
+		fmt.Fprintf(&b, "QN %03d", c.Header.Quantification)
+	}
+
+	// Radar Stations - Example "MS 10<boo,ros>"
+	if len(c.Header.Radars) != 0 {
+		list := "<" + strings.Join(c.Header.Radars, ",") + ">"
+		fmt.Fprintf(&b, "MS%3d%s", len(list), list)
+	}
+
+	// Station Status - Example "ST 14<boo 1,ros 1>"
+	if len(c.Header.Stations) != 0 {
+		entries := make([]string, len(c.Header.Stations))
+		for i, station := range c.Header.Stations {
+			entries[i] = fmt.Sprintf("%s %d", station.Name, station.Status)
+		}
+		list := "<" + strings.Join(entries, ",") + ">"
+		fmt.Fprintf(&b, "ST%3d%s", len(list), list)
+	}
+
+	// Level - Example "LV 6  1.0 19.0 28.0 37.0 46.0 55.0"
+	if c.level != nil {
+		fmt.Fprintf(&b, "LV%2d", len(c.level))
+		for _, l := range c.level {
+			fmt.Fprintf(&b, "%5.1f", l)
+		}
+	}
+
+	// Coding and Maximum - Example "CS1MX 1"
+	if c.Header.Coding != 0 {
+		fmt.Fprintf(&b, "CS%d", c.Header.Coding)
+	}
+	if c.Header.Maximum != 0 {
+		fmt.Fprintf(&b, "MX %d", c.Header.Maximum)
+	}
+
+	b.WriteByte('\x03')
+
+	header := b.String()
+	by := fmt.Sprintf("BY%7d", len(header)+dataLength)
+	if len(by) != 9 {
+		return "", newError("encodeHeader", "data length exceeds field width")
+	}
+
+	return header[:byPos] + by + header[byPos+len(by):], nil
+}
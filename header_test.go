@@ -145,3 +145,55 @@ func testParseHeader(t *testing.T, ht *headerTestcase) {
 		t.Errorf("%s.parseHeader(): binary data corrupted", ht.expProduct)
 	}
 }
+
+func TestParseHeaderFields(t *testing.T) {
+	dummy := &Composite{}
+	test := "WN141205100000723BY 2640176VS 5SW   2.32.0PR E-02INT   5GP1200x1100VV 120MF 00000008" +
+		"QN 001MS 34<asb,boo,ros,hnr,umd,pro,ess>ST 44<asb 1,boo 1,ros 0,hnr 1>XY foo\x03"
+
+	if err := dummy.parseHeader(bufio.NewReader(strings.NewReader(test))); err != nil {
+		t.Fatalf("WN.parseHeader(): returned error: %#v", err.Error())
+	}
+
+	h := dummy.Header
+	if h.Raw != test[:len(test)-1] {
+		t.Errorf("WN.parseHeader(): Header.Raw: %#v; expected: %#v", h.Raw, test[:len(test)-1])
+	}
+	if h.Product != "WN" || h.WMO != "10000" || h.DataLength != 2640176 || h.Version != 5 {
+		t.Errorf("WN.parseHeader(): Header: Product: %#v WMO: %#v DataLength: %d Version: %d",
+			h.Product, h.WMO, h.DataLength, h.Version)
+	}
+	if h.Software != "2.32.0" || h.Precision != -2 || h.Interval != 5 || h.Forecast != 120 {
+		t.Errorf("WN.parseHeader(): Header: Software: %#v Precision: %d Interval: %d Forecast: %d",
+			h.Software, h.Precision, h.Interval, h.Forecast)
+	}
+	if h.Dx != 1100 || h.Dy != 1200 || h.ModuleFlags != 8 || h.Quantification != 1 {
+		t.Errorf("WN.parseHeader(): Header: Dx: %d Dy: %d ModuleFlags: %d Quantification: %d",
+			h.Dx, h.Dy, h.ModuleFlags, h.Quantification)
+	}
+
+	expRadars := []string{"asb", "boo", "ros", "hnr", "umd", "pro", "ess"}
+	if len(h.Radars) != len(expRadars) {
+		t.Fatalf("WN.parseHeader(): Header.Radars: %#v; expected: %#v", h.Radars, expRadars)
+	}
+	for i := range expRadars {
+		if h.Radars[i] != expRadars[i] {
+			t.Errorf("WN.parseHeader(): Header.Radars: %#v; expected: %#v", h.Radars, expRadars)
+		}
+	}
+
+	expStations := []Station{{"asb", 1}, {"boo", 1}, {"ros", 0}, {"hnr", 1}}
+	if len(h.Stations) != len(expStations) {
+		t.Fatalf("WN.parseHeader(): Header.Stations: %#v; expected: %#v", h.Stations, expStations)
+	}
+	for i := range expStations {
+		if h.Stations[i] != expStations[i] {
+			t.Errorf("WN.parseHeader(): Header.Stations: %#v; expected: %#v", h.Stations, expStations)
+		}
+	}
+
+	if len(h.Unknown) != 1 || h.Unknown["XY"] != " foo" {
+		t.Errorf("WN.parseHeader(): Header.Unknown: %#v; expected: %#v", h.Unknown,
+			map[string]string{"XY": " foo"})
+	}
+}
@@ -0,0 +1,176 @@
+package radolan
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry is a composite found by ScanIndex. Only the header of the composite is
+// parsed.
+type Entry struct {
+	Path   string // path of the file
+	Member string // path of the member inside an archive (empty for plain files)
+
+	Product      string
+	CaptureTime  time.Time
+	ForecastTime time.Time
+
+	Header Header // parsed header fields
+}
+
+// Index holds the composites of a directory tree of RADOLAN files and
+// archives. The entries are sorted by ForecastTime in ascending order.
+type Index struct {
+	Entries []Entry
+}
+
+// ScanIndex walks the directory tree at root and reads the header of each
+// composite. Archives and compressed files are traversed as described for
+// NewCompositesFrom, whereas plain files are only considered if ParseFilename
+// accepts their name. Composites which cannot be read are returned as
+// MemberErrors along with a valid index of the remaining composites. Other
+// errors are returned directly.
+func ScanIndex(root string) (*Index, error) {
+	idx := &Index{}
+	var errs MemberErrors
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if err := idx.scanFile(path, &errs); err != nil {
+			errs = append(errs, &MemberError{path, err})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].ForecastTime.Before(idx.Entries[j].ForecastTime)
+	})
+
+	if len(errs) > 0 {
+		return idx, errs
+	}
+	return idx, nil
+}
+
+// scanFile adds the composites of the file at path to the index. Failures of
+// archive members are appended to errs.
+func (idx *Index) scanFile(path string, errs *MemberErrors) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	kind, err := identifyContainer(reader)
+	if err != nil {
+		return err
+	}
+	if _, err := ParseFilename(path); kind == containerRaw && err != nil {
+		return nil // no radolan file
+	}
+
+	walker := containerWalker{
+		visit: func(name string, rd *bufio.Reader) error {
+			dec, err := NewDecoder(rd)
+			if err != nil && err != ErrUnknownUnit {
+				return err
+			}
+
+			c := dec.Composite()
+			idx.Entries = append(idx.Entries, Entry{
+				Path:         path,
+				Member:       name,
+				Product:      c.Product,
+				CaptureTime:  c.CaptureTime,
+				ForecastTime: c.ForecastTime,
+				Header:       c.Header,
+			})
+			return nil
+		},
+		fail: func(name string, err error) error {
+			*errs = append(*errs, &MemberError{path + "/" + name, err})
+			return nil
+		},
+	}
+	return walker.walk(reader, "", DefaultArchiveOptions.MaxDepth)
+}
+
+// Query returns the entries of the given product with a ForecastTime between
+// from and to (inclusive).
+func (idx *Index) Query(product string, from, to time.Time) []Entry {
+	begin := sort.Search(len(idx.Entries), func(i int) bool {
+		return !idx.Entries[i].ForecastTime.Before(from)
+	})
+
+	var entries []Entry
+	for _, e := range idx.Entries[begin:] {
+		if e.ForecastTime.After(to) {
+			break
+		}
+		if e.Product == product {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Latest returns the entries of the most recent run of the given product,
+// which are the entries sharing the latest CaptureTime. For forecast products
+// like WN or FX these are the different lead times. nil is returned if the
+// product is not indexed.
+func (idx *Index) Latest(product string) []Entry {
+	var latest time.Time
+	var entries []Entry
+	for _, e := range idx.Entries {
+		if e.Product != product {
+			continue
+		}
+
+		switch {
+		case e.CaptureTime.After(latest) || entries == nil:
+			latest = e.CaptureTime
+			entries = []Entry{e}
+		case e.CaptureTime.Equal(latest):
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Open reads and parses the composite of the entry. Like NewComposite, it
+// returns the composite along with ErrUnknownUnit if its unit is not defined
+// in the catalog, so that all indexed entries can be opened.
+func (e Entry) Open() (*Composite, error) {
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	opts := DefaultArchiveOptions
+	opts.Name = func(name string) bool { return name == e.Member }
+	cs, err := NewCompositesFrom(f, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, newError("Open", "member not found: "+e.Member)
+	}
+	if cs[0].DataUnit == Unit_unknown {
+		return cs[0], ErrUnknownUnit
+	}
+	return cs[0], nil
+}
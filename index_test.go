@@ -0,0 +1,148 @@
+package radolan
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "radolan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	encode := func(c *Composite) []byte {
+		var buf bytes.Buffer
+		if err := c.Encode(&buf); err != nil {
+			t.Fatalf("%s.Encode(): returned error: %#v", c.Product, err.Error())
+		}
+		return buf.Bytes()
+	}
+	write := func(name string, data []byte) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// hourly RW composites as plain and gzipped files
+	base := time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		rw := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 { return float32(i) })
+		rw.CaptureTime = base.Add(time.Duration(i) * time.Hour)
+		rw.ForecastTime = rw.CaptureTime
+
+		name := "rw/raa01-rw_10000-" + rw.ForecastTime.Format("0601021504") + "-dwd---bin"
+		if i%2 == 0 {
+			write(name, encode(rw))
+		} else {
+			write(name+".gz", gzipData(encode(rw)))
+		}
+	}
+
+	// two FX runs with lead times in tar archives
+	for run := 0; run < 2; run++ {
+		files := make(map[string][]byte)
+		capture := base.Add(time.Duration(run) * 5 * time.Minute)
+		for lead := 0; lead <= 20; lead += 5 {
+			fx := newTestComposite("FX", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 { return 0 })
+			fx.CaptureTime = capture
+			fx.ForecastTime = capture.Add(time.Duration(lead) * time.Minute)
+			files[fx.CaptureTime.Format("FX0601021504")+"_"+fx.ForecastTime.Format("04")] = encode(fx)
+		}
+		write(capture.Format("fx/FX0601021504.tar.gz"), gzipData(tarArchive(t, files)))
+	}
+
+	write("README", []byte("no radolan file"))
+	write("raa01-rw_10000-1608010000-dwd---bin", []byte("RW broken"))
+
+	idx, err := ScanIndex(dir)
+	errs, ok := err.(MemberErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("ScanIndex(): returned error: %#v; expected one MemberError", err)
+	}
+	if len(idx.Entries) != 16 {
+		t.Fatalf("ScanIndex(): %d entries; expected: 16", len(idx.Entries))
+	}
+
+	rw := idx.Query("RW", base.Add(time.Hour), base.Add(3*time.Hour))
+	if len(rw) != 3 || !rw[0].ForecastTime.Equal(base.Add(time.Hour)) || !rw[2].ForecastTime.Equal(base.Add(3*time.Hour)) {
+		t.Errorf("Index.Query(RW): %d entries; expected: 3", len(rw))
+	}
+	for _, e := range rw {
+		if e.Member != "" || e.Header.Product != "RW" {
+			t.Errorf("Index.Query(RW): unexpected entry: %#v", e)
+		}
+	}
+
+	latest := idx.Latest("FX")
+	if len(latest) != 5 {
+		t.Fatalf("Index.Latest(FX): %d entries; expected: 5", len(latest))
+	}
+	for _, e := range latest {
+		if !e.CaptureTime.Equal(base.Add(5*time.Minute)) || e.Member == "" {
+			t.Errorf("Index.Latest(FX): unexpected entry: %#v", e)
+		}
+	}
+	if idx.Latest("WN") != nil {
+		t.Errorf("Index.Latest(WN): expected nil")
+	}
+
+	// entries can be opened
+	comp, err := latest[4].Open()
+	if err != nil {
+		t.Fatalf("Entry.Open(%s): returned error: %#v", latest[4].Member, err.Error())
+	}
+	if !comp.ForecastTime.Equal(latest[4].ForecastTime) {
+		t.Errorf("Entry.Open(%s).ForecastTime: %v; expected: %v", latest[4].Member, comp.ForecastTime, latest[4].ForecastTime)
+	}
+	comp, err = rw[1].Open()
+	if err != nil || comp.At(0, 0) != 2 {
+		t.Errorf("Entry.Open(%s): composite %#v, error: %v", rw[1].Path, comp, err)
+	}
+}
+
+func TestIndexUnknownUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "radolan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// RV forecasts as plain file and archive
+	plain := filepath.Join(dir, "DE1200_RV1607311650_000")
+	archive := filepath.Join(dir, "DE1200_RV1607311650.tar.gz")
+	if err := ioutil.WriteFile(plain, rvComposite(t, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{"DE1200_RV1607311650_005": rvComposite(t, 5), "DE1200_RV1607311650_010": rvComposite(t, 10)}
+	if err := ioutil.WriteFile(archive, gzipData(tarArchive(t, files)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := ScanIndex(dir)
+	if err != nil {
+		t.Fatalf("ScanIndex(): returned error: %#v", err.Error())
+	}
+	latest := idx.Latest("RV")
+	if len(latest) != 3 {
+		t.Fatalf("Index.Latest(RV): %d entries; expected: 3", len(latest))
+	}
+
+	// indexed entries can be opened
+	for _, e := range latest {
+		comp, err := e.Open()
+		if err != ErrUnknownUnit || comp == nil {
+			t.Fatalf("Entry.Open(%s %s): composite %v, error: %v; expected: composite and ErrUnknownUnit", e.Path, e.Member, comp, err)
+		}
+		if !comp.ForecastTime.Equal(e.ForecastTime) || comp.DataUnit != Unit_unknown {
+			t.Errorf("Entry.Open(%s %s): %s composite (%s); expected: %s", e.Path, e.Member, comp.ForecastTime, comp.DataUnit, e.ForecastTime)
+		}
+	}
+}
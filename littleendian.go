@@ -2,48 +2,58 @@ package radolan
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 )
 
-// parseLittleEndian parses the little endian encoded composite as described in [1] and [3].
-// Result are written into the previously created PlainData field of the composite.
-func (c *Composite) parseLittleEndian(reader *bufio.Reader) error {
-	last := len(c.PlainData) - 1
-	for i := range c.PlainData {
-		line, err := c.readLineLittleEndian(reader)
-		if err != nil {
-			return err
-		}
+// PixelFlag holds the flags of a single pixel in little endian encoded products
+// as described in [1]. The flags are stored in the upper four bits of each raw
+// value.
+type PixelFlag uint8
 
-		err = c.decodeLittleEndian(c.PlainData[last-i], line) // write vertically flipped
-		if err != nil {
-			return err
-		}
+const (
+	Secondary PixelFlag = 1 << iota // bit 12: secondary value
+	NoData                          // bit 13: no-data
+	Negative                        // bit 14: negative value
+	Clutter                         // bit 15: clutter
+)
+
+// Has reports whether all the given flags are set.
+func (f PixelFlag) Has(flag PixelFlag) bool {
+	return f&flag == flag
+}
+
+// parseRowLittleEndian parses the next line of the little endian encoded composite as
+// described in [1] and [3]. The values and pixel flags are written to the given
+// destinations. The line buffer must hold two bytes for each value.
+func (c *Composite) parseRowLittleEndian(reader *bufio.Reader, line []byte, dst []float32, flags []PixelFlag) error {
+	if err := c.readLineLittleEndian(reader, line); err != nil {
+		return err
 	}
 
-	return nil
+	return c.decodeLittleEndian(dst, flags, line)
 }
 
-// readLineLittleEndian reads a line until horizontal limit from the given reader
+// readLineLittleEndian fills the given line from the given reader.
 // This method is used to get a line of little endian encoded data.
-func (c *Composite) readLineLittleEndian(rd *bufio.Reader) (line []byte, err error) {
-	line = make([]byte, c.Dx*2)
-	_, err = io.ReadFull(rd, line)
-	if err != nil {
-		err = newError("readLineLittleEndian", err.Error())
+func (c *Composite) readLineLittleEndian(rd *bufio.Reader, line []byte) error {
+	if _, err := io.ReadFull(rd, line); err != nil {
+		return newError("readLineLittleEndian", err.Error())
 	}
-	return
+	return nil
 }
 
-// decodeLittleEndian decodes the source line and writes to the given destination.
-func (c *Composite) decodeLittleEndian(dst []float32, line []byte) error {
-	if len(line)%2 != 0 || len(dst)*2 != len(line) {
+// decodeLittleEndian decodes the source line and writes the values and pixel flags
+// to the given destinations.
+func (c *Composite) decodeLittleEndian(dst []float32, flags []PixelFlag, line []byte) error {
+	if len(line)%2 != 0 || len(dst)*2 != len(line) || len(flags) != len(dst) {
 		return newError("decodeLittleEndian", "wrong destination or source size")
 	}
 
 	for i := range dst {
 		tuple := [2]byte{line[2*i], line[2*i+1]}
 		dst[i] = c.rvp6LittleEndian(tuple)
+		flags[i] = PixelFlag(tuple[1] >> 4)
 	}
 
 	return nil
@@ -75,3 +85,74 @@ func (c *Composite) rvp6LittleEndian(tuple [2]byte) float32 {
 	// the bias and scale it (RADVOR FX, dBZ)
 	return toDBZ(conv)
 }
+
+// encodeLittleEndian encodes the PlainData field of the composite as little endian
+// format and writes the result to w. The clutter and secondary pixel flags are
+// retained when the Flags field is available.
+func (c *Composite) encodeLittleEndian(w *bytes.Buffer) error {
+	if c.Flags != nil && len(c.Flags) != len(c.PlainData) {
+		return newError("encodeLittleEndian", "wrong flags size")
+	}
+
+	line := make([]byte, c.Px*2)
+	for i := range c.PlainData {
+		y := len(c.PlainData) - 1 - i // write vertically flipped
+
+		var flags []PixelFlag
+		if c.Flags != nil {
+			flags = c.Flags[y]
+		}
+
+		err := c.encodeLineLittleEndian(line, c.PlainData[y], flags)
+		if err != nil {
+			return err
+		}
+		w.Write(line)
+	}
+
+	return nil
+}
+
+// encodeLineLittleEndian encodes the source line and writes to the given destination.
+// The flags are optional.
+func (c *Composite) encodeLineLittleEndian(dst []byte, src []float32, flags []PixelFlag) error {
+	if len(src)*2 != len(dst) || (flags != nil && len(flags) != len(src)) {
+		return newError("encodeLittleEndian", "wrong destination or source size")
+	}
+
+	for i, v := range src {
+		tuple := c.rawLittleEndian(v)
+		if flags != nil { // no-data and negative flags are determined by the value
+			tuple[1] |= byte(flags[i]&(Clutter|Secondary)) << 4
+		}
+		dst[2*i], dst[2*i+1] = tuple[0], tuple[1]
+	}
+
+	return nil
+}
+
+// rawLittleEndian converts the given value to the raw two byte tuple of little endian
+// encoded composite products. This is the inverse of rvp6LittleEndian.
+func (c *Composite) rawLittleEndian(value float32) (tuple [2]byte) {
+	if IsNaN(value) { // error code: no-data
+		return [2]byte{0xC4, 0x29}
+	}
+
+	// little endian encoded formats are also used for mm/h
+	if c.DataUnit == Unit_dBZ {
+		value = toRVP6(value)
+	}
+
+	raw := c.rawRVP6(value)
+	if raw < 0 { // flag: negative value
+		raw *= -1
+		tuple[1] |= 1 << 6
+	}
+	if raw > 0x0FFF {
+		raw = 0x0FFF
+	}
+
+	tuple[0] = byte(raw)
+	tuple[1] |= byte(raw >> 8)
+	return
+}
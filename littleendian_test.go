@@ -0,0 +1,51 @@
+package radolan
+
+import (
+	"testing"
+)
+
+func TestDecodeLittleEndianFlags(t *testing.T) {
+	dummy := &Composite{DataUnit: Unit_mm, precision: -1}
+
+	testcases := []struct {
+		tuple    [2]byte
+		expValue float32
+		expFlags PixelFlag
+	}{
+		{[2]byte{0x0A, 0x00}, 1.0, 0},
+		{[2]byte{0x0A, 0x40}, -1.0, Negative},
+		{[2]byte{0xC4, 0x29}, NaN, NoData},
+		{[2]byte{0x00, 0x80}, 0.0, Clutter},
+		{[2]byte{0x0A, 0x10}, 1.0, Secondary},
+		{[2]byte{0xC4, 0xA9}, NaN, NoData | Clutter},
+	}
+
+	for _, test := range testcases {
+		dst := make([]float32, 1)
+		flags := make([]PixelFlag, 1)
+
+		if err := dummy.decodeLittleEndian(dst, flags, test.tuple[:]); err != nil {
+			t.Fatalf("decodeLittleEndian(%#v): returned error: %#v", test.tuple, err.Error())
+		}
+
+		if dst[0] != test.expValue && !(IsNaN(dst[0]) && IsNaN(test.expValue)) {
+			t.Errorf("decodeLittleEndian(%#v): value: %#v; expected: %#v", test.tuple, dst[0], test.expValue)
+		}
+		if flags[0] != test.expFlags {
+			t.Errorf("decodeLittleEndian(%#v): flags: %#v; expected: %#v", test.tuple, flags[0], test.expFlags)
+		}
+
+		// encoding retains value and flags
+		line := make([]byte, 2)
+		if err := dummy.encodeLineLittleEndian(line, dst, flags); err != nil {
+			t.Fatalf("encodeLineLittleEndian(%#v): returned error: %#v", dst, err.Error())
+		}
+		if line[0] != test.tuple[0] || line[1] != test.tuple[1] {
+			t.Errorf("encodeLineLittleEndian(%#v) = %#v; expected: %#v", dst, line, test.tuple)
+		}
+	}
+
+	if !(NoData | Clutter).Has(Clutter) || Negative.Has(Clutter) {
+		t.Errorf("PixelFlag.Has(): wrong result")
+	}
+}
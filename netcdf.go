@@ -0,0 +1,400 @@
+package radolan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// netcdf tags and types as described in the NetCDF classic format specification
+const (
+	ncDimension = 0x0A
+	ncVariable  = 0x0B
+	ncAttribute = 0x0C
+
+	ncChar   = 2
+	ncInt    = 4
+	ncFloat  = 5
+	ncDouble = 6
+)
+
+// ncAttr is a named netcdf attribute holding a string, int32, float32 or
+// float64 value.
+type ncAttr struct {
+	name  string
+	value interface{}
+}
+
+// ncVar is a netcdf variable. Its data is written by the write function.
+type ncVar struct {
+	name  string
+	dims  []int // dimension ids
+	attrs []ncAttr
+	typ   int
+	size  int64 // data size in bytes without padding
+	write func(w io.Writer) error
+}
+
+// EncodeNetCDF writes the given composites as single NetCDF file in 64-bit
+// offset format to w. The file follows the CF-conventions and contains the
+// dimensions time, y, x and additionally z for composites with multiple
+// layers. The two dimensional lat and lon variables hold the center of each
+// pixel and the projection is described by the polar_stereographic variable.
+// The data variable is named after the product label and uses the DataUnit as
+// units attribute.
+//
+// All composites must share the same product and dimensions. An error is
+// returned if no projection is available.
+func EncodeNetCDF(wr io.Writer, cs []*Composite) error {
+	if len(cs) == 0 {
+		return newError("EncodeNetCDF", "no composites")
+	}
+
+	first := cs[0]
+	if !first.HasProjection {
+		return newError("EncodeNetCDF", "no projection available")
+	}
+	for _, c := range cs {
+		if c.Product != first.Product || c.Dx != first.Dx || c.Dy != first.Dy || c.Dz != first.Dz {
+			return newError("EncodeNetCDF", "composites do not share product and dimensions")
+		}
+	}
+
+	// dimensions
+	var dimNames []string
+	var dimLengths []int
+	dim := func(name string, length int) int {
+		dimNames = append(dimNames, name)
+		dimLengths = append(dimLengths, length)
+		return len(dimNames) - 1
+	}
+
+	dimTime := dim("time", len(cs))
+	dataDims := []int{dimTime}
+	dimZ := -1
+	if first.Dz > 1 { // omit z dimension for single layer composites
+		dimZ = dim("z", first.Dz)
+		dataDims = append(dataDims, dimZ)
+	}
+	dimY := dim("y", first.Dy)
+	dimX := dim("x", first.Dx)
+	dataDims = append(dataDims, dimY, dimX)
+
+	// variables
+	epoch := first.ForecastTime.UTC().Truncate(24 * time.Hour)
+	vars := []ncVar{
+		{
+			name: "time", dims: []int{dimTime}, typ: ncDouble, size: int64(len(cs)) * 8,
+			attrs: []ncAttr{
+				{"standard_name", "time"},
+				{"long_name", "forecast time"},
+				{"units", "minutes since " + epoch.Format("2006-01-02 15:04:05")},
+				{"calendar", "standard"},
+			},
+			write: func(w io.Writer) error {
+				for _, c := range cs {
+					if err := binary.Write(w, binary.BigEndian, c.ForecastTime.Sub(epoch).Minutes()); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "y", dims: []int{dimY}, typ: ncDouble, size: int64(first.Dy) * 8,
+			attrs: []ncAttr{
+				{"standard_name", "projection_y_coordinate"},
+				{"units", "km"},
+			},
+			write: func(w io.Writer) error {
+				for y := 0; y < first.Dy; y++ { // northing of pixel center
+					_, northing := first.planeCoordinates(0, float64(y)+0.5)
+					if err := binary.Write(w, binary.BigEndian, northing); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "x", dims: []int{dimX}, typ: ncDouble, size: int64(first.Dx) * 8,
+			attrs: []ncAttr{
+				{"standard_name", "projection_x_coordinate"},
+				{"units", "km"},
+			},
+			write: func(w io.Writer) error {
+				for x := 0; x < first.Dx; x++ { // easting of pixel center
+					easting, _ := first.planeCoordinates(float64(x)+0.5, 0)
+					if err := binary.Write(w, binary.BigEndian, easting); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "lat", dims: []int{dimY, dimX}, typ: ncFloat, size: int64(first.Dx*first.Dy) * 4,
+			attrs: []ncAttr{
+				{"standard_name", "latitude"},
+				{"units", "degrees_north"},
+			},
+			write: func(w io.Writer) error {
+				return first.writeCoordinates(w, false)
+			},
+		},
+		{
+			name: "lon", dims: []int{dimY, dimX}, typ: ncFloat, size: int64(first.Dx*first.Dy) * 4,
+			attrs: []ncAttr{
+				{"standard_name", "longitude"},
+				{"units", "degrees_east"},
+			},
+			write: func(w io.Writer) error {
+				return first.writeCoordinates(w, true)
+			},
+		},
+		{
+			name: "polar_stereographic", typ: ncInt, size: 4,
+			attrs: first.gridMapping(),
+			write: func(w io.Writer) error {
+				return binary.Write(w, binary.BigEndian, int32(0))
+			},
+		},
+	}
+
+	if dimZ != -1 {
+		vars = append(vars, ncVar{
+			name: "z", dims: []int{dimZ}, typ: ncInt, size: int64(first.Dz) * 4,
+			attrs: []ncAttr{
+				{"long_name", "layer"},
+			},
+			write: func(w io.Writer) error {
+				for z := 0; z < first.Dz; z++ {
+					if err := binary.Write(w, binary.BigEndian, int32(z)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	dataAttrs := []ncAttr{
+		{"long_name", "RADOLAN " + first.Product},
+		{"_FillValue", NaN},
+		{"grid_mapping", "polar_stereographic"},
+		{"coordinates", "lat lon"},
+	}
+	if first.DataUnit != Unit_unknown {
+		dataAttrs = append(dataAttrs, ncAttr{"units", first.DataUnit.String()})
+	}
+	vars = append(vars, ncVar{
+		name: first.Product, dims: dataDims, typ: ncFloat,
+		size:  int64(len(cs)*first.Dz*first.Dy*first.Dx) * 4,
+		attrs: dataAttrs,
+		write: func(w io.Writer) error {
+			row := make([]byte, first.Dx*4)
+			for _, c := range cs {
+				for z := 0; z < c.Dz; z++ {
+					for y := 0; y < c.Dy; y++ {
+						for x, v := range c.DataZ[z][y] {
+							binary.BigEndian.PutUint32(row[x*4:], math.Float32bits(v))
+						}
+						if _, err := w.Write(row); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return nil
+		},
+	})
+
+	globalAttrs := []ncAttr{
+		{"Conventions", "CF-1.7"},
+		{"title", "RADOLAN " + first.Product + " composite"},
+		{"source", "Deutscher Wetterdienst"},
+	}
+
+	// header size does not depend on the values of begin
+	header := encodeNetCDFHeader(dimNames, dimLengths, globalAttrs, vars, nil)
+	begin := make([]int64, len(vars))
+	offset := int64(len(header))
+	for i, v := range vars {
+		begin[i] = offset
+		offset += ncPadding(v.size)
+	}
+	header = encodeNetCDFHeader(dimNames, dimLengths, globalAttrs, vars, begin)
+
+	w := bufio.NewWriter(wr)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	// data
+	for _, v := range vars {
+		if err := v.write(w); err != nil {
+			return err
+		}
+		if pad := ncPadding(v.size) - v.size; pad > 0 {
+			if _, err := w.Write(make([]byte, pad)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+// writeCoordinates writes the latitude (or longitude if lon is set) of each
+// pixel center as big endian float values to w.
+func (c *Composite) writeCoordinates(w io.Writer, lon bool) error {
+	row := make([]byte, c.Dx*4)
+	for y := 0; y < c.Dy; y++ {
+		for x := 0; x < c.Dx; x++ {
+			north, east := c.PixelCenter(x, y)
+			v := north
+			if lon {
+				v = east
+			}
+			binary.BigEndian.PutUint32(row[x*4:], math.Float32bits(float32(v)))
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gridMapping returns the attributes of the CF grid mapping variable describing
+// the polar stereographic projection of the composite. The false easting and
+// northing are given in km.
+func (c *Composite) gridMapping() []ncAttr {
+	p := c.proj_wgs84
+	if p == nil { // sphere
+		return []ncAttr{
+			{"grid_mapping_name", "polar_stereographic"},
+			{"straight_vertical_longitude_from_pole", junctionEast},
+			{"latitude_of_projection_origin", 90.0},
+			{"standard_parallel", junctionNorth},
+			{"false_easting", 0.0},
+			{"false_northing", 0.0},
+			{"earth_radius", earthRadius * 1000},
+		}
+	}
+
+	attrs := []ncAttr{ // ellipsoid
+		{"grid_mapping_name", "polar_stereographic"},
+		{"straight_vertical_longitude_from_pole", p.lon_0 / degToRad},
+		{"latitude_of_projection_origin", 90.0},
+		{"standard_parallel", p.lat_ts},
+		{"false_easting", p.x_0 / p.scale},
+		{"false_northing", p.y_0 / p.scale},
+		{"semi_major_axis", p.a},
+	}
+	if p.k != 1 { // true scale at the pole is expressed by the scale factor
+		attrs[3] = ncAttr{"scale_factor_at_projection_origin", p.k}
+	}
+	if p.a == p.b {
+		return attrs
+	}
+	return append(attrs, ncAttr{"inverse_flattening", p.a / (p.a - p.b)})
+}
+
+// encodeNetCDFHeader returns the header of a netcdf file in 64-bit offset
+// format holding only non-record variables. The data of each variable begins
+// at the given offset.
+func encodeNetCDFHeader(dimNames []string, dimLengths []int, attrs []ncAttr, vars []ncVar, begin []int64) []byte {
+	var buf bytes.Buffer
+	put := func(v interface{}) {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+
+	buf.WriteString("CDF\x02")
+	put(int32(0)) // number of records
+
+	put(int32(ncDimension))
+	put(int32(len(dimNames)))
+	for i, name := range dimNames {
+		ncPutName(&buf, name)
+		put(int32(dimLengths[i]))
+	}
+
+	ncPutAttrs(&buf, attrs)
+
+	put(int32(ncVariable))
+	put(int32(len(vars)))
+	for i, v := range vars {
+		ncPutName(&buf, v.name)
+		put(int32(len(v.dims)))
+		for _, d := range v.dims {
+			put(int32(d))
+		}
+		ncPutAttrs(&buf, v.attrs)
+		put(int32(v.typ))
+
+		vsize := ncPadding(v.size)
+		if vsize > math.MaxUint32 {
+			vsize = math.MaxUint32
+		}
+		put(uint32(vsize))
+
+		var offset int64
+		if begin != nil {
+			offset = begin[i]
+		}
+		put(offset)
+	}
+
+	return buf.Bytes()
+}
+
+// ncPutName writes the length prefixed and padded name to buf.
+func ncPutName(buf *bytes.Buffer, name string) {
+	binary.Write(buf, binary.BigEndian, int32(len(name)))
+	buf.WriteString(name)
+	buf.Write(make([]byte, ncPadding(int64(len(name)))-int64(len(name))))
+}
+
+// ncPutAttrs writes the attribute list to buf.
+func ncPutAttrs(buf *bytes.Buffer, attrs []ncAttr) {
+	put := func(v interface{}) {
+		binary.Write(buf, binary.BigEndian, v)
+	}
+
+	if len(attrs) == 0 { // absent
+		put(int64(0))
+		return
+	}
+
+	put(int32(ncAttribute))
+	put(int32(len(attrs)))
+	for _, a := range attrs {
+		ncPutName(buf, a.name)
+
+		switch v := a.value.(type) {
+		case string:
+			put(int32(ncChar))
+			ncPutName(buf, v)
+		case int32:
+			put(int32(ncInt))
+			put(int32(1))
+			put(v)
+		case float32:
+			put(int32(ncFloat))
+			put(int32(1))
+			put(v)
+		case float64:
+			put(int32(ncDouble))
+			put(int32(1))
+			put(v)
+		}
+	}
+}
+
+// ncPadding returns the given size rounded up to the next multiple of four.
+func ncPadding(size int64) int64 {
+	return (size + 3) &^ 3
+}
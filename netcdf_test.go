@@ -0,0 +1,246 @@
+package radolan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+type ncTestVar struct {
+	dims  []int
+	attrs map[string]interface{}
+	typ   int
+	begin int64
+}
+
+// readNetCDFHeader parses the header of the given netcdf file in 64-bit offset format.
+func readNetCDFHeader(t *testing.T, file []byte) (dims []string, lengths []int, attrs map[string]interface{}, vars map[string]ncTestVar) {
+	t.Helper()
+	rd := bytes.NewReader(file)
+	get := func(v interface{}) {
+		if err := binary.Read(rd, binary.BigEndian, v); err != nil {
+			t.Fatalf("netcdf header corrupted: %#v", err.Error())
+		}
+	}
+	getInt := func() int {
+		var v int32
+		get(&v)
+		return int(v)
+	}
+	getName := func() string {
+		b := make([]byte, ncPadding(int64(getInt())))
+		get(b)
+		return string(bytes.TrimRight(b, "\x00"))
+	}
+	getAttrs := func() map[string]interface{} {
+		m := make(map[string]interface{})
+		tag, n := getInt(), getInt()
+		if tag != ncAttribute && (tag != 0 || n != 0) {
+			t.Fatalf("netcdf header corrupted: invalid attribute tag %d", tag)
+		}
+		for i := 0; i < n; i++ {
+			name := getName()
+			switch typ := getInt(); typ {
+			case ncChar:
+				m[name] = getName()
+			case ncInt:
+				getInt()
+				m[name] = int32(getInt())
+			case ncFloat:
+				var v float32
+				getInt()
+				get(&v)
+				m[name] = v
+			case ncDouble:
+				var v float64
+				getInt()
+				get(&v)
+				m[name] = v
+			default:
+				t.Fatalf("netcdf header corrupted: invalid attribute type %d", typ)
+			}
+		}
+		return m
+	}
+
+	magic := make([]byte, 4)
+	get(magic)
+	if string(magic) != "CDF\x02" {
+		t.Fatalf("invalid netcdf magic: %#v", magic)
+	}
+	getInt() // number of records
+
+	if tag := getInt(); tag != ncDimension {
+		t.Fatalf("netcdf header corrupted: invalid dimension tag %d", tag)
+	}
+	for i, n := 0, getInt(); i < n; i++ {
+		dims = append(dims, getName())
+		lengths = append(lengths, getInt())
+	}
+
+	attrs = getAttrs()
+
+	vars = make(map[string]ncTestVar)
+	if tag := getInt(); tag != ncVariable {
+		t.Fatalf("netcdf header corrupted: invalid variable tag %d", tag)
+	}
+	for i, n := 0, getInt(); i < n; i++ {
+		var v ncTestVar
+		name := getName()
+		for j, m := 0, getInt(); j < m; j++ {
+			v.dims = append(v.dims, getInt())
+		}
+		v.attrs = getAttrs()
+		v.typ = getInt()
+		getInt() // vsize
+		get(&v.begin)
+		vars[name] = v
+	}
+	return
+}
+
+func TestEncodeNetCDF(t *testing.T) {
+	// time series of single layer composites
+	var cs []*Composite
+	for i := 0; i < 3; i++ {
+		c := newTestComposite("RW", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 {
+			if x == y {
+				return NaN
+			}
+			return float32(x*1000 + y + i)
+		})
+		c.ForecastTime = c.ForecastTime.Add(time.Duration(i) * time.Hour)
+		cs = append(cs, c)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeNetCDF(&buf, cs); err != nil {
+		t.Fatalf("EncodeNetCDF(): returned error: %#v", err.Error())
+	}
+	file := buf.Bytes()
+	dims, lengths, attrs, vars := readNetCDFHeader(t, file)
+
+	if len(dims) != 3 || dims[0] != "time" || dims[1] != "y" || dims[2] != "x" ||
+		lengths[0] != 3 || lengths[1] != 450 || lengths[2] != 450 {
+		t.Errorf("EncodeNetCDF(): dimensions: %#v %#v; expected: time(3) y(450) x(450)", dims, lengths)
+	}
+	if attrs["Conventions"] != "CF-1.7" {
+		t.Errorf("EncodeNetCDF(): Conventions: %#v; expected: \"CF-1.7\"", attrs["Conventions"])
+	}
+
+	for _, name := range []string{"time", "y", "x", "lat", "lon", "polar_stereographic", "RW"} {
+		if _, ok := vars[name]; !ok {
+			t.Fatalf("EncodeNetCDF(): variable %s missing", name)
+		}
+	}
+
+	rw := vars["RW"]
+	if len(rw.dims) != 3 || rw.typ != ncFloat || rw.attrs["units"] != "mm" ||
+		rw.attrs["grid_mapping"] != "polar_stereographic" || rw.attrs["coordinates"] != "lat lon" {
+		t.Errorf("EncodeNetCDF(): RW: %#v", rw)
+	}
+
+	gm := vars["polar_stereographic"].attrs
+	if gm["grid_mapping_name"] != "polar_stereographic" || gm["standard_parallel"] != 60.0 ||
+		gm["straight_vertical_longitude_from_pole"] != 10.0 || gm["earth_radius"] != 6370040.0 {
+		t.Errorf("EncodeNetCDF(): polar_stereographic: %#v", gm)
+	}
+
+	// data values
+	for i, c := range cs {
+		for _, p := range [][2]int{{0, 0}, {10, 20}, {449, 3}} {
+			x, y := p[0], p[1]
+			offset := rw.begin + int64(((i*c.Dy+y)*c.Dx+x)*4)
+			res := math.Float32frombits(binary.BigEndian.Uint32(file[offset:]))
+			if exp := c.Data[y][x]; res != exp && !(IsNaN(res) && IsNaN(exp)) {
+				t.Errorf("EncodeNetCDF(): RW[%d][%d][%d] = %#v; expected: %#v", i, y, x, res, exp)
+			}
+		}
+	}
+
+	// time values
+	units := vars["time"].attrs["units"]
+	if units != "minutes since 2016-07-31 00:00:00" {
+		t.Errorf("EncodeNetCDF(): time units: %#v", units)
+	}
+	for i := range cs {
+		res := math.Float64frombits(binary.BigEndian.Uint64(file[vars["time"].begin+int64(i*8):]))
+		if exp := float64(17*60 + 5 + i*60); res != exp {
+			t.Errorf("EncodeNetCDF(): time[%d] = %#v; expected: %#v", i, res, exp)
+		}
+	}
+
+	// pixel center coordinates
+	lat := math.Float32frombits(binary.BigEndian.Uint32(file[vars["lat"].begin:]))
+	lon := math.Float32frombits(binary.BigEndian.Uint32(file[vars["lon"].begin:]))
+	if north, east := cs[0].PixelCenter(0, 0); lat != float32(north) || lon != float32(east) {
+		t.Errorf("EncodeNetCDF(): lat, lon [0][0] = %#v, %#v; expected: %#v, %#v", lat, lon, north, east)
+	}
+}
+
+func TestEncodeNetCDFLayers(t *testing.T) {
+	// composite with two layers on the national grid
+	c := newTestComposite("PZ", 0, 200, 2400, 0, []float32{1, 2, 3}, func(c *Composite, x, y int) float32 {
+		return c.level[(x+y)%3]
+	})
+	c.Px, c.Py, c.Dx, c.Dy = 450, 900, 450, 450
+	c.allocData(false)
+	for i := range c.Raw {
+		c.Raw[i] = float32(i)
+	}
+	c.arrangeData()
+	c.calibrateProjection()
+
+	var buf bytes.Buffer
+	if err := EncodeNetCDF(&buf, []*Composite{c}); err != nil {
+		t.Fatalf("EncodeNetCDF(): returned error: %#v", err.Error())
+	}
+	file := buf.Bytes()
+	dims, lengths, _, vars := readNetCDFHeader(t, file)
+
+	if len(dims) != 4 || dims[1] != "z" || lengths[1] != 2 {
+		t.Fatalf("EncodeNetCDF(): dimensions: %#v %#v; expected: time(1) z(2) y(450) x(450)", dims, lengths)
+	}
+
+	pz := vars["PZ"]
+	if len(pz.dims) != 4 || pz.dims[1] != 1 {
+		t.Errorf("EncodeNetCDF(): PZ dimensions: %#v", pz.dims)
+	}
+	for _, i := range []int{0, 450*450 - 1, 450 * 450, 2*450*450 - 1} {
+		res := math.Float32frombits(binary.BigEndian.Uint32(file[pz.begin+int64(i*4):]))
+		if res != float32(i) {
+			t.Errorf("EncodeNetCDF(): PZ value %d = %#v; expected: %#v", i, res, float32(i))
+		}
+	}
+
+	if err := EncodeNetCDF(&buf, []*Composite{NewDummy("PX", 0, 200, 224)}); err == nil {
+		t.Errorf("EncodeNetCDF(PX): expected error for missing projection")
+	}
+}
+
+func TestEncodeNetCDFScaleFactor(t *testing.T) {
+	defer func(grids []gridDefinition) { registeredGrids = grids }(registeredGrids)
+
+	err := RegisterGrid(GridDefinition{
+		Dx: 300, Dy: 500, MinFormat: 5,
+		Proj4: "+proj=stere +lat_0=90 +lat_ts=90 +lon_0=10 +k=0.97 +ellps=WGS84",
+		North: 55, West: 3,
+		South: 47, East: 15,
+	})
+	if err != nil {
+		t.Fatalf("RegisterGrid() returned error: %#v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeNetCDF(&buf, []*Composite{NewDummy("WN", 5, 300, 500)}); err != nil {
+		t.Fatalf("EncodeNetCDF(): returned error: %#v", err.Error())
+	}
+	_, _, _, vars := readNetCDFHeader(t, buf.Bytes())
+
+	gm := vars["polar_stereographic"].attrs
+	if _, ok := gm["standard_parallel"]; ok || gm["scale_factor_at_projection_origin"] != 0.97 {
+		t.Errorf("EncodeNetCDF(): polar_stereographic: %#v; expected scale factor 0.97", gm)
+	}
+}
@@ -0,0 +1,341 @@
+package nowcast
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Field is a motion field estimated for a grid of square blocks. The velocity
+// of block (i, j) is stored at index j*Cols + i in pixels per minute, where U
+// increases to the right (east) and V downwards (south). The center of the
+// block is located at the data indices ((i+0.5)*Block, (j+0.5)*Block).
+type Field struct {
+	Width, Height int // dimensions of the composites in pixels
+	Block         int // block size in pixels
+	Cols, Rows    int // number of blocks
+
+	U, V []float64
+}
+
+// newField returns a motion field at rest for composites of the given
+// dimensions.
+func newField(width, height, block int) *Field {
+	cols := (width + block - 1) / block
+	rows := (height + block - 1) / block
+	return &Field{
+		Width:  width,
+		Height: height,
+		Block:  block,
+		Cols:   cols,
+		Rows:   rows,
+		U:      make([]float64, cols*rows),
+		V:      make([]float64, cols*rows),
+	}
+}
+
+// At returns the velocity at the given data indices in pixels per minute. It
+// is interpolated bilinearly between the block centers and constant beyond
+// the outermost block centers.
+func (f *Field) At(x, y float64) (u, v float64) {
+	// relative to the block centers
+	fx := clamp(x/float64(f.Block)-0.5, 0, float64(f.Cols-1))
+	fy := clamp(y/float64(f.Block)-0.5, 0, float64(f.Rows-1))
+
+	i0, j0 := int(fx), int(fy)
+	i1, j1 := min(i0+1, f.Cols-1), min(j0+1, f.Rows-1)
+	tx, ty := fx-float64(i0), fy-float64(j0)
+
+	interpolate := func(a []float64) float64 {
+		top := a[j0*f.Cols+i0]*(1-tx) + a[j0*f.Cols+i1]*tx
+		bottom := a[j1*f.Cols+i0]*(1-tx) + a[j1*f.Cols+i1]*tx
+		return top*(1-ty) + bottom*ty
+	}
+	return interpolate(f.U), interpolate(f.V)
+}
+
+// Motion estimates the motion field of the echoes in the composites, which
+// must share the same grid, hold a single layer of reflectivity (dBZ) and be
+// ordered by ForecastTime. Precipitation products have to be converted by
+// radolan.Reflectivity beforehand, as the threshold and matching assume a
+// logarithmic scale. The displacement of
+// each block is determined by block matching of each pair of consecutive
+// composites, minimizing the mean squared difference of the values, and
+// refined to sub-pixel accuracy. The velocities of all pairs are averaged.
+// Blocks without sufficient echoes are filled from their neighbours and the
+// field is smoothed. nil options are replaced by DefaultOptions.
+func Motion(cs []*radolan.Composite, opts *Options) (*Field, error) {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if len(cs) < 2 {
+		return nil, newError("Motion", "at least two composites required")
+	}
+	if o.Block < 1 || o.Search < 1 {
+		return nil, newError("Motion", "invalid block size or search range")
+	}
+	for i, c := range cs {
+		if c.Dx != cs[0].Dx || c.Dy != cs[0].Dy {
+			return nil, newError("Motion", "composites do not share the same grid")
+		}
+		if c.Dz != 1 {
+			return nil, newError("Motion", "composite holds no single layer data")
+		}
+		if c.DataUnit != radolan.Unit_dBZ {
+			return nil, newError("Motion", "composite holds no reflectivity (dBZ)")
+		}
+		if i > 0 && !c.ForecastTime.After(cs[i-1].ForecastTime) {
+			return nil, newError("Motion", "composites are not in chronological order")
+		}
+	}
+
+	f := newField(cs[0].Dx, cs[0].Dy, o.Block)
+	n := make([]int, len(f.U)) // number of matched pairs per block
+
+	prev := echoes(cs[0], o.Threshold)
+	for i := 1; i < len(cs); i++ {
+		next := echoes(cs[i], o.Threshold)
+		minutes := cs[i].ForecastTime.Sub(cs[i-1].ForecastTime).Minutes()
+
+		f.match(prev, next, &o, func(b int, dx, dy float64) {
+			f.U[b] += dx / minutes
+			f.V[b] += dy / minutes
+			n[b]++
+		})
+		prev = next
+	}
+
+	valid := make([]bool, len(n))
+	for b := range n {
+		if n[b] > 0 {
+			f.U[b] /= float64(n[b])
+			f.V[b] /= float64(n[b])
+			valid[b] = true
+		}
+	}
+
+	f.fill(valid)
+	for i := 0; i < o.Smooth; i++ {
+		f.smooth()
+	}
+	return f, nil
+}
+
+// echoes returns the first layer of the composite with values below the
+// threshold and missing values replaced by the threshold.
+func echoes(c *radolan.Composite, threshold float64) [][]float32 {
+	t := float32(threshold)
+	layer := make([][]float32, c.Dy)
+	for y := range layer {
+		layer[y] = make([]float32, c.Dx)
+		for x, v := range c.DataZ[0][y] {
+			if radolan.IsNaN(v) || v < t {
+				v = t
+			}
+			layer[y][x] = v
+		}
+	}
+	return layer
+}
+
+// match determines the displacement of each block of prev in next and reports
+// it by the found function. Blocks with insufficient echoes are skipped. The
+// blocks are processed in parallel, but found is called sequentially.
+func (f *Field) match(prev, next [][]float32, o *Options, found func(b int, dx, dy float64)) {
+	threshold := float32(o.Threshold)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	rows := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range rows {
+				for i := 0; i < f.Cols; i++ {
+					dx, dy, ok := f.matchBlock(prev, next, i, j, o.Search, threshold, o.MinEchoes)
+					if ok {
+						mu.Lock()
+						found(j*f.Cols+i, dx, dy)
+						mu.Unlock()
+					}
+				}
+			}
+		}()
+	}
+
+	for j := 0; j < f.Rows; j++ {
+		rows <- j
+	}
+	close(rows)
+	wg.Wait()
+}
+
+// matchBlock returns the displacement of block (i, j) of prev in next.
+func (f *Field) matchBlock(prev, next [][]float32, i, j, search int, threshold float32, minEchoes float64) (dx, dy float64, ok bool) {
+	x0, y0 := i*f.Block, j*f.Block
+	x1, y1 := min(x0+f.Block, f.Width), min(y0+f.Block, f.Height)
+
+	count := 0
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if prev[y][x] > threshold {
+				count++
+			}
+		}
+	}
+	if float64(count) < minEchoes*float64((x1-x0)*(y1-y0)) || count == 0 {
+		return 0, 0, false
+	}
+
+	// mean squared difference for each displacement
+	size := 2*search + 1
+	costs := make([]float64, size*size)
+	cost := func(sx, sy int) float64 {
+		return costs[(sy+search)*size+sx+search]
+	}
+
+	best, bestX, bestY := math.Inf(1), 0, 0
+	for sy := -search; sy <= search; sy++ {
+		for sx := -search; sx <= search; sx++ {
+			var sum float64
+			var n int
+			for y := y0; y < y1; y++ {
+				ny := y + sy
+				if ny < 0 || ny >= f.Height {
+					continue
+				}
+				for x := x0; x < x1; x++ {
+					nx := x + sx
+					if nx < 0 || nx >= f.Width {
+						continue
+					}
+					d := float64(next[ny][nx] - prev[y][x])
+					sum += d * d
+					n++
+				}
+			}
+
+			c := math.Inf(1)
+			if 2*n >= (x1-x0)*(y1-y0) { // at least half of the block is compared
+				c = sum / float64(n)
+			}
+			costs[(sy+search)*size+sx+search] = c
+
+			// prefer small displacements on equal costs
+			if c < best || c == best && sx*sx+sy*sy < bestX*bestX+bestY*bestY {
+				best, bestX, bestY = c, sx, sy
+			}
+		}
+	}
+	if math.IsInf(best, 1) {
+		return 0, 0, false
+	}
+
+	// sub-pixel refinement by fitting a parabola in each direction, unless
+	// the match is exact
+	dx, dy = float64(bestX), float64(bestY)
+	if best == 0 {
+		return dx, dy, true
+	}
+	if bestX > -search && bestX < search {
+		dx += vertex(cost(bestX-1, bestY), best, cost(bestX+1, bestY))
+	}
+	if bestY > -search && bestY < search {
+		dy += vertex(cost(bestX, bestY-1), best, cost(bestX, bestY+1))
+	}
+	return dx, dy, true
+}
+
+// vertex returns the offset of the vertex of the parabola through (-1, a),
+// (0, b) and (1, c) in the range -0.5 to 0.5.
+func vertex(a, b, c float64) float64 {
+	d := a - 2*b + c
+	if d <= 0 || math.IsInf(a, 0) || math.IsInf(c, 0) {
+		return 0
+	}
+	return clamp(0.5*(a-c)/d, -0.5, 0.5)
+}
+
+// fill replaces the velocities of invalid blocks by the mean of their valid
+// neighbours, growing the valid area until all blocks are filled. If no block
+// is valid, the field remains at rest.
+func (f *Field) fill(valid []bool) {
+	for {
+		var filled []int
+		for j := 0; j < f.Rows; j++ {
+			for i := 0; i < f.Cols; i++ {
+				b := j*f.Cols + i
+				if valid[b] {
+					continue
+				}
+
+				var u, v float64
+				var n int
+				f.neighbours(i, j, func(nb int) {
+					if valid[nb] {
+						u += f.U[nb]
+						v += f.V[nb]
+						n++
+					}
+				})
+				if n > 0 {
+					f.U[b], f.V[b] = u/float64(n), v/float64(n)
+					filled = append(filled, b)
+				}
+			}
+		}
+
+		if len(filled) == 0 {
+			return
+		}
+		for _, b := range filled {
+			valid[b] = true
+		}
+	}
+}
+
+// smooth replaces each velocity by the mean of the block and its neighbours.
+func (f *Field) smooth() {
+	u := make([]float64, len(f.U))
+	v := make([]float64, len(f.V))
+	for j := 0; j < f.Rows; j++ {
+		for i := 0; i < f.Cols; i++ {
+			b := j*f.Cols + i
+			su, sv, n := f.U[b], f.V[b], 1
+			f.neighbours(i, j, func(nb int) {
+				su += f.U[nb]
+				sv += f.V[nb]
+				n++
+			})
+			u[b], v[b] = su/float64(n), sv/float64(n)
+		}
+	}
+	f.U, f.V = u, v
+}
+
+// neighbours calls fn with the index of each of the up to eight neighbours of
+// block (i, j).
+func (f *Field) neighbours(i, j int, fn func(b int)) {
+	for nj := j - 1; nj <= j+1; nj++ {
+		for ni := i - 1; ni <= i+1; ni++ {
+			if (ni != i || nj != j) && ni >= 0 && nj >= 0 && ni < f.Cols && nj < f.Rows {
+				fn(nj*f.Cols + ni)
+			}
+		}
+	}
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,126 @@
+// Package nowcast creates short-term precipitation forecasts (nowcasts) by
+// extrapolating the motion of radar echoes. The motion field is estimated by
+// block matching of consecutive reflectivity composites like RX or WN. The
+// latest composite is then advected along the motion field in semi-Lagrangian
+// steps, so that the forecasts follow curved trajectories.
+//
+// The method assumes stationary motion and does not model growth or decay of
+// echoes. Its skill therefore decreases quickly with increasing lead time and
+// is typically limited to about two hours.
+package nowcast
+
+import (
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"time"
+)
+
+// Options configures the estimation of the motion field and the
+// extrapolation.
+type Options struct {
+	Block     int     // block size in pixels
+	Search    int     // maximum displacement between consecutive composites in pixels
+	Threshold float64 // values below (and missing values) are treated as no echo
+	MinEchoes float64 // minimum fraction of echo pixels for a block to be matched (0 - 1)
+	Smooth    int     // number of smoothing passes applied to the motion field
+
+	Step    time.Duration // lead time step (interval of the latest composite if zero)
+	Lead    time.Duration // maximum lead time
+	Product string        // product label of the forecasts (product of the latest composite if empty)
+}
+
+// DefaultOptions are used when no options are given. The threshold of 5 dBZ
+// suppresses clutter and noise in reflectivity composites.
+var DefaultOptions = Options{
+	Block:     16,
+	Search:    12,
+	Threshold: 5,
+	MinEchoes: 0.05,
+	Smooth:    2,
+	Lead:      2 * time.Hour,
+}
+
+// Nowcast estimates the motion field of the composites and extrapolates the
+// latest composite. It combines Motion and Extrapolate.
+func Nowcast(cs []*radolan.Composite, opts *Options) ([]*radolan.Composite, error) {
+	f, err := Motion(cs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return Extrapolate(cs[len(cs)-1], f, opts)
+}
+
+// Extrapolate advects the single layer composite along the motion field
+// and returns a forecast composite for each lead time step up to the maximum
+// lead time. The forecasts retain the grid and unit of the composite. Their
+// CaptureTime is the ForecastTime of the composite and their ForecastTime is
+// advanced by the lead time. Values are interpolated bilinearly; pixels whose
+// trajectory originates outside of the composite are missing (NaN). nil
+// options are replaced by DefaultOptions.
+func Extrapolate(c *radolan.Composite, f *Field, opts *Options) ([]*radolan.Composite, error) {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	step := o.Step
+	if step <= 0 {
+		step = c.Interval
+	}
+	if step <= 0 {
+		return nil, newError("Extrapolate", "no lead time step given")
+	}
+	if c.Dz != 1 || c.PlainData == nil {
+		return nil, newError("Extrapolate", "composite holds no single layer data")
+	}
+	if f.Width != c.Dx || f.Height != c.Dy {
+		return nil, newError("Extrapolate", "motion field does not match composite")
+	}
+
+	product := o.Product
+	if product == "" {
+		product = c.Product
+	}
+	minutes := step.Minutes()
+
+	// origin of the trajectory ending at each pixel center
+	posX := make([]float64, c.Dx*c.Dy)
+	posY := make([]float64, c.Dx*c.Dy)
+	for y := 0; y < c.Dy; y++ {
+		for x := 0; x < c.Dx; x++ {
+			posX[y*c.Dx+x] = float64(x) + 0.5
+			posY[y*c.Dx+x] = float64(y) + 0.5
+		}
+	}
+
+	var forecasts []*radolan.Composite
+	for lead := step; lead <= o.Lead; lead += step {
+		fc := c.Copy()
+		fc.Product = product
+		fc.CaptureTime = c.ForecastTime
+		fc.ForecastTime = c.ForecastTime.Add(lead)
+		fc.Interval = step
+
+		for y := 0; y < c.Dy; y++ {
+			for x := 0; x < c.Dx; x++ {
+				i := y*c.Dx + x
+
+				// trace back by one step (semi-Lagrangian)
+				u, v := f.At(posX[i], posY[i])
+				posX[i] -= u * minutes
+				posY[i] -= v * minutes
+
+				fc.DataZ[0][y][x] = radolan.Bilinear(c, posX[i], posY[i], 0)
+			}
+		}
+
+		forecasts = append(forecasts, fc)
+	}
+
+	return forecasts, nil
+}
+
+// newError returns an error indicating the failed function and reason
+func newError(function, reason string) error {
+	return fmt.Errorf("nowcast.%s: %s", function, reason)
+}
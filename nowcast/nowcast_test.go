@@ -0,0 +1,200 @@
+package nowcast
+
+import (
+	"bytes"
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC)
+
+// echo returns the reflectivity (dBZ) of two gaussian cells centered around
+// (cx, cy).
+func echo(x, y, cx, cy float64) float32 {
+	gauss := func(dx, dy, sigma float64) float64 {
+		return math.Exp(-(dx*dx + dy*dy) / (2 * sigma * sigma))
+	}
+	return float32(50*gauss(x-cx, y-cy, 10) + 35*gauss(x-cx-25, y-cy+10, 6))
+}
+
+// testComposite returns a RX composite showing the cells moving by (u, v)
+// pixels per minute, located at (cx, cy) at base time.
+func testComposite(t *testing.T, forecast time.Time, cx, cy, u, v float64) *radolan.Composite {
+	minutes := forecast.Sub(base).Minutes()
+	cx += u * minutes
+	cy += v * minutes
+
+	c := radolan.NewDummy("RX", 3, 300, 300)
+	c.CaptureTime = forecast
+	c.ForecastTime = forecast
+	c.Interval = 5 * time.Minute
+	c.DataUnit = radolan.Unit_dBZ
+	c.Px, c.Py = c.Dx, c.Dy
+	c.PlainData = make([][]float32, c.Py)
+	for y := range c.PlainData {
+		c.PlainData[y] = make([]float32, c.Px)
+		for x := range c.PlainData[y] {
+			c.PlainData[y][x] = echo(float64(x)+0.5, float64(y)+0.5, cx, cy)
+		}
+	}
+
+	// encode and parse to obtain a regular composite
+	var buf bytes.Buffer
+	if err := c.Encode(&buf); err != nil {
+		t.Fatalf("Encode(): returned error: %#v", err.Error())
+	}
+	comp, err := radolan.NewComposite(&buf)
+	if err != nil {
+		t.Fatalf("NewComposite(): returned error: %#v", err.Error())
+	}
+	return comp
+}
+
+// peak returns the data indices of the maximum value.
+func peak(c *radolan.Composite) (px, py int) {
+	max := float32(math.Inf(-1))
+	for y, row := range c.Data {
+		for x, v := range row {
+			if !radolan.IsNaN(v) && v > max {
+				max, px, py = v, x, y
+			}
+		}
+	}
+	return
+}
+
+func TestMotion(t *testing.T) {
+	const u, v = 0.6, -0.4 // px/min
+
+	var cs []*radolan.Composite
+	for i := 0; i < 3; i++ {
+		cs = append(cs, testComposite(t, base.Add(time.Duration(i)*5*time.Minute), 120, 150, u, v))
+	}
+
+	f, err := Motion(cs, nil)
+	if err != nil {
+		t.Fatalf("Motion(): returned error: %#v", err.Error())
+	}
+
+	// near the cells and at the far edge, where the motion is filled in
+	for _, p := range [][2]float64{{125, 144}, {140, 140}, {290, 10}} {
+		ru, rv := f.At(p[0], p[1])
+		if math.Abs(ru-u) > 0.05 || math.Abs(rv-v) > 0.05 {
+			t.Errorf("Motion().At(%v, %v) = (%.3f, %.3f); expected: (%.3f, %.3f)", p[0], p[1], ru, rv, u, v)
+		}
+	}
+
+	// errors
+	if _, err := Motion(cs[:1], nil); err == nil {
+		t.Errorf("Motion(): single composite: returned no error")
+	}
+	if _, err := Motion([]*radolan.Composite{cs[1], cs[0]}, nil); err == nil {
+		t.Errorf("Motion(): unordered composites: returned no error")
+	}
+
+	// precipitation and multi layer composites are rejected
+	rw := *cs[1]
+	rw.DataUnit = radolan.Unit_mm
+	if _, err := Motion([]*radolan.Composite{cs[0], &rw}, nil); err == nil {
+		t.Errorf("Motion(): precipitation composite: returned no error")
+	}
+	layers := *cs[1]
+	layers.Dz = 2
+	if _, err := Motion([]*radolan.Composite{cs[0], &layers}, nil); err == nil {
+		t.Errorf("Motion(): multi layer composite: returned no error")
+	}
+}
+
+func TestMotionAtRest(t *testing.T) {
+	cs := []*radolan.Composite{
+		testComposite(t, base, 150, 150, 0, 0),
+		testComposite(t, base.Add(5*time.Minute), 150, 150, 0, 0),
+	}
+
+	f, err := Motion(cs, nil)
+	if err != nil {
+		t.Fatalf("Motion(): returned error: %#v", err.Error())
+	}
+	for b := range f.U {
+		if f.U[b] != 0 || f.V[b] != 0 {
+			t.Fatalf("Motion(): block %d moves by (%v, %v); expected: (0, 0)", b, f.U[b], f.V[b])
+		}
+	}
+}
+
+func TestNowcast(t *testing.T) {
+	const u, v = 0.6, -0.4 // px/min
+
+	var cs []*radolan.Composite
+	for i := 0; i < 3; i++ {
+		cs = append(cs, testComposite(t, base.Add(time.Duration(i)*5*time.Minute), 120, 150, u, v))
+	}
+	latest := cs[len(cs)-1]
+
+	opts := DefaultOptions
+	opts.Lead = 30 * time.Minute
+	opts.Product = "FX"
+	fcs, err := Nowcast(cs, &opts)
+	if err != nil {
+		t.Fatalf("Nowcast(): returned error: %#v", err.Error())
+	}
+	if len(fcs) != 6 {
+		t.Fatalf("Nowcast(): %d forecasts; expected: 6", len(fcs))
+	}
+
+	for i, fc := range fcs {
+		lead := time.Duration(i+1) * 5 * time.Minute
+		if fc.Product != "FX" || !fc.CaptureTime.Equal(latest.ForecastTime) || !fc.ForecastTime.Equal(latest.ForecastTime.Add(lead)) {
+			t.Errorf("Nowcast(): forecast %d: %s %s %s; expected: FX %s %s", i, fc.Product, fc.CaptureTime, fc.ForecastTime,
+				latest.ForecastTime, latest.ForecastTime.Add(lead))
+		}
+
+		// compare with the observed cells at the forecast time (reference)
+		ref := testComposite(t, fc.ForecastTime, 120, 150, u, v)
+		fx, fy := peak(fc)
+		rx, ry := peak(ref)
+		if abs(fx-rx) > 1 || abs(fy-ry) > 1 {
+			t.Errorf("Nowcast(): forecast %d: peak at (%d, %d); expected: (%d, %d)", i, fx, fy, rx, ry)
+		}
+
+		var sum float64
+		var n int
+		for y := 100; y < 200; y++ {
+			for x := 70; x < 220; x++ {
+				d := float64(fc.Data[y][x] - ref.Data[y][x])
+				sum += d * d
+				n++
+			}
+		}
+		if rmse := math.Sqrt(sum / float64(n)); rmse > 1.5 {
+			t.Errorf("Nowcast(): forecast %d: rmse %.2f dBZ; expected: < 1.5 dBZ", i, rmse)
+		}
+	}
+
+	// the latest composite is not modified
+	if x, y := peak(latest); abs(x-126) > 1 || abs(y-146) > 1 {
+		t.Errorf("Nowcast(): latest composite modified: peak at (%d, %d)", x, y)
+	}
+}
+
+func TestExtrapolateErrors(t *testing.T) {
+	c := testComposite(t, base, 150, 150, 0, 0)
+
+	if _, err := Extrapolate(c, newField(100, 100, 16), nil); err == nil {
+		t.Errorf("Extrapolate(): mismatching field: returned no error")
+	}
+
+	c.Interval = 0
+	if _, err := Extrapolate(c, newField(c.Dx, c.Dy, 16), nil); err == nil {
+		t.Errorf("Extrapolate(): no step: returned no error")
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
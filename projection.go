@@ -1,6 +1,7 @@
 package radolan
 
 import (
+	"image"
 	"math"
 )
 
@@ -14,6 +15,7 @@ const (
 	extendedNationalGrid      // resolution: 900km * 1100km
 	DE1200Grid                // resolution: 1100km * 1200km
 	middleEuropeanGrid        // resolution: 1400km * 1500km
+	firstRegisteredGrid       // grids added by RegisterGrid
 )
 
 // minRes repeatedly bisects the given edges until no further step is possible
@@ -32,60 +34,123 @@ func minRes(dx, dy int) (rdx int, rdy int) {
 }
 
 // errNoProjection means that the projection grid could not be identified.
-var errNoProjection = newError("cornerPoints", "warning: unable to identify grid")
+var errNoProjection = newError("gridDefinition", "warning: unable to identify grid")
 
-// detectGrid identifies the used projection grid based on the composite dimensions
-func (c *Composite) detectGrid() grid {
-	dx, dy := minRes(c.Dx, c.Dy)
+// gridDefinition describes the placement of a grid by the geographical
+// coordinates of its outer corners and the used projection.
+type gridDefinition struct {
+	grid                 grid
+	dx, dy               int         // dimensions in pixels, multiples are matched as well (see minRes)
+	minFormat, maxFormat int         // range of format versions of matching composites
+	proj                 *projection // ellipsoid projection (nil: sphere described in [1])
 
-	if mx, my := minRes(900, 900); dx == mx && dy == my {
-		return nationalGrid
-	}
-	if mx, my := minRes(920, 920); dx == mx && dy == my {
-		return nationalPictureGrid
-	}
-	if mx, my := minRes(900, 1100); dx == mx && dy == my {
-		return extendedNationalGrid
+	originTop, originLeft float64 // N, E
+	edgeBottom, edgeRight float64 // N, E
+}
+
+// anyFormat is the maximal format version of grids without upper bound.
+const anyFormat = math.MaxInt32
+
+// builtinGrids holds the national, extended or middle-european grids. The
+// values are described in [1], [4] and [6]. The sphere described in [1] is
+// used up to format version 4. For format version 5, which uses the WGS84
+// ellipsoid, only the parameters of the DE1200 grid are described in [6], so
+// that composites of version 5 on the other grids have no projection unless
+// their parameters are added by RegisterGrid.
+var builtinGrids = []gridDefinition{
+	{nationalGrid, 900, 900, 0, 4, nil, 54.5877, 02.0715, 47.0705, 14.6209},
+	{nationalPictureGrid, 920, 920, 0, 4, nil, 54.66218275, 1.900684377, 46.98044293, 14.73300934},
+	{extendedNationalGrid, 900, 1100, 0, 4, nil, 55.5482, 03.0889, 46.1827, 15.4801},
+	{DE1200Grid, 1100, 1200, 5, anyFormat, proj_DE1200_WGS84, 55.86208711, 1.463301510, 45.68460578, 16.58086935},
+	{DE1200Grid, 1100, 1200, 0, 4, nil, 55.86584289, 1.435612143, 45.68358331, 16.60186543},
+	{middleEuropeanGrid, 1400, 1500, 0, 4, nil, 56.5423, -0.8654, 43.8736, 18.2536},
+}
+
+// registeredGrids holds the grids added by RegisterGrid.
+var registeredGrids []gridDefinition
+
+// GridDefinition describes a grid which is not known to this package, e.g. a
+// grid introduced with a new product. The projection is given as proj4 string
+// and must be a north polar stereographic projection, for example:
+//
+//	+proj=stere +lat_0=90 +lat_ts=60 +lon_0=10 +a=6378137 +b=6356752.3142451802 +x_0=0 +y_0=0
+//
+// If Proj4 is empty, the sphere described in [1] is used.
+type GridDefinition struct {
+	Dx, Dy    int    // dimensions in pixels, multiples with the same aspect ratio are matched as well
+	MinFormat int    // minimal format version of matching composites
+	Proj4     string // projection parameters
+
+	// geographical coordinates of the outer upper left (North, West) and lower
+	// right (South, East) corners of the grid
+	North, West float64
+	South, East float64
+}
+
+// RegisterGrid adds the grid definition, so that composites matching its
+// dimensions and format version can be projected. Registered grids take
+// precedence over the built-in ones and over previously registered ones, so
+// that the parameters of known grids can be replaced as well. RegisterGrid
+// affects composites parsed afterwards and should be called during
+// initialization, as it is not safe for concurrent use.
+func RegisterGrid(def GridDefinition) error {
+	if def.Dx <= 0 || def.Dy <= 0 {
+		return newError("RegisterGrid", "invalid dimensions")
 	}
-	if mx, my := minRes(1100, 1200); dx == mx && dy == my {
-		return DE1200Grid
+	if !(def.North > def.South) || !(def.East > def.West) {
+		return newError("RegisterGrid", "invalid corner points")
 	}
-	if mx, my := minRes(1400, 1500); dx == mx && dy == my {
-		return middleEuropeanGrid
+
+	var proj *projection
+	if def.Proj4 != "" {
+		var err error
+		if proj, err = parseProj4(def.Proj4); err != nil {
+			return err
+		}
 	}
-	return unknownGrid
+
+	registeredGrids = append(registeredGrids, gridDefinition{
+		grid:      firstRegisteredGrid + grid(len(registeredGrids)),
+		dx:        def.Dx,
+		dy:        def.Dy,
+		minFormat: def.MinFormat,
+		maxFormat: anyFormat,
+		proj:      proj,
+
+		originTop: def.North, originLeft: def.West,
+		edgeBottom: def.South, edgeRight: def.East,
+	})
+	return nil
 }
 
-// cornerPoints returns corner coordinates of the national, extended or
-// middle-european grid based on the dimensions of the composite. The used
-// values are described in [1], [4].  If an error is returned,
-// projection methods will not work.
-func (c *Composite) cornerPoints(detectedGrid grid) (originTop, originLeft, edgeBottom, edgeRight float64, err error) {
-	switch detectedGrid {
-	case nationalGrid: // described in [1]
-		originTop, originLeft = 54.5877, 02.0715 // N, E
-		edgeBottom, edgeRight = 47.0705, 14.6209 // N, E
-	case nationalPictureGrid: // (pg) described in [4]
-		originTop, originLeft = 54.66218275, 1.900684377 // N, E
-		edgeBottom, edgeRight = 46.98044293, 14.73300934 // N, E
-	case extendedNationalGrid: // described in [1]
-		originTop, originLeft = 55.5482, 03.0889 // N, E
-		edgeBottom, edgeRight = 46.1827, 15.4801 // N, E
-	case DE1200Grid: // described in [6]
-		if c.Format >= 5 {
-			originTop, originLeft = 55.86208711, 1.463301510 // N, E
-			edgeBottom, edgeRight = 45.68460578, 16.58086935 // N, E
-		} else {
-			originTop, originLeft = 55.86584289, 1.435612143 // N, E
-			edgeBottom, edgeRight = 45.68358331, 16.60186543 // N, E
+// gridDefinition returns the definition matching the dimensions and format
+// version of the composite. If an error is returned, projection methods will
+// not work.
+func (c *Composite) gridDefinition() (gridDefinition, error) {
+	dx, dy := minRes(c.Dx, c.Dy)
+	matches := func(def gridDefinition) bool {
+		mx, my := minRes(def.dx, def.dy)
+		return dx == mx && dy == my && c.Format >= def.minFormat && c.Format <= def.maxFormat
+	}
+
+	for i := len(registeredGrids) - 1; i >= 0; i-- {
+		if matches(registeredGrids[i]) {
+			return registeredGrids[i], nil
 		}
-	case middleEuropeanGrid: // described in [1]
-		originTop, originLeft = 56.5423, -0.8654 // N, E
-		edgeBottom, edgeRight = 43.8736, 18.2536 // N, E
-	default:
-		err = errNoProjection
 	}
-	return
+	for _, def := range builtinGrids {
+		if matches(def) {
+			return def, nil
+		}
+	}
+	return gridDefinition{}, errNoProjection
+}
+
+// detectGrid identifies the used projection grid based on the composite
+// dimensions and format version
+func (c *Composite) detectGrid() grid {
+	def, _ := c.gridDefinition()
+	return def.grid
 }
 
 // calibrateProjection initializes fields that are necessary for coordinate transformation
@@ -96,23 +161,14 @@ func (c *Composite) calibrateProjection() {
 	c.offy = math.NaN()
 
 	// get corner points
-	detectedGrid := c.detectGrid()
-
-	originTop, originLeft, edgeBottom, edgeRight, err := c.cornerPoints(detectedGrid)
+	def, err := c.gridDefinition()
 	if err != nil {
 		return
 	}
 
-	if c.Format >= 5 {
-		if detectedGrid == DE1200Grid {
-			c.proj_wgs84 = proj_DE1200_WGS84
-		} else {
-			return
-		}
-	}
-
 	// found matching projection rule
 	c.HasProjection = true
+	c.proj_wgs84 = def.proj
 
 	// set resolution to 1 km for calibration
 	c.Rx = 1.0
@@ -121,10 +177,10 @@ func (c *Composite) calibrateProjection() {
 	c.offy = 0.0
 
 	// calibrate offset correction
-	c.offx, c.offy = c.Project(originTop, originLeft)
+	c.offx, c.offy = c.Project(def.originTop, def.originLeft)
 
 	// calibrate scaling
-	resx, resy := c.Project(edgeBottom, edgeRight)
+	resx, resy := c.Project(def.edgeBottom, def.edgeRight)
 	c.Rx = (resx) / float64(c.Dx)
 	c.Ry = (resy) / float64(c.Dy)
 }
@@ -143,3 +199,75 @@ func (c *Composite) Project(north, east float64) (x, y float64) {
 	}
 	return c.projectSphere(north, east)
 }
+
+// Unproject transforms data indices in the coordinate system of the composite to the
+// according geographical coordinates (latitude north, longitude east). It is the
+// inverse of Project. The upper left corner of the pixel at (x, y) is located at the
+// data indices (x, y) and its center at (x+0.5, y+0.5).
+// NaN is returned when no projection is available.
+func (c *Composite) Unproject(x, y float64) (north, east float64) {
+	if !c.HasProjection {
+		north, east = math.NaN(), math.NaN()
+		return
+	}
+
+	if c.proj_wgs84 != nil {
+		return c.unprojectWGS84(x, y)
+	}
+	return c.unprojectSphere(x, y)
+}
+
+// PixelCenter returns the geographical coordinates (latitude north, longitude east) of
+// the center of the pixel at (x, y).
+func (c *Composite) PixelCenter(x, y int) (north, east float64) {
+	return c.Unproject(float64(x)+0.5, float64(y)+0.5)
+}
+
+// PixelCorner returns the geographical coordinates (latitude north, longitude east) of
+// the upper left corner of the pixel at (x, y). The lower left corner of this pixel is
+// obtained by PixelCorner(x, y+1).
+func (c *Composite) PixelCorner(x, y int) (north, east float64) {
+	return c.Unproject(float64(x), float64(y))
+}
+
+// planeCoordinates returns the coordinates (km) in the plane of the polar
+// stereographic projection for the given data indices. The easting increases
+// to the east and the northing increases to the north.
+func (c *Composite) planeCoordinates(x, y float64) (easting, northing float64) {
+	return c.offx + x*c.Rx, -(c.offy + y*c.Ry)
+}
+
+// BoundingBox is a geographical area limited by latitudes (north, south) and
+// longitudes (west, east).
+type BoundingBox struct {
+	North, West, South, East float64
+}
+
+// Rectangle returns the smallest rectangle of data indices covering the given
+// bounding box. The rectangle is clipped to the dimensions of the composite and
+// is empty when no projection is available or the bounding box lies outside the
+// composite.
+func (c *Composite) Rectangle(box BoundingBox) image.Rectangle {
+	if !c.HasProjection {
+		return image.Rectangle{}
+	}
+
+	// parallels and meridians are curved, so the edges are sampled
+	const steps = 100
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= steps; i++ {
+		f := float64(i) / steps
+		north := box.South + f*(box.North-box.South)
+		east := box.West + f*(box.East-box.West)
+
+		for _, p := range [][2]float64{{box.North, east}, {box.South, east}, {north, box.West}, {north, box.East}} {
+			x, y := c.Project(p[0], p[1])
+			minx, maxx = math.Min(minx, x), math.Max(maxx, x)
+			miny, maxy = math.Min(miny, y), math.Max(maxy, y)
+		}
+	}
+
+	rect := image.Rect(int(math.Floor(minx)), int(math.Floor(miny)), int(math.Ceil(maxx)), int(math.Ceil(maxy)))
+	return rect.Intersect(image.Rect(0, 0, c.Dx, c.Dy))
+}
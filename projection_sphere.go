@@ -34,3 +34,25 @@ func (c *Composite) projectSphere(north, east float64) (x, y float64) {
 
 	return
 }
+
+func (c *Composite) unprojectSphere(x, y float64) (north, east float64) {
+	deg := func(rad float64) float64 {
+		return rad * (180.0 / math.Pi)
+	}
+
+	lamda0, phi0 := junctionEast*(math.Pi/180.0), junctionNorth*(math.Pi/180.0)
+
+	// scaling
+	x *= c.Rx
+	y *= c.Ry
+
+	// offset correction
+	x += c.offx
+	y += c.offy
+
+	r := math.Sqrt(x*x + y*y) // distance to north pole
+	lamda := lamda0 + math.Atan2(x, y)
+	phi := math.Pi/2.0 - 2.0*math.Atan(r/(earthRadius*(1.0+math.Sin(phi0))))
+
+	return deg(phi), deg(lamda)
+}
@@ -136,6 +136,13 @@ func TestProject(t *testing.T) {
 				t.Errorf("dummy%s.Project(%#v, %#v) = (%#v, %#v); expected: (%#v, %#v)",
 					test.comp.Product, edge[0], edge[1], rx, ry, ex, ey)
 			}
+
+			// inverse projection, allowed inaccuracy by about 100 meters
+			rn, re := test.comp.Unproject(ex, ey)
+			if !absequal(rn, edge[0], 0.001) || !absequal(re, edge[1], 0.002) {
+				t.Errorf("dummy%s.Unproject(%#v, %#v) = (%#v, %#v); expected: (%#v, %#v)",
+					test.comp.Product, ex, ey, rn, re, edge[0], edge[1])
+			}
 		}
 	}
 }
@@ -242,3 +249,68 @@ func testGrid(t *testing.T, mode gridMode, dummys ...*Composite) {
 		}
 	}
 }
+
+func TestUnprojectRoundtrip(t *testing.T) {
+	dummys := []*Composite{
+		NewDummy("PG", 0, 460, 460),
+		NewDummy("SF", 3, 900, 900),
+		NewDummy("WX", 3, 900, 1100),
+		NewDummy("WN", 3, 1100, 1200),
+		NewDummy("WN", 5, 1100, 1200),
+		NewDummy("EX", 3, 1400, 1500),
+	}
+
+	for _, comp := range dummys {
+		for y := 0; y <= comp.Dy; y += comp.Dy / 10 {
+			for x := 0; x <= comp.Dx; x += comp.Dx / 10 {
+				north, east := comp.Unproject(float64(x), float64(y))
+				rx, ry := comp.Project(north, east)
+
+				if dist(rx, ry, float64(x), float64(y)) > 0.000001 {
+					t.Errorf("dummy%s.Project(dummy%s.Unproject(%d, %d)) = (%#v, %#v)",
+						comp.Product, comp.Product, x, y, rx, ry)
+				}
+			}
+		}
+	}
+
+	// geographical coordinates covering the grids, about 10m inaccuracy allowed
+	for _, comp := range dummys {
+		for north := 44.0; north <= 56.0; north += 0.5 {
+			for east := 2.0; east <= 18.0; east += 0.5 {
+				x, y := comp.Project(north, east)
+				rn, re := comp.Unproject(x, y)
+
+				if !absequal(rn, north, 0.0001) || !absequal(re, east, 0.0002) {
+					t.Errorf("dummy%s.Unproject(dummy%s.Project(%#v, %#v)) = (%#v, %#v)",
+						comp.Product, comp.Product, north, east, rn, re)
+				}
+			}
+		}
+	}
+
+	dummy := NewDummy("XX", 3, 10, 10) // no projection available
+	if n, e := dummy.Unproject(1, 1); !math.IsNaN(n) || !math.IsNaN(e) {
+		t.Errorf("dummyXX.Unproject(1, 1) = (%#v, %#v); expected: (NaN, NaN)", n, e)
+	}
+}
+
+func TestPixelCenter(t *testing.T) {
+	comp := NewDummy("RX", 3, 900, 900)
+
+	north, east := comp.PixelCorner(0, 0)
+	if !absequal(north, 54.5877, 0.0001) || !absequal(east, 2.0715, 0.0001) {
+		t.Errorf("dummyRX.PixelCorner(0, 0) = (%#v, %#v); expected: (54.5877, 2.0715)", north, east)
+	}
+
+	north, east = comp.PixelCorner(450, 450)
+	if !absequal(north, 51.0, 0.001) || !absequal(east, 9.0, 0.001) {
+		t.Errorf("dummyRX.PixelCorner(450, 450) = (%#v, %#v); expected: (51.0, 9.0)", north, east)
+	}
+
+	north, east = comp.PixelCenter(450, 450)
+	cn, ce := comp.Unproject(450.5, 450.5)
+	if north != cn || east != ce {
+		t.Errorf("dummyRX.PixelCenter(450, 450) = (%#v, %#v); expected: (%#v, %#v)", north, east, cn, ce)
+	}
+}
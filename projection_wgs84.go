@@ -2,32 +2,158 @@ package radolan
 
 import (
 	"math"
+	"strconv"
+	"strings"
 )
 
+// projection holds the parameters of a north polar stereographic projection of
+// an ellipsoid.
 type projection struct {
-	lon_0 float64
-	ecc   float64
-	k_0   float64
-	x_0   float64
-	y_0   float64
+	lon_0  float64 // central meridian (rad)
+	lat_ts float64 // latitude of true scale (degrees)
+	a      float64 // semi-major axis
+	b      float64 // semi-minor axis
+	ecc    float64 // eccentricity
+	k      float64 // scale factor at the pole (+k_0), 1 unless lat_ts is 90
+	k_0    float64 // scaling derived from lat_ts, the scale factor and the ellipsoid
+	x_0    float64 // false easting
+	y_0    float64 // false northing
 
-	scale float64
+	scale float64 // unit (m) per km
 }
 
 const (
 	degToRad = 2 * math.Pi / 360
 )
 
+// semi-axes of the WGS84 ellipsoid in m
+const (
+	wgs84SemiMajor = 6378137.0
+	wgs84SemiMinor = 6356752.3142451802
+)
+
 // DE1200 WGS84
 // +proj=stere +lat_0=90 +lat_ts=60 +lon_0=10 +a=6378137 +b=6356752.3142451802 +no_defs +x_0=543196.83521776402 +y_0=3622588.861931001
-var proj_DE1200_WGS84 = &projection{
-	lon_0: 10 * degToRad,
-	ecc:   0.08181919084262032,
-	k_0:   11862667.042661695,
-	x_0:   543196.83521776402,
-	y_0:   3622588.861931001,
-
-	scale: 1000, // unit per km
+var proj_DE1200_WGS84 = newProjection(60, 10, wgs84SemiMajor, wgs84SemiMinor, 543196.83521776402, 3622588.861931001)
+
+// newProjection returns the projection with the given latitude of true scale
+// and central meridian (degrees), semi-axes and false easting and northing
+// (m).
+func newProjection(latTS, lon0, a, b, x0, y0 float64) *projection {
+	p := &projection{
+		lon_0:  lon0 * degToRad,
+		lat_ts: latTS,
+		a:      a,
+		b:      b,
+		ecc:    math.Sqrt(1 - (b*b)/(a*a)),
+		x_0:    x0,
+		y_0:    y0,
+		k:      1,
+		scale:  1000,
+	}
+
+	e := p.ecc
+	if math.Abs(latTS-90) < 1e-10 { // true scale at the pole
+		p.k_0 = 2 * a / math.Sqrt(math.Pow(1+e, 1+e)*math.Pow(1-e, 1-e))
+		return p
+	}
+
+	ts := latTS * degToRad
+	sinTS := math.Sin(ts)
+	m := math.Cos(ts) / math.Sqrt(1-e*e*sinTS*sinTS)
+	t := math.Tan(0.5*(math.Pi/2-ts)) / math.Pow((1-e*sinTS)/(1+e*sinTS), 0.5*e)
+	p.k_0 = a * m / t
+	return p
+}
+
+// parseProj4 returns the projection described by the proj4 string. Only the
+// north polar stereographic projection (+proj=stere +lat_0=90) is supported.
+func parseProj4(def string) (*projection, error) {
+	params := make(map[string]string)
+	for _, field := range strings.Fields(def) {
+		if !strings.HasPrefix(field, "+") {
+			return nil, newError("parseProj4", "invalid parameter: "+field)
+		}
+		kv := strings.SplitN(field[1:], "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "")
+		}
+		params[kv[0]] = kv[1]
+	}
+
+	if params["proj"] != "stere" {
+		return nil, newError("parseProj4", "unsupported projection: "+params["proj"])
+	}
+
+	var err error
+	number := func(key string, def float64) float64 {
+		s, ok := params[key]
+		if !ok || err != nil {
+			return def
+		}
+		v, perr := strconv.ParseFloat(s, 64)
+		if perr != nil {
+			err = newError("parseProj4", "invalid value of "+key+": "+s)
+		}
+		return v
+	}
+
+	// ellipsoid
+	a, b := wgs84SemiMajor, wgs84SemiMinor
+	switch params["ellps"] {
+	case "", "WGS84":
+	case "GRS80":
+		b = 6356752.314140347
+	case "sphere":
+		a, b = 6370997, 6370997
+	default:
+		return nil, newError("parseProj4", "unsupported ellipsoid: "+params["ellps"])
+	}
+	if datum, ok := params["datum"]; ok && datum != "WGS84" {
+		return nil, newError("parseProj4", "unsupported datum: "+datum)
+	}
+
+	if _, ok := params["R"]; ok {
+		a = number("R", a)
+		b = a
+	} else if _, ok := params["a"]; ok {
+		a = number("a", a)
+		b = number("b", a) // sphere unless the semi-minor axis is given
+		if rf := number("rf", 0); rf != 0 {
+			b = a * (1 - 1/rf)
+		}
+	}
+
+	lat0 := number("lat_0", 90)
+	latTS := number("lat_ts", 90)
+	lon0 := number("lon_0", 0)
+	x0, y0 := number("x_0", 0), number("y_0", 0)
+	k0 := number("k_0", number("k", 1))
+	if err != nil {
+		return nil, err
+	}
+
+	if lat0 != 90 {
+		return nil, newError("parseProj4", "only north polar stereographic projections are supported")
+	}
+	if a <= 0 || b <= 0 || b > a {
+		return nil, newError("parseProj4", "invalid ellipsoid")
+	}
+	if latTS <= 0 || latTS > 90 {
+		return nil, newError("parseProj4", "invalid latitude of true scale")
+	}
+	if k0 != 1 && latTS != 90 {
+		return nil, newError("parseProj4", "scale factor requires true scale at the pole")
+	}
+
+	if units, ok := params["units"]; ok && units != "m" {
+		return nil, newError("parseProj4", "unsupported unit: "+units)
+	}
+
+	p := newProjection(latTS, lon0, a, b, x0, y0)
+	p.k = k0
+	p.k_0 *= k0
+	return p, nil
 }
 
 func (c *Composite) projectWGS84(north, east float64) (x, y float64) {
@@ -58,3 +184,41 @@ func (c *Composite) projectWGS84(north, east float64) (x, y float64) {
 
 	return
 }
+
+func (c *Composite) unprojectWGS84(x, y float64) (north, east float64) {
+	p := c.proj_wgs84
+
+	// scaling to image
+	x *= c.Rx
+	y *= c.Ry
+
+	// offset correction
+	x += c.offx
+	y += c.offy
+
+	x = (x * p.scale)
+	y = (y * -p.scale)
+
+	dx := x - p.x_0
+	dy := p.y_0 - y
+
+	s := math.Sqrt(dx*dx + dy*dy)
+	t := s / p.k_0
+
+	// latitude is obtained by fixed-point iteration of the conformal latitude
+	lat := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 15; i++ {
+		sinLat := math.Sin(lat)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-p.ecc*sinLat)/(1+p.ecc*sinLat), 0.5*p.ecc))
+		if math.Abs(next-lat) < 1e-12 {
+			lat = next
+			break
+		}
+		lat = next
+	}
+	lon := p.lon_0 + math.Atan2(dx, dy)
+
+	north = lat / degToRad
+	east = lon / degToRad
+	return
+}
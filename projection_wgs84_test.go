@@ -1,6 +1,7 @@
 package radolan
 
 import (
+	"math"
 	"testing"
 )
 
@@ -21,5 +22,127 @@ func Test_DE1200_WGS84(t *testing.T) {
 		if dist(rx, ry, ex, ey) > 0.000001 {
 			t.Errorf("comp.Project(%#v, %#v) = (%#v, %#v); expected: (%#v, %#v)", v[0], v[1], rx, ry, ex, ey)
 		}
+
+		rn, re := comp.Unproject(ex, ey)
+		if dist(rn, re, v[0], v[1]) > 0.000001 {
+			t.Errorf("comp.Unproject(%#v, %#v) = (%#v, %#v); expected: (%#v, %#v)", ex, ey, rn, re, v[0], v[1])
+		}
+	}
+}
+
+func TestFormat5Grids(t *testing.T) {
+	// only the DE1200 grid is described for format version 5 in [6]
+	for _, test := range []struct {
+		dx, dy     int
+		projection bool
+	}{
+		{900, 900, false},
+		{920, 920, false},
+		{900, 1100, false},
+		{1100, 1200, true},
+		{1400, 1500, false},
+	} {
+		sphere := NewDummy("WN", 4, test.dx, test.dy)
+		if !sphere.HasProjection || sphere.proj_wgs84 != nil {
+			t.Errorf("NewDummy(4, %d, %d): HasProjection = %v, ellipsoid = %v; expected: sphere",
+				test.dx, test.dy, sphere.HasProjection, sphere.proj_wgs84 != nil)
+		}
+
+		comp := NewDummy("WN", 5, test.dx, test.dy)
+		if comp.HasProjection != test.projection || (comp.proj_wgs84 != nil) != test.projection {
+			t.Errorf("NewDummy(5, %d, %d): HasProjection = %v, ellipsoid = %v; expected: %v",
+				test.dx, test.dy, comp.HasProjection, comp.proj_wgs84 != nil, test.projection)
+		}
+	}
+}
+
+func TestParseProj4(t *testing.T) {
+	p, err := parseProj4("+proj=stere +lat_0=90 +lat_ts=60 +lon_0=10 +a=6378137 +b=6356752.3142451802 +no_defs +x_0=543196.83521776402 +y_0=3622588.861931001")
+	if err != nil {
+		t.Fatalf("parseProj4(DE1200) returned error: %#v", err)
+	}
+	if math.Abs(p.k_0-11862667.042661695) > 1e-6 || math.Abs(p.ecc-0.08181919084262032) > 1e-12 ||
+		p.lon_0 != 10*degToRad || p.x_0 != 543196.83521776402 || p.y_0 != 3622588.861931001 {
+		t.Errorf("parseProj4(DE1200) = %#v; expected: %#v", p, proj_DE1200_WGS84)
+	}
+
+	if p, err = parseProj4("+proj=stere +lat_0=90 +lat_ts=60 +lon_0=10 +ellps=WGS84 +units=m"); err != nil {
+		t.Errorf("parseProj4(ellps=WGS84) returned error: %#v", err)
+	} else if p.a != wgs84SemiMajor || p.b != wgs84SemiMinor {
+		t.Errorf("parseProj4(ellps=WGS84) = %#v; expected WGS84 ellipsoid", p)
+	}
+
+	if p, err = parseProj4("+proj=stere +lat_0=90 +lat_ts=60 +lon_0=10 +R=6370040"); err != nil {
+		t.Errorf("parseProj4(R=6370040) returned error: %#v", err)
+	} else if p.ecc != 0 || p.a != 6370040 {
+		t.Errorf("parseProj4(R=6370040) = %#v; expected sphere", p)
+	}
+
+	for _, def := range []string{
+		"",
+		"+proj=merc",
+		"+proj=stere +lat_0=-90",
+		"+proj=stere +lat_0=90 +lat_ts=abc",
+		"+proj=stere +lat_0=90 +ellps=bessel",
+		"+proj=stere +lat_0=90 +units=km",
+		"proj=stere",
+	} {
+		if _, err := parseProj4(def); err == nil {
+			t.Errorf("parseProj4(%#v) returned no error", def)
+		}
+	}
+}
+
+func TestRegisterGrid(t *testing.T) {
+	defer func(grids []gridDefinition) { registeredGrids = grids }(registeredGrids)
+
+	// the sphere projection of RADOLAN described as proj4 string
+	err := RegisterGrid(GridDefinition{
+		Dx: 500, Dy: 700, MinFormat: 5,
+		Proj4: "+proj=stere +lat_0=90 +lat_ts=60 +lon_0=10 +R=6370040",
+		North: 54.5877, West: 02.0715,
+		South: 47.0705, East: 14.6209,
+	})
+	if err != nil {
+		t.Fatalf("RegisterGrid() returned error: %#v", err)
+	}
+
+	if NewDummy("WN", 4, 500, 700).HasProjection {
+		t.Errorf("NewDummy(4, 500, 700).HasProjection = true; expected: false")
+	}
+
+	custom := NewDummy("WN", 5, 1000, 1400)
+	sphere := NewDummy("RX", 4, 900, 900)
+	if !custom.HasProjection {
+		t.Fatalf("NewDummy(5, 1000, 1400).HasProjection = false; expected: true")
+	}
+	if custom.detectGrid() < firstRegisteredGrid {
+		t.Errorf("NewDummy(5, 1000, 1400).detectGrid() = %d; expected registered grid", custom.detectGrid())
+	}
+
+	for _, v := range [][2]float64{{54.5877, 02.0715}, {51, 9}, {47.0705, 14.6209}} {
+		sx, sy := sphere.Project(v[0], v[1])
+		ex, ey := sx*1000/900, sy*1400/900 // scaled to custom dimensions
+		if rx, ry := custom.Project(v[0], v[1]); dist(rx, ry, ex, ey) > 0.0001 {
+			t.Errorf("custom.Project(%#v, %#v) = (%#v, %#v); expected: (%#v, %#v)", v[0], v[1], rx, ry, ex, ey)
+		}
+	}
+
+	// registered grids take precedence over built-in ones
+	if err := RegisterGrid(GridDefinition{Dx: 900, Dy: 900, North: 55, West: 2, South: 47, East: 15}); err != nil {
+		t.Fatalf("RegisterGrid() returned error: %#v", err)
+	}
+	if rx, ry := NewDummy("RX", 4, 900, 900).Project(55, 2); dist(rx, ry, 0, 0) > 0.000001 {
+		t.Errorf("overridden.Project(55, 2) = (%#v, %#v); expected: (0, 0)", rx, ry)
+	}
+
+	for _, def := range []GridDefinition{
+		{Dx: 0, Dy: 900, North: 55, West: 2, South: 47, East: 15},
+		{Dx: 900, Dy: 900, North: 47, West: 2, South: 55, East: 15},
+		{Dx: 900, Dy: 900, Proj4: "+proj=merc", North: 55, West: 2, South: 47, East: 15},
+	} {
+		if err := RegisterGrid(def); err == nil {
+			t.Errorf("RegisterGrid(%#v) returned no error", def)
+		}
 	}
 }
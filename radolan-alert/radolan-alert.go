@@ -0,0 +1,112 @@
+// radolan-alert is an example program for the radolan package, that evaluates
+// alert rules against the composites of a local directory in chronological
+// order. The rules are read from a JSON file as described for alert.Rule. Each
+// start and end of an alert is printed as a line of JSON. Rules which cannot be
+// evaluated are logged once and skipped, as are composites which cannot be
+// opened.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"gitlab.cs.fau.de/since/radolan/alert"
+	"log"
+	"os"
+	"time"
+)
+
+func main() {
+	from := flag.String("from", "", "evaluate composites from this forecast time on (RFC 3339)")
+	to := flag.String("to", "", "evaluate composites up to this forecast time (RFC 3339)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "radolan-alert evaluates alert rules against radolan composite files."+
+			"\n\n\tUsage: %s [flags] <rules.json> <directory>\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// display help message
+	if flag.NArg() != 2 {
+		flag.Usage()
+		return
+	}
+
+	evaluate(flag.Arg(0), flag.Arg(1), parseTime(*from), parseTime(*to))
+}
+
+// evaluate evaluates the rules against the composites between begin and end,
+// which are ignored if zero.
+func evaluate(rulesPath, dir string, begin, end time.Time) {
+	f, err := os.Open(rulesPath)
+	care(err)
+	rules, err := alert.ReadRules(f)
+	f.Close()
+	care(err)
+
+	engine, err := alert.NewEngine(rules)
+	care(err)
+
+	products := make(map[string]bool)
+	for _, r := range rules {
+		products[r.Product] = true
+	}
+
+	idx, err := radolan.ScanIndex(dir)
+	if err != nil {
+		if _, ok := err.(radolan.MemberErrors); !ok {
+			log.Fatal(err)
+		}
+		log.Print(err) // evaluate the remaining composites
+	}
+
+	// entries are sorted by forecast time
+	out := json.NewEncoder(os.Stdout)
+	reported := make(map[string]bool) // rules whose error was logged
+	for _, e := range idx.Entries {
+		if !products[e.Product] || e.ForecastTime.Before(begin) || !end.IsZero() && e.ForecastTime.After(end) {
+			continue
+		}
+
+		// composites of units missing from the catalog are evaluated as well
+		c, err := e.Open()
+		if err != nil && err != radolan.ErrUnknownUnit {
+			log.Print(err) // evaluate the remaining composites
+			continue
+		}
+		events, err := engine.Evaluate(c)
+		if errs, ok := err.(alert.RuleErrors); ok {
+			// the remaining rules are evaluated
+			for _, rerr := range errs {
+				if !reported[rerr.Rule] {
+					log.Print(rerr)
+					reported[rerr.Rule] = true
+				}
+			}
+		} else {
+			care(err)
+		}
+		for _, ev := range events {
+			care(out.Encode(ev))
+		}
+	}
+}
+
+// parseTime parses the RFC 3339 time or returns the zero time if empty.
+func parseTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	care(err)
+	return t
+}
+
+// care exits the program if an error occured
+func care(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
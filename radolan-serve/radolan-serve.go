@@ -0,0 +1,62 @@
+// radolan-serve is an example program for the radolan package, that serves the
+// composites of a local directory as web map tiles at
+// http://<addr>/{product}/{time}/{z}/{x}/{y}.png for use with web map
+// libraries. New files appearing in the directory are served as well.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"gitlab.cs.fau.de/since/radolan/tile"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	interval := flag.Duration("interval", time.Minute, "interval for scanning the directory for new files")
+	tiles := flag.Int("cache", tile.DefaultOptions.Tiles, "number of cached tiles")
+	maxZoom := flag.Int("maxzoom", tile.DefaultOptions.MaxZoom, "maximum zoom level")
+	bilinear := flag.Bool("bilinear", false, "interpolate bilinearly instead of nearest neighbour")
+	min := flag.Float64("min", math.NaN(), "values below are drawn transparent")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "radolan-serve serves radolan composite files as web map tiles."+
+			"\n\n\tUsage: %s [flags] <directory>\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// display help message
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return
+	}
+
+	opts := tile.DefaultOptions
+	opts.Tiles = *tiles
+	opts.MaxZoom = *maxZoom
+	if *bilinear {
+		opts.Method = radolan.ResampleBilinear
+	}
+	if !math.IsNaN(*min) {
+		opts.Transparent = func(val float64) bool { return val < *min }
+	}
+
+	server, err := tile.NewServer(flag.Arg(0), &opts)
+	if err != nil {
+		if _, ok := err.(radolan.MemberErrors); !ok {
+			log.Fatal(err)
+		}
+		log.Print(err) // serve the remaining composites
+	}
+
+	go server.Watch(*interval, nil)
+
+	log.Printf("serving %s at %s", flag.Arg(0), *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -37,7 +37,7 @@ package radolan
 
 import (
 	"archive/tar"
-	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"fmt"
 	"io"
@@ -55,6 +55,8 @@ import (
 // c.Data[ y ][ x ] and is stored as raw float value (NaN if the no-data flag
 // is set). Some 3D radar products feature multiple layers in which the voxel
 // at position (x, y, z) is accessible by c.DataZ[ z ][ y ][ x ].
+// All values are stored in the contiguous field c.Raw, for which c.Data and
+// c.DataZ are views. The voxel (x, y, z) is located at c.Raw[ c.Offset(x, y, z) ].
 //
 // The data value is used differently depending on the product type:
 // (also consult the DataUnit field of the Composite)
@@ -72,11 +74,12 @@ import (
 // The cloud reflectivity factor Z is stored in its logarithmic representation dBZ:
 //	dBZ = 10 * log(Z)
 // Real world geographical coordinates (latitude, longitude) can be projected into the
-// coordinate system of the composite by using the projection method:
+// coordinate system of the composite by using the projection method. The value at
+// these coordinates is obtained by sampling the surrounding pixels:
 //	// if c.HasProjection
-//	x, y := c.Project(52.51861, 13.40833)	// Berlin (lat, lon)
+//	x, y := c.Project(52.51861, 13.40833)	// Berlin (lat, lon), pixel (int(x), int(y))
 //
-//	dbz := c.At(int(x), int(y))					// Raw value is Cloud reflectivity (dBZ)
+//	dbz := c.Sample(52.51861, 13.40833, radolan.Bilinear)		// Raw value is Cloud reflectivity (dBZ)
 //	rat := radolan.PrecipitationRate(radolan.Doelling98, dbz)	// Rainfall rate (mm/h) using Doelling98 as Z-R relationship
 //
 //	fmt.Println("Rainfall in Berlin [mm/h]:", rat)
@@ -90,9 +93,11 @@ type Composite struct {
 
 	DataUnit Unit
 
-	PlainData [][]float32 // data for parsed plain data element [y][x]
-	Px        int         // plain data width
-	Py        int         // plain data height
+	Raw       []float32     // contiguous plain data storage, row by row (PlainData, DataZ and Data are views into Raw)
+	PlainData [][]float32   // data for parsed plain data element [y][x]
+	Flags     [][]PixelFlag // flags for each plain data element [y][x] (little endian encoded products only, nil otherwise)
+	Px        int           // plain data width
+	Py        int           // plain data height
 
 	DataZ [][][]float32 // data for each voxel [z][y][x] (composites use only one z-layer)
 	Data  [][]float32   // data for each pixel [y][x] at layer 0 (alias for DataZ[0][x][y])
@@ -108,6 +113,8 @@ type Composite struct {
 
 	Format int // Version Format
 
+	Header Header // parsed header fields
+
 	dataLength int // length of binary section in bytes
 
 	precision int       // multiplicator 10^precision for each raw value
@@ -130,31 +137,28 @@ var ErrUnknownUnit = newError("NewComposite", "data unit not defined in catalog.
 // be incorrect due to unit dependent conversions during parsing. In this case
 // be careful when further processing the composite.
 func NewComposite(rd io.Reader) (comp *Composite, err error) {
-	reader := bufio.NewReader(rd)
-	comp = &Composite{}
-
-	err = comp.parseHeader(reader)
-	if err != nil {
+	dec, err := NewDecoder(rd)
+	if err != nil && err != ErrUnknownUnit {
 		return
 	}
+	comp = dec.Composite()
 
-	err = comp.parseData(reader)
-	if err != nil {
-		return
+	if err := comp.parseData(dec); err != nil {
+		return comp, err
 	}
 	comp.arrangeData()
 
-	comp.calibrateProjection()
-
-	if comp.DataUnit == Unit_unknown {
-		err = ErrUnknownUnit
-	}
-
 	return
 }
 
 // NewComposites reads .tar.bz2 data from rd and returns the parsed composites sorted by
-// ForecastTime in ascending order.
+// ForecastTime in ascending order. NewCompositesFrom supports further container formats.
+//
+// NewComposites decodes the members sequentially and fails if a single member
+// cannot be decoded. It takes no ArchiveOptions to keep its signature and
+// behaviour unchanged for existing callers. Use NewCompositesFrom, which also
+// reads .tar.bz2 archives, to decode members in parallel, collect the errors
+// of single members or filter them by name or ForecastTime.
 func NewComposites(rd io.Reader) ([]*Composite, error) {
 	bzipReader := bzip2.NewReader(rd)
 
@@ -182,6 +186,60 @@ func NewComposites(rd io.Reader) ([]*Composite, error) {
 	return cs, nil
 }
 
+// Encode writes the composite in binary RADOLAN format to w. The encoding of
+// the parsed source (runlength, little endian or single byte) is retained.
+// Composites that do not originate from a parsed file are encoded in little
+// endian format. Values that cannot be represented with the precision of the
+// composite are rounded.
+func (c *Composite) Encode(w io.Writer) error {
+	var data bytes.Buffer
+	if err := c.encodeData(&data); err != nil {
+		return err
+	}
+
+	header, err := c.encodeHeader(data.Len())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	_, err = data.WriteTo(w)
+	return err
+}
+
+// Copy returns a deep copy of the composite. The data of the copy is
+// stored in a new contiguous Raw field.
+func (c *Composite) Copy() *Composite {
+	cp := *c
+	cp.Header.Radars = append([]string(nil), c.Header.Radars...)
+	cp.Header.Stations = append([]Station(nil), c.Header.Stations...)
+	cp.Header.Level = append([]float32(nil), c.Header.Level...)
+	cp.Header.Unknown = make(map[string]string, len(c.Header.Unknown))
+	for k, v := range c.Header.Unknown {
+		cp.Header.Unknown[k] = v
+	}
+	if c.level != nil {
+		cp.level = append([]float32(nil), c.level...)
+	}
+
+	if c.PlainData == nil {
+		return &cp
+	}
+
+	cp.allocData(c.Flags != nil)
+	for y := range c.PlainData {
+		copy(cp.PlainData[y], c.PlainData[y])
+	}
+	for y := range c.Flags {
+		copy(cp.Flags[y], c.Flags[y])
+	}
+	cp.arrangeData()
+
+	return &cp
+}
+
 // NewDummy creates a blank dummy composite with the given product label, format version, and dimensions. It can
 // be used for generic coordinate projection.
 func NewDummy(product string, format, dx, dy int) (comp *Composite) {
@@ -190,6 +248,31 @@ func NewDummy(product string, format, dx, dy int) (comp *Composite) {
 	return
 }
 
+// Stride returns the distance between two vertically adjacent values in the Raw
+// field.
+func (c *Composite) Stride() int {
+	return c.Px
+}
+
+// Offset returns the index of the voxel at (x, y, z) in the Raw field, so that
+// c.Raw[c.Offset(x, y, z)] is equal to c.DataZ[z][y][x]. The given point is not
+// validated.
+func (c *Composite) Offset(x, y, z int) int {
+	return (c.layerRow(z)+y)*c.Px + x
+}
+
+// Layer returns the contiguous part of the Raw field holding the z-layer. The
+// voxel at (x, y) is located at index y*c.Stride() + x. nil is returned if
+// the layer does not exist.
+func (c *Composite) Layer(z int) []float32 {
+	if z < 0 || z >= c.Dz || c.Raw == nil {
+		return nil
+	}
+
+	begin := c.Offset(0, 0, z)
+	return c.Raw[begin : begin+c.Dy*c.Px]
+}
+
 // At is shorthand for c.Data[y][x] and returns the radar video processor value
 // at the given point. NaN is returned, if no data is available or the
 // requested point is located outside the scanned area.
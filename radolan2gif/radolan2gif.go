@@ -0,0 +1,91 @@
+// radolan2gif is an example program for the radolan package, that converts
+// a sequence of radolan composites to an animated .gif or .png image. The
+// composites are read from files or archives as supported by radolan.Open and
+// shown in chronological order. The frames contain the german borders and a
+// caption showing the forecast time.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"gitlab.cs.fau.de/since/radolan/radolan2png/vis"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	opts := vis.DefaultAnimateOptions
+	flag.IntVar(&opts.Layer, "layer", 0, "z-layer to visualize")
+	flag.DurationVar(&opts.Delay, "delay", 0, "display time of each frame (derived from the interval if zero)")
+	flag.Float64Var(&opts.Speedup, "speedup", opts.Speedup, "ratio of composite interval to display time")
+	flag.DurationVar(&opts.Pause, "pause", opts.Pause, "additional display time of the last frame")
+	noBorder := flag.Bool("noborder", false, "do not draw the german borders")
+	noCaption := flag.Bool("nocaption", false, "do not draw the forecast time")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "radolan2gif converts radolan composite files to animated gif or png images."+
+			"\n\n\tUsage: %s [flags] <input>... <output.gif|output.png>\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// display help message
+	if flag.NArg() < 2 {
+		flag.Usage()
+		return
+	}
+
+	if *noBorder {
+		opts.Border = nil
+	}
+	if *noCaption {
+		opts.Caption = nil
+	}
+
+	in, out := flag.Args()[:flag.NArg()-1], flag.Arg(flag.NArg()-1)
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".gif":
+		opts.Format = vis.GIF
+	case ".png", ".apng":
+		opts.Format = vis.APNG
+	default:
+		log.Fatal("unknown output format: ", out)
+	}
+
+	animate(in, out, &opts)
+}
+
+func animate(in []string, out string, opts *vis.AnimateOptions) {
+	// read composites of all input files
+	var cs []*radolan.Composite
+	for _, path := range in {
+		c, err := radolan.Open(path)
+		care(err)
+		cs = append(cs, c...)
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].ForecastTime.Before(cs[j].ForecastTime) })
+
+	if len(cs) > 0 {
+		fmt.Printf("%d %s-Images (%s) showing %s - %s\n", len(cs), cs[0].Product, cs[0].DataUnit,
+			cs[0].ForecastTime, cs[len(cs)-1].ForecastTime)
+	}
+
+	// create output file
+	outfile, err := os.Create(out)
+	care(err)
+	defer outfile.Close()
+
+	// write animation to output file
+	care(vis.Animate(outfile, cs, opts))
+}
+
+// care exits the program if an error occured
+func care(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
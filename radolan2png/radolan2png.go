@@ -1,36 +1,107 @@
 // radolan2png is an example program for the radolan package, that converts
 // radolan composite files to .png images. The created images also contain
-// an overlay showing the german borders and a latitude longitude mesh.
+// an overlay showing the german borders and a latitude longitude mesh. The
+// color gradient, overlays, image section and size can be configured by flags.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"gitlab.cs.fau.de/since/radolan"
 	"gitlab.cs.fau.de/since/radolan/radolan2png/vis"
+	"image"
 	"image/color"
 	"image/png"
 	"log"
+	"math"
 	"os"
-	"time"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
+// options holds the rendering options given by flags.
+type options struct {
+	layer       int
+	gradient    string
+	min, max    float64
+	compression string
+
+	border, mesh color.Color // nil disables the overlay
+	box          *radolan.BoundingBox
+	scale        float64
+	transparent  bool
+}
+
+// color gradients and compressions selectable by flags
 var (
-	borderColor = color.RGBA{0xFF, 0xFF, 0x00, 0xFF}
-	meshColor   = color.RGBA{0x33, 0xFF, 0x22, 0xFF}
+	gradients = map[string]func(min, max float64, compression func(float64) float64) vis.ColorFunc{
+		"heatmap":   vis.Heatmap,
+		"graymap":   vis.Graymap,
+		"radialmap": vis.Radialmap,
+	}
+	compressions = map[string]func(float64) float64{
+		"id":  vis.Id,
+		"log": vis.Log,
+	}
 )
 
 func main() {
+	var opts options
+	flag.IntVar(&opts.layer, "layer", 0, "z-layer to visualize")
+	flag.StringVar(&opts.gradient, "colors", "", "color gradient: heatmap, graymap or radialmap (derived from the unit if empty)")
+	flag.Float64Var(&opts.min, "min", math.NaN(), "lower limit of the color gradient (derived from the unit if not set)")
+	flag.Float64Var(&opts.max, "max", math.NaN(), "upper limit of the color gradient (derived from the unit if not set)")
+	flag.StringVar(&opts.compression, "compression", "", "compression of the color gradient: id or log (derived from the unit if empty)")
+	noBorder := flag.Bool("noborder", false, "do not draw the german borders")
+	noMesh := flag.Bool("nomesh", false, "do not draw the latitude longitude mesh")
+	borderColor := flag.String("bordercolor", "#FFFF00", "color of the german borders (#RRGGBB or #RRGGBBAA)")
+	meshColor := flag.String("meshcolor", "#33FF22", "color of the latitude longitude mesh (#RRGGBB or #RRGGBBAA)")
+	bbox := flag.String("bbox", "", "crop to the bounding box north,west,south,east in degrees")
+	flag.Float64Var(&opts.scale, "scale", 1, "scale factor of the image")
+	flag.BoolVar(&opts.transparent, "transparent", false, "draw missing values transparent")
+	batch := flag.Bool("batch", false, "convert all composites of a .tar.bz2 archive to numbered png images")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "radolan2png converts radolan composite files to png images."+
+			"\n\n\tUsage: %s [flags] <input> <output.png>\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	// display help message
-	if len(os.Args) < 3 {
-		fmt.Printf("radolan2png converts radolan composite files to png images."+
-			"\n\n\tUsage: %s <input> <output.png>\n\n", os.Args[0])
+	if flag.NArg() != 2 {
+		flag.Usage()
 		return
 	}
 
-	convert(os.Args[1], os.Args[2])
+	if _, ok := gradients[opts.gradient]; opts.gradient != "" && !ok {
+		log.Fatal("unknown color gradient: ", opts.gradient)
+	}
+	if _, ok := compressions[opts.compression]; opts.compression != "" && !ok {
+		log.Fatal("unknown compression: ", opts.compression)
+	}
+	if opts.scale <= 0 {
+		log.Fatal("invalid scale factor: ", opts.scale)
+	}
+	if !*noBorder {
+		opts.border = parseColor(*borderColor)
+	}
+	if !*noMesh {
+		opts.mesh = parseColor(*meshColor)
+	}
+	if *bbox != "" {
+		opts.box = parseBoundingBox(*bbox)
+	}
+
+	if *batch {
+		convertArchive(flag.Arg(0), flag.Arg(1), &opts)
+		return
+	}
+	convert(flag.Arg(0), flag.Arg(1), &opts)
 }
 
-func convert(in, out string) {
+func convert(in, out string, opts *options) {
 	// open input file
 	infile, err := os.Open(in)
 	care(err)
@@ -40,52 +111,63 @@ func convert(in, out string) {
 	comp, err := radolan.NewComposite(infile)
 	care(err)
 
-	fmt.Printf("%s-Image (%s) showing %s\n", comp.Product, comp.DataUnit, comp.ForecastTime)
+	write(comp, out, opts)
+}
 
-	var heatmap vis.ColorFunc
-	switch comp.DataUnit {
-	case radolan.Unit_mm:
-		max := 200.0
-		if comp.Interval <= time.Hour {
-			max = 100.0
-		}
-		if comp.Interval >= time.Hour*24*7 {
-			max = 400.0
-		}
-		heatmap = vis.Heatmap(0.1, max, vis.Log)
-	case radolan.Unit_dBZ:
-		heatmap = vis.HeatmapReflectivity
-	case radolan.Unit_km:
-		heatmap = vis.Graymap(0, 15, vis.Id)
-	case radolan.Unit_mps:
-		heatmap = vis.HeatmapRadialVelocity
+// convertArchive converts each composite of the .tar.bz2 archive to a png
+// image whose name is the output name numbered in chronological order, e.g.
+// out-00.png, out-01.png, ...
+func convertArchive(in, out string, opts *options) {
+	infile, err := os.Open(in)
+	care(err)
+	defer infile.Close()
+
+	cs, err := radolan.NewComposites(infile)
+	care(err)
+
+	ext := filepath.Ext(out)
+	base := strings.TrimSuffix(out, ext)
+	digits := len(strconv.Itoa(len(cs) - 1))
+	for i, c := range cs {
+		write(c, fmt.Sprintf("%s-%0*d%s", base, digits, i, ext), opts)
+	}
+}
+
+// write renders the composite and writes the png image to out.
+func write(comp *radolan.Composite, out string, opts *options) {
+	fmt.Printf("%s-Image (%s) showing %s\n", comp.Product, comp.DataUnit, comp.ForecastTime)
+	if opts.layer < 0 || opts.layer >= comp.Dz {
+		log.Fatalf("layer %d not available (%d layers)", opts.layer, comp.Dz)
 	}
 
 	// convert composite to image using the color function
-	img := vis.Image(heatmap, comp, 0) // TODO: select layer
+	img := vis.Image(colorFunc(comp, opts), comp, opts.layer)
 
 	// draw borders
 	if comp.HasProjection {
 		// print grid dimensions
 		fmt.Printf("detected grid: %.1f km * %.1f km\n", float64(comp.Dx)*comp.Rx, float64(comp.Dy)*comp.Ry)
 
-		for _, b := range border {
-			// convert border points to data indices
-			x, y := comp.Project(b[0], b[1])
-
-			// draw point
-			img.Set(int(x), int(y), borderColor)
+		if opts.border != nil {
+			vis.DrawBorder(img, comp, opts.border)
 		}
+		if opts.mesh != nil {
+			vis.DrawMesh(img, comp, opts.mesh)
+		}
+	}
 
-		// draw mesh
-		for e := 1.0; e < 16.0; e += 0.1 {
-			for n := 46.0; n < 55.0; n += 0.1 {
-				if e-float64(int(e)) < 0.1 || n-float64(int(n)) < 0.1 {
-					x, y := comp.Project(n, e)
-					img.Set(int(x), int(y), meshColor)
-				}
-			}
+	// crop to bounding box
+	var result image.Image = img
+	if opts.box != nil {
+		rect := comp.Rectangle(*opts.box)
+		if rect.Empty() {
+			log.Fatal("bounding box does not cover the composite")
 		}
+		result = img.SubImage(rect)
+	}
+
+	if opts.scale != 1 {
+		result = scale(result, opts.scale)
 	}
 
 	// create output file
@@ -94,7 +176,89 @@ func convert(in, out string) {
 	defer outfile.Close()
 
 	// write image to output file
-	care(png.Encode(outfile, img))
+	care(png.Encode(outfile, result))
+}
+
+// colorFunc returns the color function of the default gradient of the
+// composite, modified by the options.
+func colorFunc(comp *radolan.Composite, opts *options) vis.ColorFunc {
+	g := vis.DefaultGradient(comp)
+	if opts.gradient != "" {
+		g.Map = gradients[opts.gradient]
+	}
+	if !math.IsNaN(opts.min) {
+		g.Min = opts.min
+	}
+	if !math.IsNaN(opts.max) {
+		g.Max = opts.max
+	}
+	if opts.compression != "" {
+		g.Compression = compressions[opts.compression]
+	}
+
+	colors := g.ColorFunc()
+	if !opts.transparent {
+		return colors
+	}
+	return func(val float64) color.RGBA {
+		if math.IsNaN(val) {
+			return color.RGBA{}
+		}
+		return colors(val)
+	}
+}
+
+// scale resizes the image by the factor using nearest neighbour sampling.
+func scale(img image.Image, factor float64) *image.RGBA {
+	b := img.Bounds()
+	w := int(math.Max(1, math.Round(float64(b.Dx())*factor)))
+	h := int(math.Max(1, math.Round(float64(b.Dy())*factor)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + int(float64(y)/factor)
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + int(float64(x)/factor)
+			dst.Set(x, y, img.At(min(sx, b.Max.X-1), min(sy, b.Max.Y-1)))
+		}
+	}
+	return dst
+}
+
+// parseColor parses a color given as #RRGGBB or #RRGGBBAA.
+func parseColor(s string) color.Color {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "FF"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || err != nil {
+		log.Fatal("invalid color: ", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+}
+
+// parseBoundingBox parses a bounding box given as north,west,south,east.
+func parseBoundingBox(s string) *radolan.BoundingBox {
+	var v [4]float64
+	fields := strings.Split(s, ",")
+	if len(fields) != len(v) {
+		log.Fatal("invalid bounding box: ", s)
+	}
+	for i, f := range fields {
+		var err error
+		v[i], err = strconv.ParseFloat(strings.TrimSpace(f), 64)
+		care(err)
+	}
+	return &radolan.BoundingBox{North: v[0], West: v[1], South: v[2], East: v[3]}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
 
 // care exits the program if an error occured
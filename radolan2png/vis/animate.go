@@ -0,0 +1,309 @@
+package vis
+
+import (
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"sort"
+	"time"
+)
+
+// AnimationFormat is the file format of an animation.
+type AnimationFormat int
+
+const (
+	GIF  AnimationFormat = iota // animated gif
+	APNG                        // animated png
+)
+
+// AnimateOptions configures the creation of animations.
+type AnimateOptions struct {
+	Format AnimationFormat
+	Layer  int       // z-layer to visualize
+	Colors ColorFunc // color function (DefaultColorFunc of the first composite if nil)
+
+	// Delay is the display time of each frame. If zero, it is derived from
+	// the interval of each composite divided by Speedup.
+	Delay   time.Duration
+	Speedup float64
+	Pause   time.Duration // additional display time of the last frame
+
+	// Caption returns the text drawn in the upper left corner of each frame
+	// (optional).
+	Caption      func(c *radolan.Composite) string
+	CaptionScale int // size of a font pixel in image pixels
+
+	Border, Mesh color.Color // colors of the overlays (not drawn if nil)
+}
+
+// DefaultAnimateOptions are used when no options are given. With the default
+// speedup, frames of a composite with an interval of five minutes are shown for
+// 200 milliseconds.
+var DefaultAnimateOptions = AnimateOptions{
+	Format:       GIF,
+	Speedup:      1500,
+	Pause:        time.Second,
+	Caption:      Caption,
+	CaptionScale: 2,
+	Border:       BorderColor,
+}
+
+// display time of frames whose composites have no interval
+const defaultFrameDelay = 200 * time.Millisecond
+
+// maximum number of colors in the palette of an animation
+const paletteSize = 256
+
+// Caption returns the product and the forecast time of the composite in UTC.
+// For forecasts, the lead time is appended.
+func Caption(c *radolan.Composite) string {
+	caption := c.Product + " " + c.ForecastTime.UTC().Format("2006-01-02 15:04") + " UTC"
+	if lead := c.ForecastTime.Sub(c.CaptureTime); lead > 0 {
+		caption += fmt.Sprintf(" +%d min", int(lead/time.Minute))
+	}
+	return caption
+}
+
+// Animate writes an animation of the given layer of the composites to w. The
+// composites are shown in the given order and must share the same
+// dimensions. All frames are encoded with a common palette of at most 256
+// colors, so that equal values are drawn in equal colors across the
+// animation. The animation is repeated infinitely. nil options are replaced
+// by DefaultAnimateOptions.
+func Animate(w io.Writer, cs []*radolan.Composite, opts *AnimateOptions) error {
+	o := DefaultAnimateOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if len(cs) == 0 {
+		return newError("Animate", "no composites")
+	}
+	for _, c := range cs {
+		if c.Dx != cs[0].Dx || c.Dy != cs[0].Dy {
+			return newError("Animate", "composites differ in dimensions")
+		}
+		if o.Layer < 0 || o.Layer >= c.Dz {
+			return newError("Animate", "invalid layer")
+		}
+	}
+
+	colors := o.Colors
+	if colors == nil {
+		colors = DefaultColorFunc(cs[0])
+	}
+
+	// render frames
+	frames := make([]*image.RGBA, len(cs))
+	delays := make([]time.Duration, len(cs))
+	for i, c := range cs {
+		img := Image(colors, c, o.Layer)
+		if o.Mesh != nil {
+			DrawMesh(img, c, o.Mesh)
+		}
+		if o.Border != nil {
+			DrawBorder(img, c, o.Border)
+		}
+		if o.Caption != nil {
+			DrawText(img, o.Caption(c), o.CaptionScale, color.White, color.Black)
+		}
+		frames[i] = img
+
+		delays[i] = o.Delay
+		if delays[i] <= 0 {
+			delays[i] = defaultFrameDelay
+			if c.Interval > 0 && o.Speedup > 0 {
+				delays[i] = time.Duration(float64(c.Interval) / o.Speedup)
+			}
+		}
+	}
+	delays[len(delays)-1] += o.Pause
+
+	palette := quantize(frames, paletteSize)
+	paletted := make([]*image.Paletted, len(frames))
+	for i, img := range frames {
+		paletted[i] = applyPalette(img, palette)
+	}
+
+	switch o.Format {
+	case GIF:
+		g := &gif.GIF{
+			Image: paletted,
+			Delay: make([]int, len(paletted)),
+			Config: image.Config{
+				ColorModel: palette, // global color table
+				Width:      cs[0].Dx,
+				Height:     cs[0].Dy,
+			},
+		}
+		for i, d := range delays {
+			g.Delay[i] = int((d + 5*time.Millisecond) / (10 * time.Millisecond)) // 1/100 s
+		}
+		return gif.EncodeAll(w, g)
+
+	case APNG:
+		images := make([]image.Image, len(paletted))
+		for i, p := range paletted {
+			images[i] = p
+		}
+		return encodeAPNG(w, images, delays)
+	}
+
+	return newError("Animate", "unknown format")
+}
+
+// colorCount is a color and the number of pixels using it.
+type colorCount struct {
+	color color.RGBA
+	count int
+}
+
+// quantize returns a palette of at most n colors representing the colors of
+// all images. If the images use more colors, they are reduced by the median
+// cut algorithm weighted by the number of pixels.
+func quantize(images []*image.RGBA, n int) color.Palette {
+	counts := make(map[color.RGBA]int)
+	for _, img := range images {
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			counts[color.RGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]}]++
+		}
+	}
+
+	colors := make([]colorCount, 0, len(counts))
+	for c, count := range counts {
+		colors = append(colors, colorCount{c, count})
+	}
+	sort.Slice(colors, func(i, j int) bool { // deterministic order
+		a, b := colors[i].color, colors[j].color
+		if a.R != b.R {
+			return a.R < b.R
+		}
+		if a.G != b.G {
+			return a.G < b.G
+		}
+		if a.B != b.B {
+			return a.B < b.B
+		}
+		return a.A < b.A
+	})
+
+	if len(colors) <= n {
+		palette := make(color.Palette, len(colors))
+		for i, c := range colors {
+			palette[i] = c.color
+		}
+		return palette
+	}
+
+	// median cut: split the box with the widest channel range until n boxes exist
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, r := widestChannel(box); r > bestRange {
+				best, bestChannel, bestRange = i, ch, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool {
+			return channel(box[i].color, bestChannel) < channel(box[j].color, bestChannel)
+		})
+
+		// split at the weighted median
+		var total, sum int
+		for _, c := range box {
+			total += c.count
+		}
+		split := 1
+		for i, c := range box[:len(box)-1] {
+			sum += c.count
+			split = i + 1
+			if 2*sum >= total {
+				break
+			}
+		}
+
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	// average color of each box
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		var r, g, b, a, total int
+		for _, c := range box {
+			r += int(c.color.R) * c.count
+			g += int(c.color.G) * c.count
+			b += int(c.color.B) * c.count
+			a += int(c.color.A) * c.count
+			total += c.count
+		}
+		palette[i] = color.RGBA{uint8(r / total), uint8(g / total), uint8(b / total), uint8(a / total)}
+	}
+	return palette
+}
+
+// channel returns the red, green, blue or alpha channel (0 - 3) of the color.
+func channel(c color.RGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	}
+	return c.A
+}
+
+// widestChannel returns the channel with the widest range of values in the box
+// and its range.
+func widestChannel(box []colorCount) (ch int, width int) {
+	for i := 0; i < 4; i++ {
+		min, max := 255, 0
+		for _, c := range box {
+			v := int(channel(c.color, i))
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max-min > width {
+			ch, width = i, max-min
+		}
+	}
+	return
+}
+
+// applyPalette converts the image to a paletted image using the closest
+// palette color of each pixel.
+func applyPalette(img *image.RGBA, palette color.Palette) *image.Paletted {
+	p := image.NewPaletted(img.Bounds(), palette)
+	index := make(map[color.RGBA]uint8)
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			i, ok := index[c]
+			if !ok {
+				i = uint8(palette.Index(c))
+				index[c] = i
+			}
+			p.SetColorIndex(x, y, i)
+		}
+	}
+	return p
+}
@@ -0,0 +1,197 @@
+package vis
+
+import (
+	"bytes"
+	"gitlab.cs.fau.de/since/radolan"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// testComposites returns a forecast sequence of composites holding a gradient
+// which moves by one pixel each frame.
+func testComposites(n int) []*radolan.Composite {
+	capture := time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC)
+
+	cs := make([]*radolan.Composite, n)
+	for i := range cs {
+		c := radolan.NewDummy("WN", 5, 200, 100)
+		c.CaptureTime = capture
+		c.ForecastTime = capture.Add(time.Duration(i) * 5 * time.Minute)
+		c.Interval = 5 * time.Minute
+		c.DataUnit = radolan.Unit_dBZ
+		c.Dz = 1
+
+		data := make([][]float32, c.Dy)
+		for y := range data {
+			data[y] = make([]float32, c.Dx)
+			for x := range data[y] {
+				data[y][x] = float32((x+i)%c.Dx) * 75 / float32(c.Dx)
+			}
+		}
+		c.DataZ = [][][]float32{data}
+		c.Data = data
+		cs[i] = c
+	}
+	return cs
+}
+
+func TestAnimateGIF(t *testing.T) {
+	cs := testComposites(4)
+
+	var buf bytes.Buffer
+	if err := Animate(&buf, cs, nil); err != nil {
+		t.Fatalf("Animate(): returned error: %#v", err.Error())
+	}
+
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll(): returned error: %#v", err.Error())
+	}
+	if len(g.Image) != 4 {
+		t.Fatalf("Animate(): %d frames; expected: 4", len(g.Image))
+	}
+
+	// 5 minutes with speedup 1500: 200 ms; last frame pauses for another second
+	for i, expected := range []int{20, 20, 20, 120} {
+		if g.Delay[i] != expected {
+			t.Errorf("Animate(): delay of frame %d: %d; expected: %d", i, g.Delay[i], expected)
+		}
+	}
+
+	// common palette
+	palette, ok := g.Config.ColorModel.(color.Palette)
+	if !ok || len(palette) > 256 {
+		t.Fatalf("Animate(): no global palette")
+	}
+	for i, img := range g.Image {
+		if len(img.Palette) != len(palette) {
+			t.Errorf("Animate(): frame %d uses local palette", i)
+		}
+	}
+
+	// caption is drawn in white on black, data below
+	if c := g.Image[0].At(0, 0); !sameColor(c, color.Black) {
+		t.Errorf("Animate(): caption background %v; expected: black", c)
+	}
+	expected := HeatmapReflectivity(float64(cs[1].Data[50][100]))
+	if c := g.Image[1].At(100, 50); !sameColor(c, expected) {
+		t.Errorf("Animate(): frame 1 at (100, 50): %v; expected: %v", c, expected)
+	}
+}
+
+func TestAnimateAPNG(t *testing.T) {
+	cs := testComposites(3)
+
+	opts := DefaultAnimateOptions
+	opts.Format = APNG
+	opts.Delay = 500 * time.Millisecond
+	opts.Pause = 0
+	opts.Caption = nil
+
+	var buf bytes.Buffer
+	if err := Animate(&buf, cs, &opts); err != nil {
+		t.Fatalf("Animate(): returned error: %#v", err.Error())
+	}
+	data := buf.Bytes()
+
+	// the first frame is a regular png image
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode(): returned error: %#v", err.Error())
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Errorf("png.Decode(): size %v; expected: 200x100", b)
+	}
+
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		t.Fatalf("readPNGChunks(): returned error: %#v", err.Error())
+	}
+	count := make(map[string]int)
+	var seq []uint32
+	for _, c := range chunks {
+		count[c.typ]++
+		switch c.typ {
+		case "acTL":
+			if frames := int(c.data[3]); frames != 3 {
+				t.Errorf("acTL: %d frames; expected: 3", frames)
+			}
+		case "fcTL":
+			if delay := int(c.data[20])<<8 | int(c.data[21]); delay != 500 {
+				t.Errorf("fcTL: delay %d ms; expected: 500 ms", delay)
+			}
+			fallthrough
+		case "fdAT":
+			seq = append(seq, uint32(c.data[0])<<24|uint32(c.data[1])<<16|uint32(c.data[2])<<8|uint32(c.data[3]))
+		}
+	}
+	if count["acTL"] != 1 || count["fcTL"] != 3 || count["fdAT"] < 2 || count["PLTE"] != 1 || chunks[len(chunks)-1].typ != "IEND" {
+		t.Errorf("Animate(): unexpected chunks: %v", count)
+	}
+	for i, s := range seq {
+		if s != uint32(i) {
+			t.Errorf("Animate(): sequence numbers %v; expected ascending from 0", seq)
+			break
+		}
+	}
+}
+
+func TestAnimateErrors(t *testing.T) {
+	cs := testComposites(2)
+	cs = append(cs, radolan.NewDummy("WN", 5, 100, 100))
+
+	var buf bytes.Buffer
+	if err := Animate(&buf, nil, nil); err == nil {
+		t.Errorf("Animate(nil): returned no error")
+	}
+	if err := Animate(&buf, cs, nil); err == nil {
+		t.Errorf("Animate(): composites of different dimensions: returned no error")
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 0x80, 0xFF}) // 4096 colors
+		}
+	}
+
+	palette := quantize([]*image.RGBA{img}, 256)
+	if len(palette) != 256 {
+		t.Fatalf("quantize(): %d colors; expected: 256", len(palette))
+	}
+
+	// each color is approximated closely
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := img.RGBAAt(x, y)
+			p := palette[palette.Index(c)].(color.RGBA)
+			if abs(int(c.R)-int(p.R)) > 16 || abs(int(c.G)-int(p.G)) > 16 || c.B != p.B {
+				t.Fatalf("quantize(): %v is approximated by %v", c, p)
+			}
+		}
+	}
+
+	few := quantize([]*image.RGBA{image.NewRGBA(image.Rect(0, 0, 4, 4))}, 256)
+	if len(few) != 1 {
+		t.Errorf("quantize(): %d colors; expected: 1", len(few))
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,150 @@
+package vis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"time"
+)
+
+// pngSignature is the first eight bytes of a png file.
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngChunk is a chunk of a png file.
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readPNGChunks returns the chunks of the png file.
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, newError("readPNGChunks", "invalid png signature")
+	}
+	data = data[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data))
+		if length > len(data)-12 {
+			return nil, newError("readPNGChunks", "truncated chunk")
+		}
+		chunks = append(chunks, pngChunk{string(data[4:8]), data[8 : 8+length]})
+		data = data[12+length:] // length, type, data, crc
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes the chunk including its length and checksum to w.
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+
+	for _, b := range [][]byte{header[:], data, sum[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeAPNG writes the images as animated png to w. Each image is shown for
+// the given delay. The images must share the same size and color model, e.g.
+// paletted images with a common palette, as the header and palette of the
+// first image are used for all frames.
+func encodeAPNG(w io.Writer, images []image.Image, delays []time.Duration) error {
+	if len(images) == 0 || len(images) != len(delays) {
+		return newError("encodeAPNG", "invalid number of images")
+	}
+
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return err
+	}
+
+	var seq uint32 // sequence number of fcTL and fdAT chunks
+	for i, img := range images {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		chunks, err := readPNGChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		// header, palette and animation control of the first image
+		if i == 0 {
+			for _, c := range chunks {
+				if c.typ == "IDAT" {
+					break
+				}
+				if err := writePNGChunk(w, c.typ, c.data); err != nil {
+					return err
+				}
+				if c.typ == "IHDR" {
+					actl := make([]byte, 8)
+					binary.BigEndian.PutUint32(actl[0:], uint32(len(images))) // number of frames
+					binary.BigEndian.PutUint32(actl[4:], 0)                   // infinite loop
+					if err := writePNGChunk(w, "acTL", actl); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		// frame control
+		b := img.Bounds()
+		ms := delays[i] / time.Millisecond
+		if ms > 0xFFFF {
+			ms = 0xFFFF
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], 0) // x offset
+		binary.BigEndian.PutUint32(fctl[16:], 0) // y offset
+		binary.BigEndian.PutUint16(fctl[20:], uint16(ms))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		fctl[24] = 0 // dispose: none
+		fctl[25] = 0 // blend: source
+		if err := writePNGChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		seq++
+
+		// image data
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				if err := writePNGChunk(w, "IDAT", c.data); err != nil {
+					return err
+				}
+				continue
+			}
+
+			fdat := make([]byte, 4+len(c.data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			copy(fdat[4:], c.data)
+			if err := writePNGChunk(w, "fdAT", fdat); err != nil {
+				return err
+			}
+			seq++
+		}
+	}
+
+	return writePNGChunk(w, "IEND", nil)
+}
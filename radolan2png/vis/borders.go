@@ -1,4 +1,4 @@
-package main
+package vis
 
 type p []float64
 
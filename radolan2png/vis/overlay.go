@@ -0,0 +1,133 @@
+package vis
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// Sample colors for overlays.
+var (
+	BorderColor = color.RGBA{0xFF, 0xFF, 0x00, 0xFF}
+	MeshColor   = color.RGBA{0x33, 0xFF, 0x22, 0xFF}
+)
+
+// DrawBorder draws the german borders onto an image of the composite created
+// by Image. Nothing is drawn if the composite has no projection.
+func DrawBorder(img draw.Image, c *radolan.Composite, col color.Color) {
+	if !c.HasProjection {
+		return
+	}
+
+	for _, b := range border {
+		// convert border points to data indices
+		x, y := c.Project(b[0], b[1])
+
+		// draw point
+		img.Set(int(x), int(y), col)
+	}
+}
+
+// DrawMesh draws a latitude longitude mesh with a spacing of one degree onto an
+// image of the composite created by Image. Nothing is drawn if the composite
+// has no projection.
+func DrawMesh(img draw.Image, c *radolan.Composite, col color.Color) {
+	if !c.HasProjection {
+		return
+	}
+
+	for e := 1.0; e < 16.0; e += 0.1 {
+		for n := 46.0; n < 55.0; n += 0.1 {
+			if e-float64(int(e)) < 0.1 || n-float64(int(n)) < 0.1 {
+				x, y := c.Project(n, e)
+				img.Set(int(x), int(y), col)
+			}
+		}
+	}
+}
+
+// glyphs of a 5x7 pixel font. Each row is stored in the five least
+// significant bits, beginning with the leftmost column at bit 4.
+var glyphs = map[rune][7]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	' ': {},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'+': {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+}
+
+// dimensions of a glyph including spacing in font pixels
+const (
+	glyphWidth  = 6
+	glyphHeight = 8
+)
+
+// DrawText draws the text in the upper left corner of the image on a filled
+// background. Each font pixel is scaled to scale*scale image pixels. Lower case
+// letters are drawn as upper case letters and unsupported characters are
+// drawn as space.
+func DrawText(img draw.Image, text string, scale int, fg, bg color.Color) {
+	if scale < 1 {
+		scale = 1
+	}
+	text = strings.ToUpper(text)
+
+	// background with a margin of one font pixel
+	n := len([]rune(text))
+	rect := image.Rect(0, 0, (n*glyphWidth+1)*scale, (glyphHeight+1)*scale).Add(img.Bounds().Min)
+	draw.Draw(img, rect, image.NewUniform(bg), image.Point{}, draw.Src)
+
+	fill := image.NewUniform(fg)
+	for i, r := range []rune(text) {
+		glyph := glyphs[r]
+		for row, bits := range glyph {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>uint(col)) == 0 {
+					continue
+				}
+				x := rect.Min.X + (1+i*glyphWidth+col)*scale
+				y := rect.Min.Y + (1+row)*scale
+				draw.Draw(img, image.Rect(x, y, x+scale, y+scale), fill, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
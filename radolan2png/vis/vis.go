@@ -1,10 +1,12 @@
 package vis
 
 import (
+	"fmt"
 	"gitlab.cs.fau.de/since/radolan"
 	"image"
 	"image/color"
 	"math"
+	"time"
 )
 
 // A ColorFunc can be used to assign colors to data values for image creation.
@@ -45,6 +47,48 @@ var (
 	GraymapLinearWide = Graymap(0, 4095, Id)
 )
 
+// Gradient describes a color gradient by the function creating it (e.g.
+// Heatmap), its range and compression.
+type Gradient struct {
+	Map         func(min, max float64, compression func(float64) float64) ColorFunc
+	Min, Max    float64
+	Compression func(float64) float64
+}
+
+// ColorFunc returns the color function of the gradient.
+func (g Gradient) ColorFunc() ColorFunc {
+	return g.Map(g.Min, g.Max, g.Compression)
+}
+
+// DefaultGradient returns a gradient suitable for the data unit of the
+// composite. Accumulated rainfall is scaled by the interval of the composite.
+func DefaultGradient(c *radolan.Composite) Gradient {
+	switch c.DataUnit {
+	case radolan.Unit_mm:
+		max := 200.0
+		if c.Interval <= time.Hour {
+			max = 100.0
+		}
+		if c.Interval >= time.Hour*24*7 {
+			max = 400.0
+		}
+		return Gradient{Heatmap, 0.1, max, Log}
+	case radolan.Unit_dBZ:
+		return Gradient{Heatmap, 1.0, 75.0, Id} // HeatmapReflectivity
+	case radolan.Unit_km:
+		return Gradient{Graymap, 0, 15, Id}
+	case radolan.Unit_mps:
+		return Gradient{Radialmap, -31.5, 31.5, Log} // HeatmapRadialVelocity
+	}
+	return Gradient{Graymap, 0, 409.5, Id} // GraymapLinear
+}
+
+// DefaultColorFunc returns the color function of the DefaultGradient of the
+// composite.
+func DefaultColorFunc(c *radolan.Composite) ColorFunc {
+	return DefaultGradient(c).ColorFunc()
+}
+
 // Id is the identity (no compression)
 func Id(x float64) float64 {
 	return x
@@ -180,3 +224,8 @@ func Heatmap(min, max float64, compression func(float64) float64) ColorFunc {
 		return color.RGBA{r, g, b, 0xFF}
 	}
 }
+
+// newError returns an error indicating the failed function and reason
+func newError(function, reason string) error {
+	return fmt.Errorf("vis.%s: %s", function, reason)
+}
@@ -0,0 +1,40 @@
+package vis
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDefaultGradient(t *testing.T) {
+	c := radolan.NewDummy("RX", 4, 900, 900)
+
+	for _, test := range []struct {
+		unit     radolan.Unit
+		interval time.Duration
+		expected ColorFunc
+	}{
+		{radolan.Unit_dBZ, 5 * time.Minute, HeatmapReflectivity},
+		{radolan.Unit_mm, time.Hour, HeatmapAccumulatedHour},
+		{radolan.Unit_mm, 24 * time.Hour, HeatmapAccumulatedDay},
+		{radolan.Unit_mps, 5 * time.Minute, HeatmapRadialVelocity},
+		{radolan.Unit_unknown, 5 * time.Minute, GraymapLinear},
+	} {
+		c.DataUnit, c.Interval = test.unit, test.interval
+		fn := DefaultColorFunc(c)
+		for _, v := range []float64{-40, -10, 0, 0.5, 5, 20, 45, 75, 150, 300, math.NaN()} {
+			if fn(v) != test.expected(v) {
+				t.Errorf("DefaultColorFunc(): %s, %s: color of %v is %v; expected: %v",
+					test.unit, test.interval, v, fn(v), test.expected(v))
+			}
+		}
+	}
+
+	// modified gradient
+	g := DefaultGradient(c)
+	g.Max = 100
+	if fn, expected := g.ColorFunc(), Graymap(0, 100, Id); fn(50) != expected(50) {
+		t.Errorf("Gradient.ColorFunc(): color of 50 is %v; expected: %v", fn(50), expected(50))
+	}
+}
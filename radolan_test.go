@@ -0,0 +1,302 @@
+package radolan
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// newTestComposite creates a composite with the given header values. The data
+// is written by the fill function which receives the plain data indices.
+func newTestComposite(product string, format, px, py, precision int, level []float32,
+	fill func(c *Composite, x, y int) float32) *Composite {
+	c := &Composite{
+		Product:      product,
+		CaptureTime:  time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC),
+		ForecastTime: time.Date(2016, time.July, 31, 17, 05, 0, 0, time.UTC),
+		Interval:     5 * time.Minute,
+		DataUnit:     unitCatalog[product],
+		Px:           px,
+		Py:           py,
+		Dx:           px,
+		Dy:           py,
+		Format:       format,
+		precision:    precision,
+		level:        level,
+		Header: Header{
+			Software:    "2.13.1",
+			ModuleFlags: 2,
+			Radars:      []string{"boo", "ros", "emd"},
+			Stations:    []Station{{"boo", 1}, {"ros", 0}},
+		},
+	}
+
+	if v, ok := dimensionCatalog[product]; ok {
+		c.Dx, c.Dy = v.dx, v.dy
+	}
+
+	c.allocData(false)
+	for y := range c.PlainData {
+		for x := range c.PlainData[y] {
+			c.PlainData[y][x] = fill(c, x, y)
+		}
+	}
+	c.arrangeData()
+	c.calibrateProjection()
+
+	return c
+}
+
+func TestEncode(t *testing.T) {
+	pgLevel := []float32{1.0, 19.0, 28.0, 37.0, 46.0, 55.0}
+
+	testcases := []*Composite{
+		// runlength with long gaps
+		newTestComposite("PG", 0, 460, 460, 0, pgLevel, func(c *Composite, x, y int) float32 {
+			if x < 2*y || (x+y)%7 == 0 {
+				return NaN
+			}
+			return c.level[(x/3+y)%len(c.level)]
+		}),
+		// runlength with multiple layers
+		newTestComposite("PZ", 0, 200, 2400, 0, pgLevel, func(c *Composite, x, y int) float32 {
+			if y%200 > 150 {
+				return NaN
+			}
+			return c.level[(x*y)%len(c.level)]
+		}),
+		// little endian with precision and negative values
+		newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+			if x == y {
+				return NaN
+			}
+			return c.rvp6Raw((x*y)%4096 - 2048)
+		}),
+		// little endian reflectivity
+		newTestComposite("FX", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 {
+			if x > 400 {
+				return NaN
+			}
+			return toDBZ(c.rvp6Raw((x + y) % 4096))
+		}),
+		// single byte reflectivity
+		newTestComposite("RX", 3, 900, 900, 0, nil, func(c *Composite, x, y int) float32 {
+			if y < 10 {
+				return NaN
+			}
+			return toDBZ(c.rvp6Raw((x + 2*y) % 250))
+		}),
+	}
+	testcases[4].dataLength = testcases[4].Px * testcases[4].Py // keep single byte encoding
+
+	// clutter flags are retained in little endian encoding
+	rw := testcases[2]
+	rw.Flags = make([][]PixelFlag, rw.Py)
+	for y := range rw.Flags {
+		rw.Flags[y] = make([]PixelFlag, rw.Px)
+		for x := range rw.Flags[y] {
+			switch v := rw.PlainData[y][x]; {
+			case IsNaN(v):
+				rw.Flags[y][x] = NoData
+			case v < 0:
+				rw.Flags[y][x] = Negative
+			}
+			if (x+y)%5 == 0 {
+				rw.Flags[y][x] |= Clutter
+			}
+		}
+	}
+
+	for _, exp := range testcases {
+		var buf bytes.Buffer
+		if err := exp.Encode(&buf); err != nil {
+			t.Fatalf("%s.Encode(): returned error: %#v", exp.Product, err.Error())
+		}
+		encoded := buf.Bytes()
+
+		comp, err := NewComposite(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("NewComposite(%s.Encode()): returned error: %#v", exp.Product, err.Error())
+		}
+
+		testEqualComposite(t, comp, exp)
+
+		// encoding the parsed composite must yield the same result
+		buf.Reset()
+		if err := comp.Encode(&buf); err != nil {
+			t.Fatalf("%s.Encode(): returned error: %#v", exp.Product, err.Error())
+		}
+		if !bytes.Equal(buf.Bytes(), encoded) {
+			t.Errorf("%s.Encode(): repeated encoding differs", exp.Product)
+		}
+	}
+}
+
+func testEqualComposite(t *testing.T, comp, exp *Composite) {
+	t.Helper()
+
+	if comp.Product != exp.Product {
+		t.Errorf("%s: Product: %#v; expected: %#v", exp.Product, comp.Product, exp.Product)
+	}
+	if !comp.CaptureTime.Equal(exp.CaptureTime) {
+		t.Errorf("%s: CaptureTime: %v; expected: %v", exp.Product, comp.CaptureTime, exp.CaptureTime)
+	}
+	if !comp.ForecastTime.Equal(exp.ForecastTime) {
+		t.Errorf("%s: ForecastTime: %v; expected: %v", exp.Product, comp.ForecastTime, exp.ForecastTime)
+	}
+	if comp.Interval != exp.Interval {
+		t.Errorf("%s: Interval: %v; expected: %v", exp.Product, comp.Interval, exp.Interval)
+	}
+	if comp.DataUnit != exp.DataUnit {
+		t.Errorf("%s: DataUnit: %v; expected: %v", exp.Product, comp.DataUnit, exp.DataUnit)
+	}
+	if comp.Format != exp.Format || comp.precision != exp.precision {
+		t.Errorf("%s: Format: %d precision: %d; expected Format: %d precision: %d", exp.Product,
+			comp.Format, comp.precision, exp.Format, exp.precision)
+	}
+	if comp.Px != exp.Px || comp.Py != exp.Py || comp.Dx != exp.Dx || comp.Dy != exp.Dy || comp.Dz != exp.Dz {
+		t.Fatalf("%s: dimensions: %dx%d (%dx%dx%d); expected: %dx%d (%dx%dx%d)", exp.Product,
+			comp.Px, comp.Py, comp.Dx, comp.Dy, comp.Dz, exp.Px, exp.Py, exp.Dx, exp.Dy, exp.Dz)
+	}
+	if len(comp.level) != len(exp.level) {
+		t.Errorf("%s: level: %#v; expected: %#v", exp.Product, comp.level, exp.level)
+	}
+	for i := range exp.level {
+		if i < len(comp.level) && comp.level[i] != exp.level[i] {
+			t.Errorf("%s: level: %#v; expected: %#v", exp.Product, comp.level, exp.level)
+			break
+		}
+	}
+	if len(comp.Header.Radars) != len(exp.Header.Radars) || len(comp.Header.Stations) != len(exp.Header.Stations) {
+		t.Errorf("%s: Header.Radars: %#v Header.Stations: %#v; expected: %#v %#v", exp.Product,
+			comp.Header.Radars, comp.Header.Stations, exp.Header.Radars, exp.Header.Stations)
+	}
+	if comp.Header.Software != exp.Header.Software || comp.Header.ModuleFlags != exp.Header.ModuleFlags {
+		t.Errorf("%s: Header.Software: %#v Header.ModuleFlags: %d; expected: %#v %d", exp.Product,
+			comp.Header.Software, comp.Header.ModuleFlags, exp.Header.Software, exp.Header.ModuleFlags)
+	}
+
+	if exp.Flags != nil {
+		if len(comp.Flags) != len(exp.Flags) {
+			t.Fatalf("%s: Flags: %d rows; expected: %d rows", exp.Product, len(comp.Flags), len(exp.Flags))
+		}
+		for y := range exp.Flags {
+			for x, e := range exp.Flags[y] {
+				if f := comp.Flags[y][x]; f != e {
+					t.Fatalf("%s: Flags[%d][%d] = %#v; expected: %#v", exp.Product, y, x, f, e)
+				}
+			}
+		}
+	}
+
+	for y := range exp.PlainData {
+		for x, e := range exp.PlainData[y] {
+			v := comp.PlainData[y][x]
+			if v != e && !(IsNaN(v) && IsNaN(e)) {
+				t.Fatalf("%s: PlainData[%d][%d] = %#v; expected: %#v", exp.Product, y, x, v, e)
+			}
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	level := []float32{1.0, 19.0, 28.0, 37.0, 46.0, 55.0}
+	fill := func(c *Composite, x, y int) float32 {
+		return c.level[(x+y)%len(c.level)]
+	}
+
+	testcases := []*Composite{
+		newTestComposite("PZ", 0, 200, 2400, 0, level, fill), // multiple layers
+		newTestComposite("PX", 0, 200, 224, 0, level, fill),  // stripped elevation
+		newTestComposite("PG", 0, 460, 460, 0, level, fill),
+	}
+
+	for _, comp := range testcases {
+		if len(comp.Raw) != comp.Px*comp.Py {
+			t.Fatalf("%s: len(Raw) = %d; expected: %d", comp.Product, len(comp.Raw), comp.Px*comp.Py)
+		}
+
+		for z := 0; z < comp.Dz; z++ {
+			layer := comp.Layer(z)
+			if len(layer) != comp.Dx*comp.Dy {
+				t.Fatalf("%s.Layer(%d): length %d; expected: %d", comp.Product, z, len(layer), comp.Dx*comp.Dy)
+			}
+
+			for y := 0; y < comp.Dy; y++ {
+				for x := 0; x < comp.Dx; x++ {
+					comp.DataZ[z][y][x] = float32(x + y + z)
+					if v := comp.Raw[comp.Offset(x, y, z)]; v != float32(x+y+z) {
+						t.Fatalf("%s.Raw[Offset(%d, %d, %d)] = %#v; expected: %#v", comp.Product, x, y, z, v, x+y+z)
+					}
+					if v := layer[y*comp.Stride()+x]; v != float32(x+y+z) {
+						t.Fatalf("%s.Layer(%d)[%d*Stride()+%d] = %#v; expected: %#v", comp.Product, z, y, x, v, x+y+z)
+					}
+				}
+			}
+		}
+
+		if comp.Layer(comp.Dz) != nil {
+			t.Errorf("%s.Layer(%d): expected nil", comp.Product, comp.Dz)
+		}
+
+		// copies do not share storage
+		cp := comp.Copy()
+		testEqualComposite(t, cp, comp)
+		cp.Data[0][0] = -1
+		if comp.Data[0][0] == -1 || cp.Raw[cp.Offset(0, 0, 0)] != -1 {
+			t.Errorf("%s.Copy(): storage not independent", comp.Product)
+		}
+	}
+}
+
+func benchmarkNewComposite(b *testing.B, comp *Composite) {
+	var buf bytes.Buffer
+	if err := comp.Encode(&buf); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := NewComposite(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewCompositeRunlength(b *testing.B) {
+	level := []float32{1.0, 19.0, 28.0, 37.0, 46.0, 55.0}
+	benchmarkNewComposite(b, newTestComposite("PG", 0, 460, 460, 0, level, func(c *Composite, x, y int) float32 {
+		return c.level[(x/8+y/8)%len(c.level)]
+	}))
+}
+
+func BenchmarkNewCompositeLittleEndian(b *testing.B) {
+	benchmarkNewComposite(b, newTestComposite("EX", 3, 1400, 1500, -1, nil, func(c *Composite, x, y int) float32 {
+		return toDBZ(c.rvp6Raw((x + y) % 4096))
+	}))
+}
+
+func BenchmarkNewCompositeSingleByte(b *testing.B) {
+	comp := newTestComposite("RX", 3, 900, 900, 0, nil, func(c *Composite, x, y int) float32 {
+		return toDBZ(c.rvp6Raw((x + y) % 250))
+	})
+	comp.dataLength = comp.Px * comp.Py // keep single byte encoding
+	benchmarkNewComposite(b, comp)
+}
+
+func BenchmarkCopy(b *testing.B) {
+	comp := newTestComposite("EX", 3, 1400, 1500, -1, nil, func(c *Composite, x, y int) float32 {
+		return float32(x + y)
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		comp.Copy()
+	}
+}
@@ -0,0 +1,373 @@
+package radolan
+
+import (
+	"math"
+)
+
+// TargetGrid is a raster to which composites are reprojected. The pixel (x, y)
+// covers the data indices [x, x+1) and [y, y+1).
+type TargetGrid interface {
+	Size() (width, height int)
+
+	// Unproject transforms data indices of the grid to geographical
+	// coordinates (latitude north, longitude east).
+	Unproject(x, y float64) (north, east float64)
+}
+
+// LatLonGrid is a regular grid of geographical coordinates (EPSG:4326).
+type LatLonGrid struct {
+	North, West    float64 // upper left corner in degrees
+	ResLat, ResLon float64 // resolution in degrees/px
+	Width, Height  int
+}
+
+func (g LatLonGrid) Size() (width, height int) {
+	return g.Width, g.Height
+}
+
+func (g LatLonGrid) Unproject(x, y float64) (north, east float64) {
+	return g.North - y*g.ResLat, g.West + x*g.ResLon
+}
+
+// WebMercatorGrid is a regular grid in the spherical mercator projection used
+// by web maps (EPSG:3857).
+type WebMercatorGrid struct {
+	West, North   float64 // upper left corner in m
+	Resolution    float64 // resolution in m/px
+	Width, Height int
+}
+
+// radius of the sphere used by the web mercator projection in m
+const webMercatorRadius = 6378137.0
+
+func (g WebMercatorGrid) Size() (width, height int) {
+	return g.Width, g.Height
+}
+
+func (g WebMercatorGrid) Unproject(x, y float64) (north, east float64) {
+	mx := g.West + x*g.Resolution
+	my := g.North - y*g.Resolution
+
+	north = (2*math.Atan(math.Exp(my/webMercatorRadius)) - math.Pi/2) / degToRad
+	east = mx / webMercatorRadius / degToRad
+	return
+}
+
+// RotatedGrid is a regular grid of rotated geographical coordinates as used by
+// numerical weather models. The rotated north pole is located at the
+// geographical coordinates PoleNorth, PoleEast.
+type RotatedGrid struct {
+	PoleNorth, PoleEast float64 // geographical coordinates of the rotated north pole
+	North, West         float64 // upper left corner in rotated degrees
+	ResLat, ResLon      float64 // resolution in rotated degrees/px
+	Width, Height       int
+}
+
+func (g RotatedGrid) Size() (width, height int) {
+	return g.Width, g.Height
+}
+
+func (g RotatedGrid) Unproject(x, y float64) (north, east float64) {
+	rlat := (g.North - y*g.ResLat) * degToRad
+	rlon := (g.West + x*g.ResLon) * degToRad
+	plat := g.PoleNorth * degToRad
+
+	north = math.Asin(math.Sin(rlat)*math.Sin(plat)+math.Cos(rlat)*math.Cos(rlon)*math.Cos(plat)) / degToRad
+	east = g.PoleEast - 180 + math.Atan2(math.Cos(rlat)*math.Sin(rlon),
+		math.Sin(plat)*math.Cos(rlat)*math.Cos(rlon)-math.Cos(plat)*math.Sin(rlat))/degToRad
+	east = math.Mod(east+540, 360) - 180 // [-180, 180)
+	return
+}
+
+// CompositeGrid uses the grid of a composite, e.g. a dummy created by
+// NewDummy, as target grid.
+type CompositeGrid struct {
+	*Composite
+}
+
+func (g CompositeGrid) Size() (width, height int) {
+	return g.Dx, g.Dy
+}
+
+// Resampling determines how the values of a target pixel are derived from the
+// source pixels.
+type Resampling int
+
+const (
+	ResampleNearest      Resampling = iota // value of the source pixel containing the target pixel center
+	ResampleBilinear                       // interpolation between the four surrounding source pixel centers
+	ResampleConservative                   // average of all source pixels weighted by their overlap
+)
+
+// number of straight segments approximating each edge of a target pixel in
+// the source grid during conservative resampling
+const conservativeSegments = 8
+
+// Reprojection maps the pixels of a target grid to the pixels of a source
+// composite grid. It only depends on the grids, so that it can be reused for
+// all composites sharing the source grid, e.g. all time steps of a product.
+type Reprojection struct {
+	Target TargetGrid
+	Method Resampling
+
+	width, height int // target dimensions
+
+	// source grid
+	dx, dy int
+	grid   grid
+	proj   *projection
+
+	// source pixels (y*dx + x) and weights of target pixel i are stored at
+	// [start[i], start[i+1])
+	start  []int32
+	index  []int32
+	weight []float32
+}
+
+// Raster is a single layer of data reprojected to a target grid. The value of
+// the pixel (x, y) is stored at Data[y*Width + x] (NaN if not available).
+type Raster struct {
+	Width, Height int
+	Data          []float32
+	Grid          TargetGrid // georeference of the raster
+}
+
+// NewReprojection computes the mapping of the target grid to the grid of the
+// source composite using the given resampling method. The source composite
+// is only used for its grid. Conservative resampling weights each source pixel
+// by the area it shares with the target pixel, whose edges are approximated by
+// straight segments in the source grid. An error is returned if the source has
+// no projection.
+func NewReprojection(src *Composite, target TargetGrid, method Resampling) (*Reprojection, error) {
+	if !src.HasProjection {
+		return nil, newError("NewReprojection", "no projection available")
+	}
+
+	width, height := target.Size()
+	if width <= 0 || height <= 0 {
+		return nil, newError("NewReprojection", "empty target grid")
+	}
+
+	r := &Reprojection{
+		Target: target,
+		Method: method,
+		width:  width,
+		height: height,
+		dx:     src.Dx,
+		dy:     src.Dy,
+		grid:   src.detectGrid(),
+		proj:   src.proj_wgs84,
+		start:  make([]int32, 1, width*height+1),
+	}
+
+	// source data indices of target data indices
+	source := func(x, y float64) (float64, float64) {
+		return src.Project(target.Unproject(x, y))
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			switch method {
+			case ResampleNearest:
+				sx, sy := source(float64(x)+0.5, float64(y)+0.5)
+				r.add(math.Floor(sx), math.Floor(sy), 1)
+
+			case ResampleBilinear:
+				sx, sy := source(float64(x)+0.5, float64(y)+0.5)
+				fx, fy := sx-0.5, sy-0.5 // relative to source pixel centers
+				x0, y0 := math.Floor(fx), math.Floor(fy)
+				tx, ty := fx-x0, fy-y0
+
+				r.add(x0, y0, (1-tx)*(1-ty))
+				r.add(x0+1, y0, tx*(1-ty))
+				r.add(x0, y0+1, (1-tx)*ty)
+				r.add(x0+1, y0+1, tx*ty)
+
+			case ResampleConservative:
+				r.addConservative(source, x, y)
+
+			default:
+				return nil, newError("NewReprojection", "unknown resampling method")
+			}
+
+			r.start = append(r.start, int32(len(r.index)))
+		}
+	}
+
+	return r, nil
+}
+
+// add appends the source pixel to the current target pixel. Pixels outside of
+// the source grid and zero weights are skipped.
+func (r *Reprojection) add(x, y, w float64) {
+	if w <= 0 || math.IsNaN(x) || math.IsNaN(y) || x < 0 || y < 0 || x >= float64(r.dx) || y >= float64(r.dy) {
+		return
+	}
+
+	i := int32(int(y)*r.dx + int(x))
+
+	// merge with previous sample of the same target pixel
+	for j := int(r.start[len(r.start)-1]); j < len(r.index); j++ {
+		if r.index[j] == i {
+			r.weight[j] += float32(w)
+			return
+		}
+	}
+
+	r.index = append(r.index, i)
+	r.weight = append(r.weight, float32(w))
+}
+
+// addConservative appends all source pixels overlapping the target pixel
+// (x, y) weighted by the fraction of the target pixel they cover.
+func (r *Reprojection) addConservative(source func(x, y float64) (float64, float64), x, y int) {
+	// outline of the target pixel in source data indices
+	corners := [5][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	outline := make([][2]float64, 0, 4*conservativeSegments)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for c := 0; c < 4; c++ {
+		for i := 0; i < conservativeSegments; i++ {
+			t := float64(i) / conservativeSegments
+			sx, sy := source(float64(x)+corners[c][0]+t*(corners[c+1][0]-corners[c][0]),
+				float64(y)+corners[c][1]+t*(corners[c+1][1]-corners[c][1]))
+			if math.IsNaN(sx) || math.IsNaN(sy) {
+				return
+			}
+
+			outline = append(outline, [2]float64{sx, sy})
+			minX, maxX = math.Min(minX, sx), math.Max(maxX, sx)
+			minY, maxY = math.Min(minY, sy), math.Max(maxY, sy)
+		}
+	}
+
+	area := polygonArea(outline)
+	if !(area > 0) {
+		return
+	}
+
+	// intersect the outline with each source row and then with each pixel
+	for sy := math.Max(0, math.Floor(minY)); sy < math.Min(float64(r.dy), maxY); sy++ {
+		row := clipPolygon(outline, 1, sy, sy+1)
+		if len(row) < 3 {
+			continue
+		}
+		for sx := math.Max(0, math.Floor(minX)); sx < math.Min(float64(r.dx), maxX); sx++ {
+			if w := polygonArea(clipPolygon(row, 0, sx, sx+1)); w > 0 {
+				// each source pixel is visited once, no merging required
+				r.index = append(r.index, int32(int(sy)*r.dx+int(sx)))
+				r.weight = append(r.weight, float32(w/area))
+			}
+		}
+	}
+}
+
+// clipPolygon returns the part of the polygon whose coordinate axis (0: x,
+// 1: y) lies within [min, max].
+func clipPolygon(polygon [][2]float64, axis int, min, max float64) [][2]float64 {
+	clip := func(in [][2]float64, inside func(p [2]float64) bool, bound float64) [][2]float64 {
+		var out [][2]float64
+		for i, p := range in {
+			prev := in[(i+len(in)-1)%len(in)]
+			if inside(p) != inside(prev) {
+				// intersection with the boundary
+				t := (bound - prev[axis]) / (p[axis] - prev[axis])
+				out = append(out, [2]float64{prev[0] + t*(p[0]-prev[0]), prev[1] + t*(p[1]-prev[1])})
+			}
+			if inside(p) {
+				out = append(out, p)
+			}
+		}
+		return out
+	}
+
+	polygon = clip(polygon, func(p [2]float64) bool { return p[axis] >= min }, min)
+	return clip(polygon, func(p [2]float64) bool { return p[axis] <= max }, max)
+}
+
+// polygonArea returns the area of the polygon by the shoelace formula.
+func polygonArea(polygon [][2]float64) float64 {
+	var a float64
+	for i, p := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		a += p[0]*next[1] - next[0]*p[1]
+	}
+	return math.Abs(a) / 2
+}
+
+// check returns an error if the composite does not use the source grid of the
+// reprojection.
+func (r *Reprojection) check(function string, c *Composite, layer int) error {
+	if c.Dx != r.dx || c.Dy != r.dy || c.detectGrid() != r.grid || c.proj_wgs84 != r.proj {
+		return newError(function, "composite does not match source grid")
+	}
+	if layer < 0 || layer >= c.Dz {
+		return newError(function, "invalid layer")
+	}
+	return nil
+}
+
+// reprojectLayer writes the reprojected layer of c to dst. Missing source values
+// are skipped and the remaining weights are normalised.
+func (r *Reprojection) reprojectLayer(dst []float32, c *Composite, layer int) {
+	src := c.DataZ[layer]
+
+	for i := range dst {
+		var sum, weight float64
+		for j := r.start[i]; j < r.start[i+1]; j++ {
+			idx := int(r.index[j])
+			v := src[idx/r.dx][idx%r.dx]
+			if IsNaN(v) {
+				continue
+			}
+			sum += float64(r.weight[j]) * float64(v)
+			weight += float64(r.weight[j])
+		}
+
+		dst[i] = NaN
+		if weight > 0 {
+			dst[i] = float32(sum / weight)
+		}
+	}
+}
+
+// Raster returns the given layer of the composite reprojected to the target
+// grid. The composite must share the source grid of the reprojection.
+func (r *Reprojection) Raster(c *Composite, layer int) (*Raster, error) {
+	if err := r.check("Raster", c, layer); err != nil {
+		return nil, err
+	}
+
+	raster := &Raster{Width: r.width, Height: r.height, Data: make([]float32, r.width*r.height), Grid: r.Target}
+	r.reprojectLayer(raster.Data, c, layer)
+	return raster, nil
+}
+
+// Composite returns the composite reprojected to the target grid, which must
+// be a CompositeGrid. All layers are reprojected and the product, times and
+// unit of the source composite are retained, whereas the format version of the
+// target determines the projection.
+func (r *Reprojection) Composite(c *Composite) (*Composite, error) {
+	target, ok := r.Target.(CompositeGrid)
+	if !ok {
+		return nil, newError("Composite", "target is not a composite grid")
+	}
+	if err := r.check("Composite", c, 0); err != nil {
+		return nil, err
+	}
+
+	tmpl := *target.Composite
+	tmpl.Dz = c.Dz
+	comp := tmpl.emptyLike()
+	comp.Product = c.Product
+	comp.CaptureTime = c.CaptureTime
+	comp.ForecastTime = c.ForecastTime
+	comp.Interval = c.Interval
+	comp.DataUnit = c.DataUnit
+	comp.precision = c.precision
+
+	for z := 0; z < c.Dz; z++ {
+		r.reprojectLayer(comp.Layer(z), c, z)
+	}
+	return comp, nil
+}
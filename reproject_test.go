@@ -0,0 +1,175 @@
+package radolan
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReprojection(t *testing.T) {
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		if x > 600 {
+			return NaN
+		}
+		return float32(x + 1000*y)
+	})
+
+	// 0.1 degree grid covering germany
+	target := LatLonGrid{North: 55, West: 5, ResLat: 0.1, ResLon: 0.1, Width: 100, Height: 80}
+
+	for _, method := range []struct {
+		resampling Resampling
+		sample     SampleMethod
+	}{{ResampleNearest, Nearest}, {ResampleBilinear, Bilinear}} {
+		r, err := NewReprojection(comp, target, method.resampling)
+		if err != nil {
+			t.Fatalf("NewReprojection(%d): returned error: %#v", method.resampling, err.Error())
+		}
+
+		raster, err := r.Raster(comp, 0)
+		if err != nil {
+			t.Fatalf("Reprojection(%d).Raster(): returned error: %#v", method.resampling, err.Error())
+		}
+		if raster.Width != 100 || raster.Height != 80 || len(raster.Data) != 8000 || raster.Grid != target {
+			t.Fatalf("Reprojection(%d).Raster(): unexpected raster dimensions", method.resampling)
+		}
+
+		// consistent with point sampling
+		for y := 0; y < raster.Height; y++ {
+			for x := 0; x < raster.Width; x++ {
+				north, east := target.Unproject(float64(x)+0.5, float64(y)+0.5)
+				exp := comp.Sample(north, east, method.sample)
+				v := raster.Data[y*raster.Width+x]
+				if !(absequal(float64(v), float64(exp), 0.1) || IsNaN(v) && IsNaN(exp)) {
+					t.Fatalf("Reprojection(%d).Raster()[%d, %d] = %f; expected: %f", method.resampling, x, y, v, exp)
+				}
+			}
+		}
+	}
+
+	// the reprojection is bound to the source grid
+	r, _ := NewReprojection(comp, target, ResampleNearest)
+	fx := newTestComposite("FX", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 { return 0 })
+	if _, err := r.Raster(fx, 0); err == nil {
+		t.Errorf("Reprojection.Raster(FX): expected error for different grid")
+	}
+}
+
+func TestReprojectionConservative(t *testing.T) {
+	// 2x2 blocks of 1 km pixels holding 0, 1, 2 and 3 (NaN)
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		v := x%2 + 2*(y%2)
+		if v == 3 {
+			return NaN
+		}
+		return float32(v)
+	})
+
+	// covering 10 x 10 source pixels each
+	dummy := NewDummy("RW", 3, 900, 900)
+	north, east := dummy.PixelCorner(100, 100)
+	target := LatLonGrid{North: north, West: east, ResLat: 0.09, ResLon: 0.14, Width: 20, Height: 20}
+
+	r, err := NewReprojection(comp, target, ResampleConservative)
+	if err != nil {
+		t.Fatalf("NewReprojection(conservative): returned error: %#v", err.Error())
+	}
+	raster, _ := r.Raster(comp, 0)
+
+	// average of the valid values
+	for i, v := range raster.Data {
+		if !absequal(float64(v), 1, 0.1) {
+			t.Fatalf("Reprojection(conservative).Raster()[%d] = %f; expected: 1", i, v)
+		}
+	}
+}
+
+func TestReprojectionConservativeCoarse(t *testing.T) {
+	// single pixel holding 1 in Kassel
+	dummy := NewDummy("RW", 3, 900, 900)
+	hx, hy := dummy.Project(51.3, 9.5)
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		if x == int(hx) && y == int(hy) {
+			return 1
+		}
+		return 0
+	})
+
+	// 1 degree cells covering thousands of source pixels each
+	target := LatLonGrid{North: 54, West: 6, ResLat: 1, ResLon: 1, Width: 9, Height: 7}
+	r, err := NewReprojection(comp, target, ResampleConservative)
+	if err != nil {
+		t.Fatalf("NewReprojection(conservative): returned error: %#v", err.Error())
+	}
+	raster, _ := r.Raster(comp, 0)
+
+	// the pixel is weighted by its share of the area of the target cell
+	i := 2*target.Width + 3 // 51N - 52N, 9E - 10E
+	var corners [4][2]float64
+	for j, p := range [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		corners[j][0], corners[j][1] = comp.Project(target.Unproject(3+p[0], 2+p[1]))
+	}
+	var area float64
+	for j := range corners {
+		next := corners[(j+1)%len(corners)]
+		area += (corners[j][0]*next[1] - next[0]*corners[j][1]) / 2
+	}
+	area = math.Abs(area)
+
+	if v := float64(raster.Data[i]) * area; !absequal(v, 1, 0.01) {
+		t.Errorf("Reprojection(conservative).Raster()[%d] = %g; expected: %g", i, raster.Data[i], 1/area)
+	}
+	if n := int(r.start[i+1] - r.start[i]); float64(n) < area {
+		t.Errorf("Reprojection(conservative): %d source pixels of target pixel %d; expected: at least %.0f", n, i, area)
+	}
+	for j, v := range raster.Data {
+		if j != i && v != 0 {
+			t.Errorf("Reprojection(conservative).Raster()[%d] = %g; expected: 0", j, v)
+		}
+	}
+}
+
+func TestReprojectionGrids(t *testing.T) {
+	// rotated grid of a weather model: rotated origin is located at 50N 10E
+	rotated := RotatedGrid{PoleNorth: 40, PoleEast: -170, North: 0, West: 0, ResLat: 0.02, ResLon: 0.02, Width: 1, Height: 1}
+	if north, east := rotated.Unproject(0, 0); !absequal(north, 50, 1e-9) || !absequal(east, 10, 1e-9) {
+		t.Errorf("RotatedGrid.Unproject(0, 0) = (%f, %f); expected: (50, 10)", north, east)
+	}
+	if north, east := rotated.Unproject(0, -90/0.02); !absequal(north, 40, 1e-9) || !absequal(math.Abs(east), 170, 1e-9) {
+		t.Errorf("RotatedGrid.Unproject(north pole) = (%f, %f); expected: (40, -170)", north, east)
+	}
+
+	// web mercator tile 0/0/0
+	mercator := WebMercatorGrid{West: -20037508.342789244, North: 20037508.342789244, Resolution: 156543.03392804097,
+		Width: 256, Height: 256}
+	if north, east := mercator.Unproject(128, 128); !absequal(north, 0, 1e-9) || !absequal(east, 0, 1e-9) {
+		t.Errorf("WebMercatorGrid.Unproject(128, 128) = (%f, %f); expected: (0, 0)", north, east)
+	}
+	if north, _ := mercator.Unproject(0, 0); !absequal(north, 85.0511287798, 1e-9) {
+		t.Errorf("WebMercatorGrid.Unproject(0, 0): north = %f; expected: 85.0511", north)
+	}
+
+	// national grid to DE1200 grid
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		return float32(x + 1000*y)
+	})
+	de1200 := NewDummy("WN", 5, 1100, 1200)
+	r, err := NewReprojection(comp, CompositeGrid{de1200}, ResampleNearest)
+	if err != nil {
+		t.Fatalf("NewReprojection(DE1200): returned error: %#v", err.Error())
+	}
+	out, err := r.Composite(comp)
+	if err != nil {
+		t.Fatalf("Reprojection(DE1200).Composite(): returned error: %#v", err.Error())
+	}
+	if out.Dx != 1100 || out.Dy != 1200 || out.Product != "RW" || out.DataUnit != Unit_mm || !out.HasProjection {
+		t.Fatalf("Reprojection(DE1200).Composite(): unexpected composite: %dx%d %s %s", out.Dx, out.Dy, out.Product, out.DataUnit)
+	}
+
+	north, east := 52.51861, 13.40833 // Berlin
+	if v, exp := out.Sample(north, east, Nearest), comp.Sample(north, east, Nearest); math.Abs(float64(v-exp)) > 1001 {
+		t.Errorf("Reprojection(DE1200).Composite(): Berlin: %f; expected: %f", v, exp)
+	}
+	if _, err := NewReprojection(comp, mercator, ResampleNearest); err != nil {
+		t.Errorf("NewReprojection(WebMercator): returned error: %#v", err.Error())
+	}
+}
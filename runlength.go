@@ -2,30 +2,31 @@ package radolan
 
 import (
 	"bufio"
+	"bytes"
 )
 
-// parseRunlength parses the runlength encoded composite and writes into the
-// previously created PlainData field of the composite.
-func (c *Composite) parseRunlength(reader *bufio.Reader) error {
-	for i := range c.PlainData {
-		line, err := c.readLineRunlength(reader)
-		if err != nil {
-			return err
-		}
-
-		err = c.decodeRunlength(c.PlainData[i], line)
-		if err != nil {
-			return err
-		}
+// parseRowRunlength parses the next line of the runlength encoded composite and
+// writes to the given destination. The line buffer and flags are not used.
+func (c *Composite) parseRowRunlength(reader *bufio.Reader, _ []byte, dst []float32, _ []PixelFlag) error {
+	line, err := c.readLineRunlength(reader)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return c.decodeRunlength(dst, line)
 }
 
 // readLineRunlength reads a line until newline (non inclusive) from the given reader.
-// This method is used to get a line of runlenth encoded data.
+// This method is used to get a line of runlenth encoded data. The returned line is
+// only valid until the next read operation.
 func (c *Composite) readLineRunlength(rd *bufio.Reader) (line []byte, err error) {
-	line, err = rd.ReadBytes('\x0A')
+	line, err = rd.ReadSlice('\x0A')
+	if err == bufio.ErrBufferFull { // line exceeds buffer size
+		var rest []byte
+		line = append([]byte(nil), line...)
+		rest, err = rd.ReadBytes('\x0A')
+		line = append(line, rest...)
+	}
 	if err != nil {
 		err = newError("readLineRunlength", err.Error())
 	}
@@ -87,3 +88,75 @@ func (c *Composite) rvp6Runlength(value byte) float32 {
 	}
 	return c.level[value]
 }
+
+// encodeRunlength encodes the PlainData field of the composite line by line as
+// runlength based format and writes the result to w.
+func (c *Composite) encodeRunlength(w *bytes.Buffer) error {
+	if len(c.level) > 15 { // level index must fit in four bits
+		return newError("encodeRunlength", "too many levels")
+	}
+
+	for i, src := range c.PlainData {
+		// the line number is ignored by the decoder but must not
+		// collide with the line delimiter
+		number := byte(i)
+		if number == '\x0A' {
+			number++
+		}
+		w.WriteByte(number)
+
+		c.encodeLineRunlength(w, src)
+		w.WriteByte('\x0A')
+	}
+
+	return nil
+}
+
+// encodeLineRunlength encodes the source line and writes to w.
+func (c *Composite) encodeLineRunlength(w *bytes.Buffer, src []float32) {
+	// trailing gaps are implied by the line delimiter
+	end := len(src)
+	for end > 0 && IsNaN(src[end-1]) {
+		end--
+	}
+
+	// leading gaps are written as offset
+	start := 0
+	for start < end && IsNaN(src[start]) {
+		start++
+	}
+	offset := start
+	for ; offset >= 239; offset -= 239 {
+		w.WriteByte(255) // next byte will be also offset
+	}
+	w.WriteByte(byte(offset + 16))
+
+	for i := start; i < end; {
+		value := c.levelRunlength(src[i])
+
+		// value [XXXX|YYYY] decodes to YYYY repeated XXXX times.
+		runlength := 1
+		for i+runlength < end && runlength < 15 && c.levelRunlength(src[i+runlength]) == value {
+			runlength++
+		}
+		w.WriteByte(byte(runlength<<4) | value)
+		i += runlength
+	}
+}
+
+// levelRunlength returns the raw level index of the given value in runlength
+// based composite products. Values between two levels are assigned to the lower
+// level. This is the inverse of rvp6Runlength.
+func (c *Composite) levelRunlength(value float32) byte {
+	if IsNaN(value) {
+		return 0
+	}
+
+	index := 0
+	for i, l := range c.level {
+		if value >= l {
+			index = i
+		}
+	}
+	return byte(index + 1)
+}
@@ -0,0 +1,155 @@
+package radolan
+
+import (
+	"math"
+)
+
+// SampleMethod derives the value of layer z at the given data indices from the
+// surrounding pixels. The pixel (x, y) covers the data indices [x, x+1) and
+// [y, y+1), so that its center is located at (x+0.5, y+0.5). NaN is returned
+// if no value can be derived.
+type SampleMethod func(c *Composite, x, y float64, z int) float32
+
+// Location is a point in geographical coordinates, e.g. the position of a
+// measuring station.
+type Location struct {
+	North float64 // latitude
+	East  float64 // longitude
+}
+
+var (
+	// Nearest returns the value of the pixel containing the point.
+	Nearest SampleMethod = sampleNearest
+
+	// Bilinear interpolates between the centers of the four surrounding
+	// pixels. Missing values are skipped and the remaining weights are
+	// normalised.
+	Bilinear SampleMethod = sampleBilinear
+)
+
+// MaxInRadius returns a sample method which yields the maximum value of all
+// pixels whose center is located within the given radius (km) around the
+// point.
+func MaxInRadius(radius float64) SampleMethod {
+	return func(c *Composite, x, y float64, z int) float32 {
+		if !(c.Rx > 0) || !(c.Ry > 0) || !(radius >= 0) || math.IsNaN(x) || math.IsNaN(y) {
+			return NaN
+		}
+
+		// pixel range covering the circle, clamped to the grid before the
+		// conversion to int
+		dx, dy := radius/c.Rx, radius/c.Ry
+		x0, x1 := clampIndex(math.Floor(x-dx-0.5), c.Dx), clampIndex(math.Ceil(x+dx-0.5), c.Dx)
+		y0, y1 := clampIndex(math.Floor(y-dy-0.5), c.Dy), clampIndex(math.Ceil(y+dy-0.5), c.Dy)
+
+		max := NaN
+		for py := y0; py <= y1; py++ {
+			for px := x0; px <= x1; px++ {
+				ex := (float64(px) + 0.5 - x) * c.Rx // distance in km
+				ey := (float64(py) + 0.5 - y) * c.Ry
+				if ex*ex+ey*ey > radius*radius {
+					continue
+				}
+
+				if v := c.AtZ(px, py, z); !IsNaN(v) && (IsNaN(max) || v > max) {
+					max = v
+				}
+			}
+		}
+		return max
+	}
+}
+
+// clampIndex returns the pixel index v limited to the range [0, n-1].
+func clampIndex(v float64, n int) int {
+	return int(math.Max(0, math.Min(v, float64(n-1))))
+}
+
+// sampleNearest implements the Nearest sample method.
+func sampleNearest(c *Composite, x, y float64, z int) float32 {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return NaN
+	}
+	return c.AtZ(int(math.Floor(x)), int(math.Floor(y)), z)
+}
+
+// sampleBilinear implements the Bilinear sample method.
+func sampleBilinear(c *Composite, x, y float64, z int) float32 {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return NaN
+	}
+
+	// relative to the surrounding pixel centers
+	fx, fy := x-0.5, y-0.5
+	x0, y0 := math.Floor(fx), math.Floor(fy)
+	tx, ty := fx-x0, fy-y0
+
+	var sum, weight float64
+	for _, n := range [4]struct {
+		dx, dy int
+		w      float64
+	}{
+		{0, 0, (1 - tx) * (1 - ty)},
+		{1, 0, tx * (1 - ty)},
+		{0, 1, (1 - tx) * ty},
+		{1, 1, tx * ty},
+	} {
+		v := c.AtZ(int(x0)+n.dx, int(y0)+n.dy, z)
+		if IsNaN(v) || n.w == 0 {
+			continue
+		}
+		sum += n.w * float64(v)
+		weight += n.w
+	}
+
+	if weight == 0 {
+		return NaN
+	}
+	return float32(sum / weight)
+}
+
+// Sample returns the value at the given geographical coordinates (latitude
+// north, longitude east) of the first layer derived by the sample method. NaN
+// is returned if no projection is available or the point is located outside
+// the composite.
+func (c *Composite) Sample(north, east float64, method SampleMethod) float32 {
+	x, y := c.Project(north, east)
+	return method(c, x, y, 0)
+}
+
+// SampleZ is like Sample, but interpolates linearly between the layers of 3D
+// products. The fractional layer index z ranges from 0 to c.Dz-1. If one of
+// the two layers holds no value, the value of the other layer is returned.
+func (c *Composite) SampleZ(north, east, z float64, method SampleMethod) float32 {
+	if z < 0 || z > float64(c.Dz-1) {
+		return NaN
+	}
+
+	x, y := c.Project(north, east)
+	z0 := math.Floor(z)
+	v0 := method(c, x, y, int(z0))
+	if z == z0 {
+		return v0
+	}
+
+	v1 := method(c, x, y, int(z0)+1)
+	switch {
+	case IsNaN(v0):
+		return v1
+	case IsNaN(v1):
+		return v0
+	}
+
+	t := float32(z - z0)
+	return v0*(1-t) + v1*t
+}
+
+// SampleLocations samples the first layer at each of the given locations like
+// Sample.
+func (c *Composite) SampleLocations(locations []Location, method SampleMethod) []float32 {
+	values := make([]float32, len(locations))
+	for i, l := range locations {
+		values[i] = c.Sample(l.North, l.East, method)
+	}
+	return values
+}
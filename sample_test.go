@@ -0,0 +1,110 @@
+package radolan
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		if x == 300 && y == 300 {
+			return NaN
+		}
+		return float32(x + 1000*y)
+	})
+
+	testcases := []struct {
+		x, y     float64
+		nearest  float32
+		bilinear float32
+	}{
+		{100.5, 200.5, 200100, 200100},      // pixel center
+		{100.02, 200.02, 200100, 199619.52}, // near pixel corner
+		{100.75, 200.5, 200100, 200100.25},
+		{299.75, 299.5, 299299, 299299.25}, // missing right neighbour is skipped
+		{-10, 5, NaN, NaN},
+	}
+
+	for _, test := range testcases {
+		north, east := comp.Unproject(test.x, test.y)
+
+		if v := comp.Sample(north, east, Nearest); !(v == test.nearest || IsNaN(v) && IsNaN(test.nearest)) {
+			t.Errorf("RW.Sample(%f, %f, Nearest) = %f; expected: %f", test.x, test.y, v, test.nearest)
+		}
+		if v := comp.Sample(north, east, Bilinear); !(absequal(float64(v), float64(test.bilinear), 0.05) ||
+			IsNaN(v) && IsNaN(test.bilinear)) {
+			t.Errorf("RW.Sample(%f, %f, Bilinear) = %f; expected: %f", test.x, test.y, v, test.bilinear)
+		}
+	}
+
+	// 2 km around the center of pixel (100, 200) include 13 pixel centers
+	north, east := comp.PixelCenter(100, 200)
+	if v := comp.Sample(north, east, MaxInRadius(2)); v != 202100 {
+		t.Errorf("RW.Sample(MaxInRadius(2)) = %f; expected: %f", v, 202100.0)
+	}
+	if v := comp.Sample(north, east, MaxInRadius(0.1)); v != 200100 {
+		t.Errorf("RW.Sample(MaxInRadius(0.1)) = %f; expected: %f", v, 200100.0)
+	}
+
+	// the radius is limited by the grid
+	for _, radius := range []float64{1e6, math.Inf(1)} {
+		if v := comp.Sample(north, east, MaxInRadius(radius)); v != 899899 {
+			t.Errorf("RW.Sample(MaxInRadius(%g)) = %f; expected: %f", radius, v, 899899.0)
+		}
+	}
+	for _, test := range [][3]float64{ // x, y, radius
+		{math.NaN(), 200.5, 2},
+		{100.5, math.NaN(), 2},
+		{100.5, 200.5, math.NaN()},
+		{100.5, 200.5, -1},
+	} {
+		if v := MaxInRadius(test[2])(comp, test[0], test[1], 0); !IsNaN(v) {
+			t.Errorf("MaxInRadius(%g)(%g, %g) = %f; expected: NaN", test[2], test[0], test[1], v)
+		}
+	}
+
+	values := comp.SampleLocations([]Location{{north, east}, {0, 0}}, Nearest)
+	if values[0] != 200100 || !IsNaN(values[1]) {
+		t.Errorf("RW.SampleLocations() = %v; expected: [200100 NaN]", values)
+	}
+
+	dummy := NewDummy("XX", 0, 10, 10)
+	if v := dummy.Sample(north, east, Bilinear); !IsNaN(v) {
+		t.Errorf("XX.Sample() = %f; expected: NaN", v)
+	}
+	if v := MaxInRadius(2)(dummy, 0.5, 0.5, 0); !IsNaN(v) {
+		t.Errorf("XX.MaxInRadius() = %f; expected: NaN", v)
+	}
+}
+
+func TestSampleZ(t *testing.T) {
+	// national grid with 3 layers
+	comp := newTestComposite("RX", 3, 900, 2700, 0, nil, func(c *Composite, x, y int) float32 {
+		if y/900 == 1 && x == 50 {
+			return NaN
+		}
+		return float32(y/900*10 + 1)
+	})
+	comp.Dy = 900
+	comp.arrangeData()
+	comp.calibrateProjection()
+
+	north, east := comp.PixelCenter(50, 50)
+	testcases := []struct {
+		z   float64
+		exp float32
+	}{
+		{0, 1}, {0.25, 1}, {1.5, 21}, {2, 21}, {-1, NaN}, {2.5, NaN}, // layer 1 is missing
+	}
+	for _, test := range testcases {
+		v := comp.SampleZ(north, east, test.z, Nearest)
+		if !(absequal(float64(v), float64(test.exp), 1e-4) || IsNaN(v) && IsNaN(test.exp)) {
+			t.Errorf("RX.SampleZ(%f) = %f; expected: %f", test.z, v, test.exp)
+		}
+	}
+
+	north, east = comp.PixelCenter(51, 50)
+	if v := comp.SampleZ(north, east, 0.25, Nearest); v != 3.5 {
+		t.Errorf("RX.SampleZ(0.25) = %f; expected: 3.5", v)
+	}
+}
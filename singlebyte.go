@@ -2,37 +2,28 @@ package radolan
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 )
 
-// parseSingleByte parses the single byte encoded composite as described in [1] and writes
-// into the previously created PlainData field of the composite.
-func (c *Composite) parseSingleByte(reader *bufio.Reader) error {
-	last := len(c.PlainData) - 1
-	for i := range c.PlainData {
-		line, err := c.readLineSingleByte(reader)
-		if err != nil {
-			return err
-		}
-
-		err = c.decodeSingleByte(c.PlainData[last-i], line) // write vertically flipped
-		if err != nil {
-			return err
-		}
+// parseRowSingleByte parses the next line of the single byte encoded composite as
+// described in [1] and writes to the given destination. The line buffer must hold one
+// byte for each value. The flags are not used.
+func (c *Composite) parseRowSingleByte(reader *bufio.Reader, line []byte, dst []float32, _ []PixelFlag) error {
+	if err := c.readLineSingleByte(reader, line); err != nil {
+		return err
 	}
 
-	return nil
+	return c.decodeSingleByte(dst, line)
 }
 
-// readLineSingleByte reads a line until horizontal limit from the given reader
+// readLineSingleByte fills the given line from the given reader.
 // This method is used to get a line of single byte encoded data.
-func (c *Composite) readLineSingleByte(rd *bufio.Reader) (line []byte, err error) {
-	line = make([]byte, c.Dx)
-	_, err = io.ReadFull(rd, line)
-	if err != nil {
-		err = newError("readLineSingleByte", err.Error())
+func (c *Composite) readLineSingleByte(rd *bufio.Reader, line []byte) error {
+	if _, err := io.ReadFull(rd, line); err != nil {
+		return newError("readLineSingleByte", err.Error())
 	}
-	return
+	return nil
 }
 
 // decodeSingleByte decodes the source line and writes to the given destination.
@@ -65,3 +56,52 @@ func (c *Composite) rvp6SingleByte(value byte) float32 {
 
 	return toDBZ(conv)
 }
+
+// encodeSingleByte encodes the PlainData field of the composite as single byte
+// format and writes the result to w.
+func (c *Composite) encodeSingleByte(w *bytes.Buffer) error {
+	line := make([]byte, c.Px)
+	for i := range c.PlainData {
+		err := c.encodeLineSingleByte(line, c.PlainData[len(c.PlainData)-1-i]) // write vertically flipped
+		if err != nil {
+			return err
+		}
+		w.Write(line)
+	}
+
+	return nil
+}
+
+// encodeLineSingleByte encodes the source line and writes to the given destination.
+func (c *Composite) encodeLineSingleByte(dst []byte, src []float32) error {
+	if len(dst) != len(src) {
+		return newError("encodeSingleByte", "wrong destination or source size")
+	}
+
+	for i, v := range src {
+		dst[i] = c.rawSingleByte(v)
+	}
+
+	return nil
+}
+
+// rawSingleByte converts the given value to the raw byte of single byte encoded
+// composite products. This is the inverse of rvp6SingleByte.
+func (c *Composite) rawSingleByte(value float32) byte {
+	if IsNaN(value) { // error code: no-data
+		return 250
+	}
+
+	if c.DataUnit == Unit_dBZ {
+		value = toRVP6(value)
+	}
+
+	raw := c.rawRVP6(value)
+	if raw < 0 {
+		raw = 0
+	}
+	if raw > 249 {
+		raw = 249
+	}
+	return byte(raw)
+}
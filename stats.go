@@ -0,0 +1,186 @@
+package radolan
+
+import (
+	"math"
+	"sort"
+)
+
+// Mask selects the pixels of a layer for the computation of statistics. The
+// element [y][x] holds the weight of the pixel at (x, y) in the range 0 to 1,
+// where 0 excludes the pixel and fractional values represent partially covered
+// pixels. nil rows exclude all pixels of the row.
+type Mask [][]float64
+
+// StatsOptions configures the computation of statistics.
+type StatsOptions struct {
+	Layer     int       // z-layer to evaluate
+	Mask      Mask      // weights of the pixels (all pixels are weighted 1 if nil)
+	Bins      []float64 // ascending edges of the histogram bins (optional)
+	Quantiles []float64 // requested quantiles in the range 0 to 1 (optional)
+}
+
+// Stats holds the statistics of a layer or masked region. The values are
+// weighted by the mask and missing values (NaN) are skipped. Min, Max, Mean
+// and StdDev are NaN if no valid value is available.
+type Stats struct {
+	Count    int     // number of considered pixels (weight > 0)
+	Valid    int     // number of considered pixels holding a valid value
+	Coverage float64 // weighted fraction of valid pixels (0 - 1)
+
+	Min    float64
+	Max    float64
+	Sum    float64 // weighted sum of the valid values
+	Mean   float64
+	StdDev float64
+
+	Histogram []float64 // weighted number of values in each bin [Bins[i], Bins[i+1])
+	Quantiles []float64 // value of each requested quantile
+}
+
+// sample is a valid value and its weight.
+type sample struct {
+	value  float64
+	weight float64
+}
+
+// Stats returns the statistics of the data values selected by the options. nil
+// options evaluate all pixels of the first layer. The last histogram bin
+// includes its upper edge and values outside of the bins are not counted. The
+// quantile q is the smallest value whose cumulative weight reaches q times the
+// total weight.
+func (c *Composite) Stats(opts *StatsOptions) (Stats, error) {
+	return c.stats("Stats", opts, false)
+}
+
+// ReflectivityStats is like Stats, but averages reflectivity in its linear
+// representation Z instead of dBZ. The mean is converted back to dBZ, whereas
+// the other fields are computed from the dBZ values. An error is returned if
+// the composite does not hold reflectivity data.
+func (c *Composite) ReflectivityStats(opts *StatsOptions) (Stats, error) {
+	if c.DataUnit != Unit_dBZ {
+		return Stats{}, newError("ReflectivityStats", "reflectivity (dBZ) data required")
+	}
+	return c.stats("ReflectivityStats", opts, true)
+}
+
+// stats collects the selected values and computes their statistics. If linear
+// is set, the mean is computed in linear Z.
+func (c *Composite) stats(function string, opts *StatsOptions, linear bool) (Stats, error) {
+	var o StatsOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.Layer < 0 || o.Layer >= c.Dz {
+		return Stats{}, newError(function, "invalid layer")
+	}
+	if o.Mask != nil && len(o.Mask) != c.Dy {
+		return Stats{}, newError(function, "mask does not match data dimensions")
+	}
+	for i := 1; i < len(o.Bins); i++ {
+		if o.Bins[i] <= o.Bins[i-1] {
+			return Stats{}, newError(function, "bins are not ascending")
+		}
+	}
+
+	s := Stats{Min: math.NaN(), Max: math.NaN(), Mean: math.NaN(), StdDev: math.NaN()}
+	var samples []sample
+	var total float64 // weight of all considered pixels
+
+	for y, row := range c.DataZ[o.Layer] {
+		if o.Mask != nil && o.Mask[y] == nil {
+			continue // excluded row
+		}
+		if o.Mask != nil && len(o.Mask[y]) != c.Dx {
+			return Stats{}, newError(function, "mask does not match data dimensions")
+		}
+
+		for x, v := range row {
+			w := 1.0
+			if o.Mask != nil {
+				w = o.Mask[y][x]
+			}
+			if w <= 0 {
+				continue
+			}
+
+			s.Count++
+			total += w
+			if !IsNaN(v) {
+				samples = append(samples, sample{float64(v), w})
+			}
+		}
+	}
+
+	if o.Bins != nil && len(o.Bins) > 1 {
+		s.Histogram = make([]float64, len(o.Bins)-1)
+	}
+	if o.Quantiles != nil {
+		s.Quantiles = make([]float64, len(o.Quantiles))
+		for i := range s.Quantiles {
+			s.Quantiles[i] = math.NaN()
+		}
+	}
+
+	s.Valid = len(samples)
+	if s.Valid == 0 {
+		return s, nil
+	}
+
+	// moments
+	var weight, sum, linearSum float64
+	s.Min, s.Max = math.Inf(1), math.Inf(-1)
+	for _, p := range samples {
+		weight += p.weight
+		sum += p.weight * p.value
+		if linear {
+			linearSum += p.weight * toLinearZ(float32(p.value))
+		}
+		s.Min = math.Min(s.Min, p.value)
+		s.Max = math.Max(s.Max, p.value)
+	}
+	s.Coverage = weight / total
+	s.Sum = sum
+
+	mean := sum / weight
+	var variance float64
+	for _, p := range samples {
+		variance += p.weight * (p.value - mean) * (p.value - mean)
+	}
+	s.StdDev = math.Sqrt(variance / weight)
+
+	s.Mean = mean
+	if linear {
+		s.Mean = float64(fromLinearZ(linearSum / weight))
+	}
+
+	// histogram
+	for _, p := range samples {
+		i := sort.SearchFloat64s(o.Bins, p.value) // first edge >= value
+		switch {
+		case i < len(o.Bins) && o.Bins[i] == p.value && i < len(s.Histogram):
+			s.Histogram[i] += p.weight
+		case i > 0 && i < len(o.Bins):
+			s.Histogram[i-1] += p.weight
+		}
+	}
+
+	// quantiles
+	if len(o.Quantiles) > 0 {
+		sort.Slice(samples, func(i, j int) bool { return samples[i].value < samples[j].value })
+
+		for i, q := range o.Quantiles {
+			s.Quantiles[i] = samples[len(samples)-1].value // rounding errors
+			var cumulative float64
+			for _, p := range samples {
+				cumulative += p.weight
+				if cumulative >= q*weight {
+					s.Quantiles[i] = p.value
+					break
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
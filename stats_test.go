@@ -0,0 +1,95 @@
+package radolan
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	// values 0 to 9 repeated in each row, last column missing
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+		if x == 899 {
+			return NaN
+		}
+		return float32(x % 10)
+	})
+
+	s, err := comp.Stats(&StatsOptions{Bins: []float64{0, 5, 9}, Quantiles: []float64{0, 0.5, 1}})
+	if err != nil {
+		t.Fatalf("RW.Stats(): returned error: %#v", err.Error())
+	}
+
+	valid := 899 * 900
+	if s.Count != 900*900 || s.Valid != valid || !absequal(s.Coverage, 899.0/900, 1e-9) {
+		t.Errorf("RW.Stats(): Count: %d Valid: %d Coverage: %f", s.Count, s.Valid, s.Coverage)
+	}
+	if s.Min != 0 || s.Max != 9 {
+		t.Errorf("RW.Stats(): Min: %f Max: %f; expected: 0 9", s.Min, s.Max)
+	}
+
+	// 90 complete sequences 0 to 9 minus the 9 of column 899 in each row
+	expMean := (90*45.0 - 9) / 899
+	if !absequal(s.Mean, expMean, 1e-9) {
+		t.Errorf("RW.Stats(): Mean: %f; expected: %f", s.Mean, expMean)
+	}
+	if s.StdDev < 2.8 || s.StdDev > 2.9 {
+		t.Errorf("RW.Stats(): StdDev: %f; expected: 2.87", s.StdDev)
+	}
+	if len(s.Histogram) != 2 || s.Histogram[0] != 5*90*900 || s.Histogram[1] != 5*90*900-900 {
+		t.Errorf("RW.Stats(): Histogram: %v", s.Histogram)
+	}
+	if s.Quantiles[0] != 0 || s.Quantiles[1] != 4 || s.Quantiles[2] != 9 {
+		t.Errorf("RW.Stats(): Quantiles: %v; expected: [0 4 9]", s.Quantiles)
+	}
+
+	// masked region with fractional weights
+	mask := make(Mask, comp.Dy)
+	for y := range mask {
+		mask[y] = make([]float64, comp.Dx)
+	}
+	mask[10][1], mask[10][2], mask[10][899] = 1, 0.5, 1
+	s, err = comp.Stats(&StatsOptions{Mask: mask})
+	if err != nil {
+		t.Fatalf("RW.Stats(mask): returned error: %#v", err.Error())
+	}
+	if s.Count != 3 || s.Valid != 2 || !absequal(s.Coverage, 1.5/2.5, 1e-9) || !absequal(s.Mean, 2.0/1.5, 1e-9) {
+		t.Errorf("RW.Stats(mask): Count: %d Valid: %d Coverage: %f Mean: %f", s.Count, s.Valid, s.Coverage, s.Mean)
+	}
+
+	// empty region
+	mask[10][1], mask[10][2] = 0, 0
+	s, _ = comp.Stats(&StatsOptions{Mask: mask, Quantiles: []float64{0.5}})
+	if s.Valid != 0 || !math.IsNaN(s.Mean) || !math.IsNaN(s.Quantiles[0]) {
+		t.Errorf("RW.Stats(empty): Valid: %d Mean: %f Quantiles: %v", s.Valid, s.Mean, s.Quantiles)
+	}
+
+	if _, err := comp.Stats(&StatsOptions{Mask: mask[1:]}); err == nil {
+		t.Errorf("RW.Stats(): expected error for mask dimensions")
+	}
+	if _, err := comp.ReflectivityStats(nil); err == nil {
+		t.Errorf("RW.ReflectivityStats(): expected error for unit mm")
+	}
+}
+
+func TestReflectivityStats(t *testing.T) {
+	// 10 dBZ and 30 dBZ average to 27.03 dBZ in linear Z
+	comp := newTestComposite("FX", 3, 450, 450, -1, nil, func(c *Composite, x, y int) float32 {
+		return float32(10 + 20*(x%2))
+	})
+
+	s, err := comp.ReflectivityStats(nil)
+	if err != nil {
+		t.Fatalf("FX.ReflectivityStats(): returned error: %#v", err.Error())
+	}
+	if exp := 10 * math.Log10((10+1000)/2.0); !absequal(s.Mean, exp, 1e-4) {
+		t.Errorf("FX.ReflectivityStats(): Mean: %f; expected: %f", s.Mean, exp)
+	}
+	if s.StdDev != 10 {
+		t.Errorf("FX.ReflectivityStats(): StdDev: %f; expected: 10", s.StdDev)
+	}
+
+	s, _ = comp.Stats(nil)
+	if s.Mean != 20 {
+		t.Errorf("FX.Stats(): Mean: %f; expected: 20", s.Mean)
+	}
+}
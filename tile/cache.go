@@ -0,0 +1,75 @@
+package tile
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is a least recently used cache of limited size. It is safe for
+// concurrent use.
+type cache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // most recently used at the front
+}
+
+// cacheItem is the value of a list element.
+type cacheItem struct {
+	key   string
+	value interface{}
+}
+
+// newCache returns a cache holding at most capacity items. A capacity of zero
+// disables caching.
+func newCache(capacity int) *cache {
+	return &cache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the cached value of key and marks it as recently used.
+func (c *cache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheItem).value, true
+}
+
+// put stores the value of key and evicts the least recently used items
+// exceeding the capacity.
+func (c *cache) put(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*cacheItem).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheItem{key, value})
+	for c.order.Len() > c.capacity {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*cacheItem).key)
+	}
+}
+
+// len returns the number of cached items.
+func (c *cache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,33 @@
+package tile
+
+import (
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c := newCache(2)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.get("a") // b is least recently used
+	c.put("c", 3)
+
+	if _, ok := c.get("b"); ok {
+		t.Errorf("cache.get(b): found evicted item")
+	}
+	for key, expected := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.get(key); !ok || v.(int) != expected {
+			t.Errorf("cache.get(%s) = %v, %v; expected: %d, true", key, v, ok, expected)
+		}
+	}
+
+	c.put("a", 4)
+	if v, _ := c.get("a"); v.(int) != 4 || c.len() != 2 {
+		t.Errorf("cache.get(a) = %v after update; expected: 4", v)
+	}
+
+	disabled := newCache(0)
+	disabled.put("a", 1)
+	if _, ok := disabled.get("a"); ok {
+		t.Errorf("disabled cache.get(a): found item")
+	}
+}
@@ -0,0 +1,297 @@
+// Package tile serves RADOLAN composites as web map tiles. The tiles follow
+// the XYZ scheme (slippy map) of the Web Mercator projection used by common
+// web map libraries and are rendered on the fly from the composites of a
+// local directory.
+//
+// Tiles are requested by the path
+//
+//	/{product}/{time}/{z}/{x}/{y}.png
+//
+// where time is either the forecast time of the composite in UTC formatted as
+// 200601021504 or RFC 3339, or "latest" for the most recent composite of the
+// product. For forecast products, "latest" selects the composite with the
+// shortest lead time of the latest run.
+package tile
+
+import (
+	"bytes"
+	"fmt"
+	"gitlab.cs.fau.de/since/radolan"
+	"gitlab.cs.fau.de/since/radolan/radolan2png/vis"
+	"image"
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configures the tile server.
+type Options struct {
+	Size    int // tile width and height in pixels
+	MaxZoom int // maximum zoom level
+
+	Tiles      int // number of cached tiles
+	Composites int // number of cached composites
+
+	Method radolan.Resampling // resampling of the composites
+
+	// Colors returns the color function used to render the composite.
+	Colors func(c *radolan.Composite) vis.ColorFunc
+
+	// Transparent reports whether a value is drawn transparent (optional).
+	// Missing values (NaN) are always transparent.
+	Transparent func(val float64) bool
+
+	ErrorLog *log.Logger // logger for refresh errors (standard logger if nil)
+}
+
+// DefaultOptions are used when no options are given.
+var DefaultOptions = Options{
+	Size:       256,
+	MaxZoom:    12,
+	Tiles:      4096,
+	Composites: 8,
+	Method:     radolan.ResampleNearest,
+	Colors:     vis.DefaultColorFunc,
+}
+
+// Server is an http.Handler serving the tiles of the composites in a
+// directory. Archives and compressed files are supported like in
+// radolan.ScanIndex. Rendered tiles and decoded composites are cached.
+type Server struct {
+	dir  string
+	opts Options
+
+	scanMu sync.Mutex // serializes refreshes
+
+	mu    sync.RWMutex // guards files and index
+	files map[string]*fileState
+	index *radolan.Index
+
+	tiles      *cache // encoded png images by tile key
+	composites *cache // decoded composites by entry key
+}
+
+// NewServer returns a server for the composites in the directory dir. nil
+// options are replaced by DefaultOptions and zero Size, MaxZoom and Colors by
+// their defaults. The directory is scanned once; new files are picked up by
+// Refresh or Watch. Composites which cannot be read are returned as
+// radolan.MemberErrors along with a valid server.
+func NewServer(dir string, opts *Options) (*Server, error) {
+	o := DefaultOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Size <= 0 {
+		o.Size = DefaultOptions.Size
+	}
+	if o.MaxZoom <= 0 {
+		o.MaxZoom = DefaultOptions.MaxZoom
+	}
+	if o.Colors == nil {
+		o.Colors = DefaultOptions.Colors
+	}
+
+	s := &Server{
+		dir:        dir,
+		opts:       o,
+		index:      &radolan.Index{},
+		tiles:      newCache(o.Tiles),
+		composites: newCache(o.Composites),
+	}
+
+	if err := s.Refresh(); err != nil {
+		if _, ok := err.(radolan.MemberErrors); !ok {
+			return nil, err
+		}
+		return s, err
+	}
+	return s, nil
+}
+
+// ServeHTTP serves the tile requested by the path of r.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// /{product}/{time}/{z}/{x}/{y}.png
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 5 || !strings.HasSuffix(parts[4], ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	z, errZ := strconv.Atoi(parts[2])
+	x, errX := strconv.Atoi(parts[3])
+	y, errY := strconv.Atoi(strings.TrimSuffix(parts[4], ".png"))
+	if errZ != nil || errX != nil || errY != nil || z < 0 || z > s.opts.MaxZoom ||
+		x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		http.NotFound(w, r)
+		return
+	}
+
+	latest := parts[1] == "latest"
+	var t time.Time
+	if !latest {
+		var err error
+		if t, err = parseTime(parts[1]); err != nil {
+			http.Error(w, "invalid time: "+parts[1], http.StatusBadRequest)
+			return
+		}
+	}
+
+	entry, key, ok := s.lookup(parts[0], t, latest)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := s.tile(entry, key, z, x, y)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if latest {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// parseTime parses the time of a tile path.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse("200601021504", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// lookup returns the entry of the requested composite and a key identifying
+// its file contents. If several runs of a forecast product hold the requested
+// forecast time, the latest run is selected.
+func (s *Server) lookup(product string, t time.Time, latest bool) (radolan.Entry, string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []radolan.Entry
+	if latest {
+		entries = s.index.Latest(product)
+	} else {
+		entries = s.index.Query(product, t, t)
+	}
+	if len(entries) == 0 {
+		return radolan.Entry{}, "", false
+	}
+
+	e := entries[0]
+	for _, c := range entries[1:] {
+		if latest && c.ForecastTime.Before(e.ForecastTime) || !latest && c.CaptureTime.After(e.CaptureTime) {
+			e = c
+		}
+	}
+
+	var modTime time.Time
+	if f, ok := s.files[e.Path]; ok {
+		modTime = f.modTime
+	}
+	return e, fmt.Sprintf("%s\x00%s\x00%d", e.Path, e.Member, modTime.UnixNano()), true
+}
+
+// composite returns the decoded composite of the entry.
+func (s *Server) composite(entry radolan.Entry, key string) (*radolan.Composite, error) {
+	if c, ok := s.composites.get(key); ok {
+		return c.(*radolan.Composite), nil
+	}
+
+	// composites of units missing from the catalog are served as well
+	c, err := entry.Open()
+	if err != nil && err != radolan.ErrUnknownUnit {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("tile: no composite decoded from %s", entry.Path)
+	}
+	s.composites.put(key, c)
+	return c, nil
+}
+
+// tile returns the png encoded tile of the entry.
+func (s *Server) tile(entry radolan.Entry, key string, z, x, y int) ([]byte, error) {
+	tileKey := fmt.Sprintf("%s/%d/%d/%d", key, z, x, y)
+	if data, ok := s.tiles.get(tileKey); ok {
+		return data.([]byte), nil
+	}
+
+	c, err := s.composite(entry, key)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := radolan.NewReprojection(c, s.grid(z, x, y), s.opts.Method)
+	if err != nil {
+		return nil, err
+	}
+	raster, err := r.Raster(c, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, s.render(raster, s.opts.Colors(c))); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	s.tiles.put(tileKey, data)
+	return data, nil
+}
+
+// grid returns the Web Mercator grid of the tile.
+func (s *Server) grid(z, x, y int) radolan.WebMercatorGrid {
+	const radius = 6378137.0 // Web Mercator sphere in m
+
+	extent := 2 * math.Pi * radius / float64(uint(1)<<uint(z)) // tile extent in m
+	return radolan.WebMercatorGrid{
+		West:       -math.Pi*radius + float64(x)*extent,
+		North:      math.Pi*radius - float64(y)*extent,
+		Resolution: extent / float64(s.opts.Size),
+		Width:      s.opts.Size,
+		Height:     s.opts.Size,
+	}
+}
+
+// render creates an image by evaluating the color function for each value of
+// the raster. Missing and transparent values are not drawn.
+func (s *Server) render(raster *radolan.Raster, fn vis.ColorFunc) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, raster.Width, raster.Height))
+	for y := 0; y < raster.Height; y++ {
+		for x := 0; x < raster.Width; x++ {
+			v := float64(raster.Data[y*raster.Width+x])
+			if math.IsNaN(v) || s.opts.Transparent != nil && s.opts.Transparent(v) {
+				continue
+			}
+			img.SetRGBA(x, y, fn(v))
+		}
+	}
+	return img
+}
+
+// logf writes to the error log of the options.
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.opts.ErrorLog != nil {
+		s.opts.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
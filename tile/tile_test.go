@@ -0,0 +1,205 @@
+package tile
+
+import (
+	"bytes"
+	"gitlab.cs.fau.de/since/radolan"
+	"gitlab.cs.fau.de/since/radolan/radolan2png/vis"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeComposite writes a RW composite holding the given value to dir.
+func writeComposite(t *testing.T, dir string, forecast time.Time, value float32) {
+	c := radolan.NewDummy("RW", 3, 900, 900)
+	c.CaptureTime = forecast
+	c.ForecastTime = forecast
+	c.Interval = time.Hour
+	c.DataUnit = radolan.Unit_mm
+	c.Px, c.Py = 900, 900
+	c.PlainData = make([][]float32, c.Py)
+	for y := range c.PlainData {
+		c.PlainData[y] = make([]float32, c.Px)
+		for x := range c.PlainData[y] {
+			c.PlainData[y][x] = value
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.Encode(&buf); err != nil {
+		t.Fatalf("Encode(): returned error: %#v", err.Error())
+	}
+
+	name := filepath.Join(dir, "raa01-rw_10000-"+forecast.Format("0601021504")+"-dwd---bin")
+	if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// get requests the path and decodes the returned tile.
+func get(t *testing.T, s *Server, path string) (int, *image.RGBA) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("GET %s: Content-Type: %s; expected: image/png", path, ct)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("GET %s: png.Decode(): returned error: %#v", path, err.Error())
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, image.Point{}, draw.Src)
+	return rec.Code, rgba
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC)
+	writeComposite(t, dir, base, 10)
+	ioutil.WriteFile(filepath.Join(dir, "README"), []byte("no radolan file"), 0644)
+
+	s, err := NewServer(dir, nil)
+	if err != nil {
+		t.Fatalf("NewServer(): returned error: %#v", err.Error())
+	}
+
+	// tile 6/33/21 covers central germany
+	code, img := get(t, s, "/RW/201607311650/6/33/21.png")
+	if code != http.StatusOK {
+		t.Fatalf("GET RW tile: status %d; expected: %d", code, http.StatusOK)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Errorf("GET RW tile: size %v; expected: 256x256", b)
+	}
+	expected := vis.DefaultColorFunc(&radolan.Composite{DataUnit: radolan.Unit_mm, Interval: time.Hour})(10)
+	if c := img.RGBAAt(128, 128); c != expected {
+		t.Errorf("GET RW tile: center color %v; expected: %v", c, expected)
+	}
+
+	// tiles are cached
+	if s.tiles.len() != 1 || s.composites.len() != 1 {
+		t.Errorf("cache holds %d tiles, %d composites; expected: 1, 1", s.tiles.len(), s.composites.len())
+	}
+	get(t, s, "/RW/2016-07-31T16:50:00Z/6/33/21.png")
+	if s.tiles.len() != 1 {
+		t.Errorf("cache holds %d tiles after repeated request; expected: 1", s.tiles.len())
+	}
+
+	// tiles outside of the composite are transparent
+	if code, img := get(t, s, "/RW/latest/6/0/0.png"); code != http.StatusOK || img.RGBAAt(128, 128).A != 0 {
+		t.Errorf("GET tile outside of composite: status %d; expected transparent tile", code)
+	}
+
+	// errors
+	for path, status := range map[string]int{
+		"/RX/latest/6/33/21.png":       http.StatusNotFound,   // unknown product
+		"/RW/201607311750/6/33/21.png": http.StatusNotFound,   // unknown time
+		"/RW/yesterday/6/33/21.png":    http.StatusBadRequest, // invalid time
+		"/RW/latest/6/64/21.png":       http.StatusNotFound,   // invalid tile
+		"/RW/latest/13/0/0.png":        http.StatusNotFound,   // exceeds maximum zoom level
+		"/RW/latest/6/33/21":           http.StatusNotFound,
+		"/RW/latest":                   http.StatusNotFound,
+	} {
+		if code, _ := get(t, s, path); code != status {
+			t.Errorf("GET %s: status %d; expected: %d", path, code, status)
+		}
+	}
+
+	// new files are picked up by Refresh
+	writeComposite(t, dir, base.Add(time.Hour), 50)
+	if err := s.Refresh(); err != nil {
+		t.Fatalf("Refresh(): returned error: %#v", err.Error())
+	}
+	_, img = get(t, s, "/RW/latest/6/33/21.png")
+	expected = vis.DefaultColorFunc(&radolan.Composite{DataUnit: radolan.Unit_mm, Interval: time.Hour})(50)
+	if img == nil || img.RGBAAt(128, 128) != expected {
+		t.Errorf("GET latest RW tile after Refresh: unexpected color; expected: %v", expected)
+	}
+	if code, _ := get(t, s, "/RW/201607311650/6/33/21.png"); code != http.StatusOK {
+		t.Errorf("GET previous RW tile after Refresh: status %d; expected: %d", code, http.StatusOK)
+	}
+}
+
+func TestServerUnknownUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// RV forecast, whose unit is not defined in the catalog
+	c := radolan.NewDummy("RV", 5, 1100, 1200)
+	c.CaptureTime = time.Date(2023, time.July, 14, 12, 5, 0, 0, time.UTC)
+	c.ForecastTime = c.CaptureTime
+	c.Px, c.Py = c.Dx, c.Dy
+	c.PlainData = make([][]float32, c.Py)
+	for y := range c.PlainData {
+		c.PlainData[y] = make([]float32, c.Px)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Encode(&buf); err != nil {
+		t.Fatalf("Encode(): returned error: %#v", err.Error())
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "DE1200_RV2307141205_000"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(dir, nil)
+	if err != nil {
+		t.Fatalf("NewServer(): returned error: %#v", err.Error())
+	}
+	for i := 0; i < 2; i++ { // uncached and cached
+		if code, img := get(t, s, "/RV/latest/6/33/21.png"); code != http.StatusOK || img == nil {
+			t.Errorf("GET RV tile: status %d; expected: %d", code, http.StatusOK)
+		}
+	}
+	if s.composites.len() != 1 {
+		t.Errorf("cache holds %d composites; expected: 1", s.composites.len())
+	}
+}
+
+func TestServerTransparent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeComposite(t, dir, time.Date(2016, time.July, 31, 16, 50, 0, 0, time.UTC), 10)
+
+	opts := DefaultOptions
+	opts.Size = 64
+	opts.Transparent = func(val float64) bool { return val < 20 }
+	s, err := NewServer(dir, &opts)
+	if err != nil {
+		t.Fatalf("NewServer(): returned error: %#v", err.Error())
+	}
+
+	code, img := get(t, s, "/RW/latest/6/33/21.png")
+	if code != http.StatusOK {
+		t.Fatalf("GET RW tile: status %d; expected: %d", code, http.StatusOK)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Errorf("GET RW tile: size %v; expected: 64x64", b)
+	}
+	if c := img.RGBAAt(32, 32); c.A != 0 {
+		t.Errorf("GET RW tile: center color %v; expected transparent", c)
+	}
+}
@@ -0,0 +1,106 @@
+package tile
+
+import (
+	"gitlab.cs.fau.de/since/radolan"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fileState is the indexed state of a file in the served directory.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	entries []radolan.Entry
+}
+
+// Refresh scans the directory for new, modified and removed files. Only new
+// and modified files are read, so that calling Refresh repeatedly is cheap.
+// Composites which cannot be read are returned as radolan.MemberErrors, while
+// the remaining composites are served. Files that failed are not read again
+// until they are modified.
+func (s *Server) Refresh() error {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	files := make(map[string]*fileState)
+	var errs radolan.MemberErrors
+
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		if old, ok := s.files[path]; ok && old.modTime.Equal(info.ModTime()) && old.size == info.Size() {
+			files[path] = old // unchanged
+			return nil
+		}
+
+		state := &fileState{modTime: info.ModTime(), size: info.Size()}
+		idx, err := radolan.ScanIndex(path)
+		if idx != nil {
+			state.entries = idx.Entries
+		}
+		switch e := err.(type) {
+		case nil:
+		case radolan.MemberErrors:
+			errs = append(errs, e...)
+		default:
+			errs = append(errs, &radolan.MemberError{Name: path, Err: err})
+		}
+
+		files[path] = state
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// merge the entries of all files in a deterministic order
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	idx := &radolan.Index{}
+	for _, path := range paths {
+		idx.Entries = append(idx.Entries, files[path].entries...)
+	}
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].ForecastTime.Before(idx.Entries[j].ForecastTime)
+	})
+
+	s.mu.Lock()
+	s.files = files
+	s.index = idx
+	s.mu.Unlock()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Watch refreshes the served directory in the given interval until stop is
+// closed, so that new files are served without restarting the server. Errors
+// are written to the ErrorLog of the options.
+func (s *Server) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				s.logf("refresh: %v", err)
+			}
+		}
+	}
+}
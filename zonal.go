@@ -0,0 +1,255 @@
+package radolan
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// number of sampled sub-rows per pixel row during rasterisation
+const rasterSubrows = 16
+
+// Rasterize returns the mask of the feature on the grid of the composite. Each
+// pixel is weighted by the fraction of its area covered by the polygons of the
+// feature. Rows which are not covered at all are nil. The vertices are
+// projected by the Project method and connected by straight lines in the
+// composite grid. An error is returned if no projection is available.
+func (c *Composite) Rasterize(f Feature) (Mask, error) {
+	if !c.HasProjection {
+		return nil, newError("Rasterize", "no projection available")
+	}
+
+	// projected edges of all rings
+	var edges [][4]float64 // x0, y0, x1, y1
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, polygon := range f.Polygons {
+		for _, ring := range polygon {
+			for i := range ring {
+				x0, y0 := c.Project(ring[i][1], ring[i][0])
+				next := ring[(i+1)%len(ring)] // closed ring
+				x1, y1 := c.Project(next[1], next[0])
+
+				edges = append(edges, [4]float64{x0, y0, x1, y1})
+				minY, maxY = math.Min(minY, y0), math.Max(maxY, y0)
+			}
+		}
+	}
+
+	mask := make(Mask, c.Dy)
+	if len(edges) == 0 {
+		return mask, nil
+	}
+
+	begin := int(math.Max(0, math.Floor(minY)))
+	end := int(math.Min(float64(c.Dy), math.Ceil(maxY)))
+
+	var crossings []float64
+	for y := begin; y < end; y++ {
+		for s := 0; s < rasterSubrows; s++ {
+			sy := float64(y) + (float64(s)+0.5)/rasterSubrows
+
+			// even-odd rule: spans between pairs of crossings are inside
+			crossings = crossings[:0]
+			for _, e := range edges {
+				if (e[1] <= sy) != (e[3] <= sy) {
+					crossings = append(crossings, e[0]+(sy-e[1])*(e[2]-e[0])/(e[3]-e[1]))
+				}
+			}
+			sort.Float64s(crossings)
+
+			for i := 0; i+1 < len(crossings); i += 2 {
+				if mask[y] == nil {
+					mask[y] = make([]float64, c.Dx)
+				}
+				addSpan(mask[y], crossings[i], crossings[i+1], 1.0/rasterSubrows)
+			}
+		}
+	}
+
+	return mask, nil
+}
+
+// featureArea returns the area of the polygons of the feature in pixels,
+// including the parts outside the grid. Holes are subtracted from the outer
+// ring of their polygon.
+func (c *Composite) featureArea(f Feature) float64 {
+	var area float64
+	for _, polygon := range f.Polygons {
+		for i, ring := range polygon {
+			// shoelace formula of the projected vertices
+			var a float64
+			for j := range ring {
+				x0, y0 := c.Project(ring[j][1], ring[j][0])
+				next := ring[(j+1)%len(ring)] // closed ring
+				x1, y1 := c.Project(next[1], next[0])
+				a += x0*y1 - x1*y0
+			}
+
+			if i == 0 {
+				area += math.Abs(a) / 2
+			} else {
+				area -= math.Abs(a) / 2
+			}
+		}
+	}
+	return area
+}
+
+// addSpan adds the horizontal span [x0, x1) weighted by w to the covered area
+// of each pixel in the row.
+func addSpan(row []float64, x0, x1, w float64) {
+	x0 = math.Max(x0, 0)
+	x1 = math.Min(x1, float64(len(row)))
+
+	for x := int(math.Floor(x0)); float64(x) < x1; x++ {
+		overlap := math.Min(x1, float64(x+1)) - math.Max(x0, float64(x))
+		row[x] += overlap * w
+	}
+}
+
+// ZonalStats holds the statistics of a feature for a single composite.
+type ZonalStats struct {
+	Properties   map[string]interface{} // properties of the feature
+	ForecastTime time.Time              // forecast time of the composite
+
+	Sum      float64 // sum of the valid values weighted by coverage
+	Mean     float64 // mean of the valid values weighted by coverage (NaN if not available)
+	Max      float64 // maximum of the valid values (NaN if not available)
+	Coverage float64 // fraction of the feature area holding valid values, including parts outside the grid
+}
+
+// ZonalStats computes the statistics of each feature for the first layer of the
+// composite.
+func (c *Composite) ZonalStats(features []Feature) ([]ZonalStats, error) {
+	return ZonalStatsSeries([]*Composite{c}, features)
+}
+
+// ZonalStatsSeries computes the statistics of each feature for the first layer
+// of each composite. The composites must share the same grid, so that each
+// feature is rasterised only once. The result is ordered by feature and then by
+// composite.
+func ZonalStatsSeries(cs []*Composite, features []Feature) ([]ZonalStats, error) {
+	if len(cs) == 0 {
+		return nil, newError("ZonalStatsSeries", "no composites")
+	}
+
+	first := cs[0]
+	for _, c := range cs {
+		if c.Dx != first.Dx || c.Dy != first.Dy || c.detectGrid() != first.detectGrid() ||
+			c.proj_wgs84 != first.proj_wgs84 {
+			return nil, newError("ZonalStatsSeries", "composites do not share the same grid")
+		}
+	}
+
+	var zs []ZonalStats
+	for _, f := range features {
+		mask, err := first.Rasterize(f)
+		if err != nil {
+			return nil, err
+		}
+		var covered float64 // area of the feature within the grid
+		for _, row := range mask {
+			for _, w := range row {
+				covered += w
+			}
+		}
+		area := first.featureArea(f)
+
+		for _, c := range cs {
+			s, err := c.Stats(&StatsOptions{Mask: mask})
+			if err != nil {
+				return nil, err
+			}
+
+			// the coverage refers to the whole feature instead of the grid
+			var coverage float64
+			if covered > 0 && area > 0 {
+				coverage = math.Min(1, s.Coverage*covered/area)
+			}
+
+			zs = append(zs, ZonalStats{
+				Properties:   f.Properties,
+				ForecastTime: c.ForecastTime,
+				Sum:          s.Sum,
+				Mean:         s.Mean,
+				Max:          s.Max,
+				Coverage:     coverage,
+			})
+		}
+	}
+
+	return zs, nil
+}
+
+// WriteZonalCSV writes the zonal statistics as comma separated values to w. The
+// leading columns hold the given feature properties, followed by the forecast
+// time (RFC 3339) and the statistics. Missing values are written as empty
+// fields.
+func WriteZonalCSV(w io.Writer, zs []ZonalStats, keys []string) error {
+	cw := csv.NewWriter(w)
+
+	header := append(append([]string(nil), keys...), "time", "sum", "mean", "max", "coverage")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, z := range zs {
+		record := make([]string, 0, len(header))
+		for _, key := range keys {
+			v, ok := z.Properties[key]
+			if !ok || v == nil {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, fmt.Sprint(v))
+		}
+
+		record = append(record, z.ForecastTime.UTC().Format(time.RFC3339))
+		for _, v := range []float64{z.Sum, z.Mean, z.Max, z.Coverage} {
+			if math.IsNaN(v) {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, formatFloat(v))
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteZonalJSON writes the zonal statistics as JSON array to w. Each element
+// holds the properties of the feature, the forecast time and the statistics.
+// Missing values are written as null.
+func WriteZonalJSON(w io.Writer, zs []ZonalStats) error {
+	type element struct {
+		Properties map[string]interface{} `json:"properties"`
+		Time       time.Time              `json:"time"`
+		Sum        *float64               `json:"sum"`
+		Mean       *float64               `json:"mean"`
+		Max        *float64               `json:"max"`
+		Coverage   *float64               `json:"coverage"`
+	}
+
+	value := func(v float64) *float64 {
+		if math.IsNaN(v) {
+			return nil
+		}
+		return &v
+	}
+
+	elements := make([]element, len(zs))
+	for i, z := range zs {
+		elements[i] = element{z.Properties, z.ForecastTime.UTC(), value(z.Sum), value(z.Mean), value(z.Max), value(z.Coverage)}
+	}
+
+	return json.NewEncoder(w).Encode(elements)
+}
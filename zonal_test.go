@@ -0,0 +1,156 @@
+package radolan
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rectangleRing returns the closed ring of the given rectangle in data indices
+// as geographical coordinates.
+func rectangleRing(c *Composite, x0, y0, x1, y1 float64) [][2]float64 {
+	var ring [][2]float64
+	for _, p := range [][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0}} {
+		north, east := c.Unproject(p[0], p[1])
+		ring = append(ring, [2]float64{east, north})
+	}
+	return ring
+}
+
+func TestRasterize(t *testing.T) {
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 { return 1 })
+
+	// rectangle covering half pixels at the left and right border with a hole
+	f := Feature{Polygons: []Polygon{{
+		rectangleRing(comp, 100.5, 200, 110.5, 210),
+		rectangleRing(comp, 104, 204, 106, 206),
+	}}}
+	mask, err := comp.Rasterize(f)
+	if err != nil {
+		t.Fatalf("RW.Rasterize(): returned error: %#v", err.Error())
+	}
+
+	var area float64
+	for y, row := range mask {
+		if row == nil {
+			continue
+		}
+		if y < 200 || y >= 210 {
+			t.Errorf("RW.Rasterize(): unexpected row %d", y)
+		}
+		for _, w := range row {
+			area += w
+		}
+	}
+	if !absequal(area, 100-4, 1e-3) {
+		t.Errorf("RW.Rasterize(): covered area %f; expected: 96", area)
+	}
+
+	testcases := []struct {
+		x, y int
+		w    float64
+	}{
+		{100, 202, 0.5}, {101, 202, 1}, {110, 202, 0.5}, {111, 202, 0}, {104, 204, 0}, {103, 204, 1},
+	}
+	for _, test := range testcases {
+		if w := mask[test.y][test.x]; !absequal(w, test.w, 1e-3) {
+			t.Errorf("RW.Rasterize()[%d][%d] = %f; expected: %f", test.y, test.x, w, test.w)
+		}
+	}
+}
+
+func TestZonalStats(t *testing.T) {
+	geojson := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"name": "inside", "id": 1}, "geometry": {"type": "Polygon", "coordinates": []}},
+		{"type": "Feature", "properties": {"name": "outside", "id": 2}, "geometry": {"type": "MultiPolygon", "coordinates": []}},
+		{"type": "Feature", "properties": {"name": "station"}, "geometry": {"type": "Point", "coordinates": [13.4, 52.5]}}
+	]}`
+	features, err := ReadGeoJSON(strings.NewReader(geojson))
+	if err != nil {
+		t.Fatalf("ReadGeoJSON(): returned error: %#v", err.Error())
+	}
+	if len(features) != 3 || features[0].Properties["name"] != "inside" || features[2].Polygons != nil {
+		t.Fatalf("ReadGeoJSON(): unexpected features: %#v", features)
+	}
+
+	var cs []*Composite
+	for i := 0; i < 2; i++ {
+		c := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 {
+			if x >= 105 {
+				return NaN
+			}
+			return float32(x%2 + i)
+		})
+		c.ForecastTime = c.ForecastTime.Add(time.Duration(i) * time.Hour)
+		cs = append(cs, c)
+	}
+	features[0].Polygons = []Polygon{{rectangleRing(cs[0], 100, 100, 110, 110)}}
+	features[1].Polygons = []Polygon{{rectangleRing(cs[0], -20, -20, -10, -10)}}
+
+	zs, err := ZonalStatsSeries(cs, features)
+	if err != nil {
+		t.Fatalf("ZonalStatsSeries(): returned error: %#v", err.Error())
+	}
+	if len(zs) != 6 {
+		t.Fatalf("ZonalStatsSeries(): %d results; expected: 6", len(zs))
+	}
+
+	// columns 100 to 104 are valid with alternating values 0, 1, 0, 1, 0 (+ i)
+	inside := zs[1]
+	if !absequal(inside.Coverage, 0.5, 1e-3) || !absequal(inside.Sum, 10*(2+5), 1e-2) ||
+		!absequal(inside.Mean, 7.0/5, 1e-3) || inside.Max != 2 {
+		t.Errorf("ZonalStatsSeries(inside): %#v", inside)
+	}
+	if !inside.ForecastTime.Equal(cs[1].ForecastTime) {
+		t.Errorf("ZonalStatsSeries(inside): ForecastTime: %v; expected: %v", inside.ForecastTime, cs[1].ForecastTime)
+	}
+	if outside := zs[2]; outside.Coverage != 0 || !math.IsNaN(outside.Mean) {
+		t.Errorf("ZonalStatsSeries(outside): %#v", outside)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteZonalCSV(&buf, zs[:3], []string{"id", "name"}); err != nil {
+		t.Fatalf("WriteZonalCSV(): returned error: %#v", err.Error())
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "id,name,time,sum,mean,max,coverage" || !strings.HasPrefix(lines[1], "1,inside,2016-07-31T17:05:00Z,20.0") ||
+		lines[3] != "2,outside,2016-07-31T17:05:00Z,0,,,0" {
+		t.Errorf("WriteZonalCSV(): unexpected output: %#v", lines)
+	}
+
+	buf.Reset()
+	if err := WriteZonalJSON(&buf, zs); err != nil {
+		t.Fatalf("WriteZonalJSON(): returned error: %#v", err.Error())
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 6 {
+		t.Fatalf("WriteZonalJSON(): invalid output: %v", err)
+	}
+	if decoded[2]["mean"] != nil || decoded[0]["properties"].(map[string]interface{})["name"] != "inside" {
+		t.Errorf("WriteZonalJSON(): unexpected element: %#v", decoded[2])
+	}
+}
+
+func TestZonalStatsGridEdge(t *testing.T) {
+	comp := newTestComposite("RW", 3, 900, 900, -1, nil, func(c *Composite, x, y int) float32 { return 1 })
+
+	// half of the features is outside the grid
+	features := []Feature{
+		{Polygons: []Polygon{{rectangleRing(comp, -10, 100, 10, 110)}}},
+		{Polygons: []Polygon{{rectangleRing(comp, 100, 895, 110, 905), rectangleRing(comp, 102, 902, 108, 904)}}},
+	}
+	expected := []float64{0.5, 50.0 / 88}
+
+	zs, err := comp.ZonalStats(features)
+	if err != nil {
+		t.Fatalf("RW.ZonalStats(): returned error: %#v", err.Error())
+	}
+	for i, z := range zs {
+		if !absequal(z.Coverage, expected[i], 1e-3) || z.Mean != 1 {
+			t.Errorf("RW.ZonalStats()[%d]: Coverage %f, Mean %f; expected: %f, 1", i, z.Coverage, z.Mean, expected[i])
+		}
+	}
+}